@@ -27,6 +27,10 @@ const (
 	defaultArgSendErrorFrames     = false
 	defaultOffCPUThreshold        = 0
 	defaultEnvVarsValue           = ""
+	defaultPprofMaxBytes          = 1 << 30
+	defaultPprofMaxAge            = 24 * time.Hour
+	defaultSpoolMaxBytes          = 256 << 20
+	defaultSpoolMaxAge            = 1 * time.Hour
 
 	// This is the X in 2^(n + x) where n is the default hardcoded map size value
 	defaultArgMapScaleFactor = 0
@@ -36,10 +40,28 @@ const (
 var (
 	noKernelVersionCheckHelp = "Disable checking kernel version for eBPF support. " +
 		"Use at your own risk, to run the agent on older kernels with backported eBPF features."
-	copyrightHelp      = "Show copyright and short license text."
-	collAgentAddrHelp  = "The collection agent address in the format of host:port."
-	verboseModeHelp    = "Enable verbose logging and debugging capabilities."
-	tracersHelp        = "Comma-separated list of interpreter tracers to include."
+	cgroupsHelp = "Comma separated list of cgroupv2 paths, as found in /proc/<pid>/cgroup. " +
+		"If set, only tasks in these cgroups and their descendants are profiled. Unlike " +
+		"filter-include, other tasks are skipped in eBPF before unwinding."
+	cmdlineRedactHelp = "Comma separated list of regular expressions whose matches are " +
+		"replaced by [REDACTED] in the reported process command lines. If a pattern has " +
+		"capture groups, only these are replaced, e.g. '--password[= ](\\S+)'. Use \\x2c " +
+		"to match a literal comma."
+	copyrightHelp     = "Show copyright and short license text."
+	collAgentAddrHelp = "The collection agent address in the format of host:port for " +
+		"OTLP/gRPC, or an http:// or https:// URL for OTLP/HTTP. Multiple comma separated " +
+		"addresses send the profiles to each of the collection agents."
+	collAgentCompressionHelp = "Compression of the OTLP/HTTP request bodies sent to the " +
+		"collection agent. Supported values are 'gzip' and '' for no compression."
+	collAgentHeadersHelp = "Comma separated list of key=value headers added to the " +
+		"OTLP/HTTP requests sent to the collection agent, e.g. 'Authorization=Bearer xyz'."
+	collAgentProtocolHelp = "Protocol to send profiles to the collection agent with: " +
+		"'grpc', 'http/protobuf' or 'http/json'. If empty, OTLP/HTTP is used for http:// " +
+		"and https:// addresses and OTLP/gRPC otherwise."
+	verboseModeHelp = "Enable verbose logging and debugging capabilities."
+	tracersHelp     = "Comma-separated list of interpreter tracers to include. " +
+		"'all' includes all of them except perfmap, which symbolizes JIT code from " +
+		"perf map and jitdump files."
 	mapScaleFactorHelp = fmt.Sprintf("Scaling factor for eBPF map sizes. "+
 		"Every increase by 1 doubles the map size. Increase if you see eBPF map size errors. "+
 		"Default is %d corresponding to 4GB of executable address space, max is %d.",
@@ -56,6 +78,33 @@ var (
 		tracer.ProbabilisticThresholdMax-1, tracer.ProbabilisticThresholdMax-1)
 	probabilisticIntervalHelp = "Time interval for which probabilistic profiling will be " +
 		"enabled or disabled."
+	probesHelp = "Comma separated list of user space probes that trigger the collection of " +
+		"a stack trace whenever they are hit, reported as 'probe' profile. Uprobes are " +
+		"given as binary:symbol, e.g. /usr/lib/libc.so.6:malloc, USDT probes as " +
+		"binary:provider:probe."
+	kernelEventsHelp = "Comma separated list of kernel events that trigger the collection " +
+		"of a stack trace, each reported as separate profile. Tracepoints are given as " +
+		"group:name, e.g. syscalls:sys_enter_write, kprobes as kernel function, e.g. " +
+		"tcp_retransmit_skb. An optional @N suffix samples one out of N events. " +
+		"Requires Linux 5.15 or newer."
+	softwareEventsHelp = "Comma separated list of software perf events that trigger the " +
+		"collection of a stack trace, each reported as separate profile. Supported events " +
+		"are page-faults, major-faults, minor-faults, context-switches and cpu-migrations. " +
+		"An optional @N suffix sets the sample period, e.g. page-faults@100."
+	processAncestorsHelp = "Report the names of the ancestor processes, starting with the " +
+		"parent, as process.ancestors sample attribute."
+	pythonAsyncioHelp = "Extend Python stacks with the await chain of asyncio tasks. " +
+		"Requires the python tracer."
+	pythonQualNamesHelp = "Report Python functions as Class.method along with their " +
+		"module. Requires the python tracer."
+	pythonGreenletHelp = "Label Python stacks with the address of the running greenlet " +
+		"as python.greenlet.id, which splits the traces of each greenlet. Requires the " +
+		"python tracer and greenlet 2.0 or later."
+	processCmdlineHelp = "Report the command lines of processes as process.command_line " +
+		"sample attribute. Command lines may contain secrets, see cmdline-redact."
+	maxSamplesPerSecondHelp = "Set the frequency (in Hz) of stack trace sampling while " +
+		"SIGUSR1 raised it. SIGUSR2 restores samples-per-second. " +
+		"Disabled if not greater than samples-per-second."
 	pprofHelp             = "Listening address (e.g. localhost:6060) to serve pprof information."
 	samplesPerSecondHelp  = "Set the frequency (in Hz) of stack trace sampling."
 	reporterIntervalHelp  = "Set the reporter's interval in seconds."
@@ -68,8 +117,55 @@ var (
 		"Valid values are in the range [1..%d], and 0 to disable off-cpu profiling."+
 		"Default is %d.",
 		support.OffCPUThresholdMax, defaultOffCPUThreshold)
+	offCPUMinDurationHelp = "Enable off-cpu profiling in latency mode: instead of randomly " +
+		"sampling scheduler events like off-cpu-threshold, record only off-cpu intervals " +
+		"of at least this duration, e.g. 10ms. Mutually exclusive with off-cpu-threshold."
+	offCPUPIDsHelp = "Comma separated list of PIDs. If set, off-cpu profiling is restricted " +
+		"to these processes. Requires off-cpu-threshold or off-cpu-min-duration."
+	offCPUWakeupsHelp = "Collect the stacks of the tasks that wake up off-CPU sampled tasks, " +
+		"reported as 'wakeup' profile. Wakeup and off-CPU samples carry the PIDs and TIDs " +
+		"of waker and wakee as wakeup.* attributes. Requires off-cpu-threshold or " +
+		"off-cpu-min-duration."
+	pidsHelp = "Comma separated list of PIDs. If set, only these processes are profiled " +
+		"and the profiler exits once all of them exited."
+	followChildrenHelp = "Also profile the existing and future descendants of the " +
+		"processes given with pid. Requires pid."
 	envVarsHelp = "Comma separated list of environment variables that will be reported with the" +
 		"captured profiling samples."
+	filterIncludeHelp = "Comma separated list of key=pattern rules. If set, only samples " +
+		"matching at least one rule are reported. Keys are 'pid', 'comm', 'process', 'exe', " +
+		"'cgroup' and 'container'. Patterns may contain '*' and '?' wildcards, e.g. " +
+		"'exe=/opt/app/*'."
+	filterExcludeHelp = "Comma separated list of key=pattern rules. Samples matching any " +
+		"rule are dropped, e.g. 'comm=kworker*'. See filter-include for the rule format."
+	dockerSocketHelp = "Path of the Docker Engine API socket, e.g. " +
+		"/var/run/docker.sock. If set, samples are enriched with the name, image and " +
+		"labels of their containers."
+	kubeletURLHelp = "Base URL of the kubelet API, e.g. https://${NODE_IP}:10250. If set, " +
+		"samples are enriched with the Kubernetes metadata of their pods."
+	kubeletTokenFileHelp = "File with the bearer token to authenticate against the kubelet."
+	kubeletCAFileHelp    = "File with the CA certificates to verify the kubelet certificate. " +
+		"If empty, the certificate is not verified."
+	foldedOutputHelp = "Append folded stacks (flamegraph format) to this file every reporter " +
+		"interval, or write them to stdout if set to '-'. Off-CPU stacks are written to a " +
+		"separate file with the suffix '.offcpu'."
+	foldedGroupByHelp = "Group folded stacks by 'pid', 'container' or 'comm'. " +
+		"Disabled if empty."
+	pprofOutputDirHelp = "Write gzipped pprof files to this directory every reporter interval."
+	durationHelp       = "Stop profiling after this duration, e.g. 30s, and exit. " +
+		"0 profiles until the agent is interrupted."
+	outputHelp = "Record a single gzipped pprof profile of the on-CPU samples to this " +
+		"file when profiling stops, e.g. out.pb.gz. Other profiles are written next to " +
+		"it, e.g. out.offcpu.pb.gz. Usually combined with duration."
+	pprofMaxBytesHelp = "Maximum total size in bytes of the pprof files kept in " +
+		"pprof-output-dir. The oldest files are removed first. 0 disables the limit."
+	pprofMaxAgeHelp = "Maximum age of the pprof files kept in pprof-output-dir. " +
+		"0 disables the limit."
+	spoolDirHelp = "Directory to keep profiles in that failed to be sent to the collection " +
+		"agent. They are sent again once the collection agent is reachable. Disabled if empty."
+	spoolMaxBytesHelp = "Maximum total size in bytes of the profiles kept in spool-dir. " +
+		"The oldest profiles are dropped first. 0 disables the limit."
+	spoolMaxAgeHelp = "Maximum age of the profiles kept in spool-dir. 0 disables the limit."
 )
 
 // Package-scope variable, so that conditionally compiled other components can refer
@@ -83,10 +179,32 @@ func parseArgs() (*controller.Config, error) {
 	// Please keep the parameters ordered alphabetically in the source-code.
 	fs.UintVar(&args.BpfVerifierLogLevel, "bpf-log-level", 0, bpfVerifierLogLevelHelp)
 
+	fs.StringVar(&args.Cgroups, "cgroups", "", cgroupsHelp)
+	fs.StringVar(&args.CmdlineRedact, "cmdline-redact", "", cmdlineRedactHelp)
 	fs.StringVar(&args.CollAgentAddr, "collection-agent", "", collAgentAddrHelp)
+	fs.StringVar(&args.CollAgentCompression, "collection-agent-compression", "",
+		collAgentCompressionHelp)
+	fs.StringVar(&args.CollAgentHeaders, "collection-agent-headers", "", collAgentHeadersHelp)
+	fs.StringVar(&args.CollAgentProtocol, "collection-agent-protocol", "",
+		collAgentProtocolHelp)
 	fs.BoolVar(&args.Copyright, "copyright", false, copyrightHelp)
 
 	fs.BoolVar(&args.DisableTLS, "disable-tls", false, disableTLSHelp)
+	fs.StringVar(&args.DockerSocket, "docker-socket", "", dockerSocketHelp)
+
+	fs.StringVar(&args.FilterExclude, "filter-exclude", "", filterExcludeHelp)
+	fs.StringVar(&args.FilterInclude, "filter-include", "", filterIncludeHelp)
+
+	fs.StringVar(&args.FoldedGroupBy, "folded-group-by", "", foldedGroupByHelp)
+	fs.StringVar(&args.FoldedOutput, "folded-output", "", foldedOutputHelp)
+
+	fs.StringVar(&args.KubeletCAFile, "k8s-kubelet-ca-file",
+		"/var/run/secrets/kubernetes.io/serviceaccount/ca.crt", kubeletCAFileHelp)
+	fs.StringVar(&args.KubeletTokenFile, "k8s-kubelet-token-file",
+		"/var/run/secrets/kubernetes.io/serviceaccount/token", kubeletTokenFileHelp)
+	fs.StringVar(&args.KubeletURL, "k8s-kubelet-url", "", kubeletURLHelp)
+
+	fs.StringVar(&args.KernelEvents, "kernel-events", "", kernelEventsHelp)
 
 	fs.UintVar(&args.MapScaleFactor, "map-scale-factor",
 		defaultArgMapScaleFactor, mapScaleFactorHelp)
@@ -101,21 +219,43 @@ func parseArgs() (*controller.Config, error) {
 		noKernelVersionCheckHelp)
 
 	fs.StringVar(&args.PprofAddr, "pprof", "", pprofHelp)
+	fs.DurationVar(&args.PprofMaxAge, "pprof-max-age", defaultPprofMaxAge, pprofMaxAgeHelp)
+	fs.Uint64Var(&args.PprofMaxBytes, "pprof-max-bytes", defaultPprofMaxBytes,
+		pprofMaxBytesHelp)
+	fs.StringVar(&args.PprofOutputDir, "pprof-output-dir", "", pprofOutputDirHelp)
+
+	fs.DurationVar(&args.Duration, "duration", 0, durationHelp)
+	fs.StringVar(&args.Output, "output", "", outputHelp)
 
 	fs.DurationVar(&args.ProbabilisticInterval, "probabilistic-interval",
 		defaultProbabilisticInterval, probabilisticIntervalHelp)
 	fs.UintVar(&args.ProbabilisticThreshold, "probabilistic-threshold",
 		defaultProbabilisticThreshold, probabilisticThresholdHelp)
+	fs.StringVar(&args.Probes, "probes", "", probesHelp)
+	fs.BoolVar(&args.ProcessAncestors, "process-ancestors", false, processAncestorsHelp)
+	fs.BoolVar(&args.ProcessCmdline, "process-cmdline", false, processCmdlineHelp)
+	fs.BoolVar(&args.PythonAsyncio, "python-asyncio", false, pythonAsyncioHelp)
+	fs.BoolVar(&args.PythonGreenlet, "python-greenlet", false, pythonGreenletHelp)
+	fs.BoolVar(&args.PythonQualNames, "python-qualnames", false, pythonQualNamesHelp)
 
 	fs.DurationVar(&args.ReporterInterval, "reporter-interval", defaultArgReporterInterval,
 		reporterIntervalHelp)
 
 	fs.IntVar(&args.SamplesPerSecond, "samples-per-second", defaultArgSamplesPerSecond,
 		samplesPerSecondHelp)
+	fs.IntVar(&args.MaxSamplesPerSecond, "max-samples-per-second", 0,
+		maxSamplesPerSecondHelp)
 
 	fs.BoolVar(&args.SendErrorFrames, "send-error-frames", defaultArgSendErrorFrames,
 		sendErrorFramesHelp)
 
+	fs.StringVar(&args.SoftwareEvents, "software-events", "", softwareEventsHelp)
+
+	fs.StringVar(&args.SpoolDir, "spool-dir", "", spoolDirHelp)
+	fs.DurationVar(&args.SpoolMaxAge, "spool-max-age", defaultSpoolMaxAge, spoolMaxAgeHelp)
+	fs.Uint64Var(&args.SpoolMaxBytes, "spool-max-bytes", defaultSpoolMaxBytes,
+		spoolMaxBytesHelp)
+
 	fs.StringVar(&args.Tracers, "t", "all", "Shorthand for -tracers.")
 	fs.StringVar(&args.Tracers, "tracers", "all", tracersHelp)
 
@@ -125,6 +265,12 @@ func parseArgs() (*controller.Config, error) {
 
 	fs.UintVar(&args.OffCPUThreshold, "off-cpu-threshold",
 		defaultOffCPUThreshold, offCPUThresholdHelp)
+	fs.DurationVar(&args.OffCPUMinDuration, "off-cpu-min-duration", 0, offCPUMinDurationHelp)
+	fs.StringVar(&args.OffCPUPIDs, "off-cpu-pids", "", offCPUPIDsHelp)
+	fs.BoolVar(&args.OffCPUWakeups, "off-cpu-wakeups", false, offCPUWakeupsHelp)
+
+	fs.StringVar(&args.PIDs, "pid", "", pidsHelp)
+	fs.BoolVar(&args.FollowChildren, "follow-children", false, followChildrenHelp)
 
 	fs.StringVar(&args.IncludeEnvVars, "env-vars", defaultEnvVarsValue, envVarsHelp)
 
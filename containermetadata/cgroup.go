@@ -0,0 +1,44 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package containermetadata resolves metadata about the containers samples
+// were recorded in, e.g. from the kubelet.
+package containermetadata // import "go.opentelemetry.io/ebpf-profiler/containermetadata"
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// containerIDPattern matches the container ID at the end of a cgroupv2 path, as
+	// created by containerd ("cri-containerd-<id>.scope", "cri-containerd:<id>"),
+	// CRI-O ("crio-<id>.scope"), docker ("docker-<id>.scope", "/docker/<id>") and
+	// the cgroupfs driver of the kubelet ("/kubepods/<qos>/pod<uid>/<id>").
+	containerIDPattern = regexp.MustCompile(`(?:^|[-/:])([0-9a-f]{64})(?:\.scope)?$`)
+
+	// podUIDPattern matches the pod UID in the cgroupv2 path. The systemd cgroup
+	// driver replaces the dashes in the UID by underscores.
+	podUIDPattern = regexp.MustCompile(
+		`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+)
+
+// ContainerIDFromCgroup extracts the container ID from a cgroupv2 path. It
+// returns an empty string if the path does not belong to a container.
+func ContainerIDFromCgroup(cgroupPath string) string {
+	matches := containerIDPattern.FindStringSubmatch(cgroupPath)
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
+
+// PodUIDFromCgroup extracts the Kubernetes pod UID from a cgroupv2 path. It
+// returns an empty string if the path does not belong to a pod.
+func PodUIDFromCgroup(cgroupPath string) string {
+	matches := podUIDPattern.FindStringSubmatch(cgroupPath)
+	if matches == nil {
+		return ""
+	}
+	return strings.ReplaceAll(matches[1], "_", "-")
+}
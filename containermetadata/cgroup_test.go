@@ -0,0 +1,66 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package containermetadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testContainerID = "2f4ab9b6a7c3d1e8f09b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f"
+
+func TestContainerIDFromCgroup(t *testing.T) {
+	tests := map[string]struct {
+		cgroup      string
+		containerID string
+		podUID      string
+	}{
+		"containerd systemd": {
+			cgroup: "/kubepods.slice/kubepods-burstable.slice/" +
+				"kubepods-burstable-pod9b2a7f04_3c1e_4d5a_8b6f_0e1d2c3b4a59.slice/" +
+				"cri-containerd-" + testContainerID + ".scope",
+			containerID: testContainerID,
+			podUID:      "9b2a7f04-3c1e-4d5a-8b6f-0e1d2c3b4a59",
+		},
+		"containerd cgroupfs": {
+			cgroup: "/kubepods/besteffort/pod9b2a7f04-3c1e-4d5a-8b6f-0e1d2c3b4a59/" +
+				testContainerID,
+			containerID: testContainerID,
+			podUID:      "9b2a7f04-3c1e-4d5a-8b6f-0e1d2c3b4a59",
+		},
+		"containerd nested": {
+			cgroup: "/system.slice/containerd.service/" +
+				"kubepods-pod9b2a7f04_3c1e_4d5a_8b6f_0e1d2c3b4a59.slice:cri-containerd:" +
+				testContainerID,
+			containerID: testContainerID,
+			podUID:      "9b2a7f04-3c1e-4d5a-8b6f-0e1d2c3b4a59",
+		},
+		"cri-o": {
+			cgroup:      "/kubepods.slice/crio-" + testContainerID + ".scope",
+			containerID: testContainerID,
+		},
+		"docker systemd": {
+			cgroup:      "/system.slice/docker-" + testContainerID + ".scope",
+			containerID: testContainerID,
+		},
+		"docker cgroupfs": {
+			cgroup:      "/docker/" + testContainerID,
+			containerID: testContainerID,
+		},
+		"systemd service": {
+			cgroup: "/system.slice/sshd.service",
+		},
+		"user session": {
+			cgroup: "/user.slice/user-1000.slice/session-2.scope",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.containerID, ContainerIDFromCgroup(tc.cgroup))
+			assert.Equal(t, tc.podUID, PodUIDFromCgroup(tc.cgroup))
+		})
+	}
+}
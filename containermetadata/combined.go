@@ -0,0 +1,25 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package containermetadata // import "go.opentelemetry.io/ebpf-profiler/containermetadata"
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+)
+
+// Combined merges the metadata of several providers.
+type Combined []samples.ContainerMetadataProvider
+
+// Assert that we implement the ContainerMetadataProvider interface.
+var _ samples.ContainerMetadataProvider = Combined(nil)
+
+// ContainerMetadata returns the attributes of all providers, in order.
+func (c Combined) ContainerMetadata(cgroupPath string) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, provider := range c {
+		attrs = append(attrs, provider.ContainerMetadata(cgroupPath)...)
+	}
+	return attrs
+}
@@ -0,0 +1,151 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package containermetadata // import "go.opentelemetry.io/ebpf-profiler/containermetadata"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+)
+
+// Assert that we implement the ContainerMetadataProvider interface.
+var _ samples.ContainerMetadataProvider = (*Docker)(nil)
+
+const (
+	// dockerRequestTimeout bounds the duration of a single container inspect request.
+	dockerRequestTimeout = 2 * time.Second
+
+	// containerLabelPrefix is the prefix of the attributes holding the container labels.
+	containerLabelPrefix = "container.label."
+)
+
+// Docker resolves container metadata from the Docker Engine API.
+//
+// Results are not cached, callers are expected to cache them per container.
+type Docker struct {
+	client *http.Client
+}
+
+// NewDocker returns a new instance of Docker that connects to the Docker Engine
+// API on the Unix socket socketPath, e.g. /var/run/docker.sock.
+func NewDocker(socketPath string) *Docker {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+
+	return &Docker{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   dockerRequestTimeout,
+		},
+	}
+}
+
+// Subset of the container information returned by the Docker Engine API.
+type dockerContainer struct {
+	// Name has the format /<name>.
+	Name string `json:"Name"`
+	// Image is the ID of the image.
+	Image  string `json:"Image"`
+	Config struct {
+		// Image is the image reference the container was created from.
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// ContainerMetadata returns the container.* attributes of the container with
+// the given cgroupv2 path.
+func (d *Docker) ContainerMetadata(cgroupPath string) []attribute.KeyValue {
+	containerID := ContainerIDFromCgroup(cgroupPath)
+	if containerID == "" {
+		return nil
+	}
+
+	container, err := d.inspect(containerID)
+	if err != nil {
+		log.Debugf("Failed to inspect container %s: %v", containerID, err)
+		return nil
+	}
+	if container == nil {
+		return nil
+	}
+
+	imageName, imageTag := splitImageReference(container.Config.Image)
+	attrs := []attribute.KeyValue{
+		semconv.ContainerRuntime("docker"),
+		semconv.ContainerName(strings.TrimPrefix(container.Name, "/")),
+		semconv.ContainerImageName(imageName),
+		semconv.ContainerImageID(container.Image),
+	}
+	if imageTag != "" {
+		attrs = append(attrs, semconv.ContainerImageTags(imageTag))
+	}
+	for _, key := range slices.Sorted(maps.Keys(container.Config.Labels)) {
+		attrs = append(attrs,
+			attribute.String(containerLabelPrefix+key, container.Config.Labels[key]))
+	}
+
+	return attrs
+}
+
+// inspect returns the information about the container with containerID, or
+// nil if the container is not known to the Docker Engine.
+func (d *Docker) inspect(containerID string) (*dockerContainer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerRequestTimeout)
+	defer cancel()
+
+	// The host is ignored as requests go to the Unix socket.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://docker/containers/"+containerID+"/json", http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// The container may belong to another runtime, or exited already.
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var container dockerContainer
+	if err = json.NewDecoder(resp.Body).Decode(&container); err != nil {
+		return nil, fmt.Errorf("failed to decode container: %v", err)
+	}
+	return &container, nil
+}
+
+// splitImageReference splits an image reference like registry:5000/app:1.2 or
+// app@sha256:<digest> into the image name and the tag.
+func splitImageReference(ref string) (name, tag string) {
+	name, _, _ = strings.Cut(ref, "@")
+	if i := strings.LastIndexByte(name, ':'); i > strings.LastIndexByte(name, '/') {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package containermetadata
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+)
+
+const testDockerContainer = `{
+  "Id": "` + testContainerID + `",
+  "Name": "/ci-runner",
+  "Image": "sha256:4c5a6d1e",
+  "Config": {
+    "Image": "registry.local:5000/ci/runner:1.4",
+    "Labels": {"team": "build", "com.example.job": "42"}
+  }
+}`
+
+func TestDocker(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/containers/"+testContainerID+"/json" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(testDockerContainer))
+		}))
+	srv.Listener = listener
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	d := NewDocker(socket)
+
+	attrs := d.ContainerMetadata("/system.slice/docker-" + testContainerID + ".scope")
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.ContainerRuntime("docker"),
+		semconv.ContainerName("ci-runner"),
+		semconv.ContainerImageName("registry.local:5000/ci/runner"),
+		semconv.ContainerImageID("sha256:4c5a6d1e"),
+		semconv.ContainerImageTags("1.4"),
+		attribute.String("container.label.com.example.job", "42"),
+		attribute.String("container.label.team", "build"),
+	}, attrs)
+
+	// Containers unknown to the Docker Engine.
+	assert.Nil(t, d.ContainerMetadata("/docker/"+
+		"1111111111111111111111111111111111111111111111111111111111111111"))
+	// Cgroups that do not belong to a container.
+	assert.Nil(t, d.ContainerMetadata("/system.slice/sshd.service"))
+}
+
+func TestSplitImageReference(t *testing.T) {
+	tests := map[string]struct {
+		name, tag string
+	}{
+		"nginx":                          {"nginx", ""},
+		"nginx:1.25":                     {"nginx", "1.25"},
+		"registry.local:5000/app":        {"registry.local:5000/app", ""},
+		"registry.local:5000/app:v2":     {"registry.local:5000/app", "v2"},
+		"app@sha256:0123456789abcdef":    {"app", ""},
+		"app:v3@sha256:0123456789abcdef": {"app", "v3"},
+	}
+	for ref, want := range tests {
+		name, tag := splitImageReference(ref)
+		assert.Equal(t, want.name, name, ref)
+		assert.Equal(t, want.tag, tag, ref)
+	}
+}
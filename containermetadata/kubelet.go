@@ -0,0 +1,290 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package containermetadata // import "go.opentelemetry.io/ebpf-profiler/containermetadata"
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+)
+
+// Assert that we implement the ContainerMetadataProvider interface.
+var _ samples.ContainerMetadataProvider = (*Kubelet)(nil)
+
+const (
+	// kubeletCacheLifetime is the maximum age of the pod list before it is
+	// fetched again.
+	kubeletCacheLifetime = 5 * time.Minute
+
+	// kubeletMinRefreshInterval limits how often the pod list is fetched when
+	// unknown containers show up.
+	kubeletMinRefreshInterval = 10 * time.Second
+
+	// kubeletRequestTimeout bounds the duration of a single pod list request.
+	kubeletRequestTimeout = 5 * time.Second
+
+	// podLabelPrefix is the prefix of the attributes holding the pod labels.
+	podLabelPrefix = "k8s.pod.label."
+)
+
+// KubeletConfig configures the access to the kubelet API.
+type KubeletConfig struct {
+	// URL is the base URL of the kubelet API, e.g. https://10.0.0.1:10250.
+	URL string
+	// TokenFile holds the bearer token to authenticate against the kubelet.
+	// It is read again on every request, to pick up rotated tokens.
+	TokenFile string
+	// CAFile holds the CA certificates to verify the kubelet certificate. If
+	// empty, the certificate is not verified.
+	CAFile string
+}
+
+// Kubelet resolves Kubernetes pod metadata for containers from the pod list
+// of the local kubelet. The pod list is fetched in the background, lookups are
+// only served from the cached list and never wait for the kubelet.
+type Kubelet struct {
+	cfg    KubeletConfig
+	client *http.Client
+
+	mu sync.Mutex
+	// containers maps container IDs to their metadata.
+	containers map[string][]attribute.KeyValue
+	// pods maps pod UIDs to their metadata, for containers that are not (yet)
+	// listed in the pod status.
+	pods map[string][]attribute.KeyValue
+	// fetched is the time the pod list was successfully fetched.
+	fetched time.Time
+	// attempted is the time the pod list was last requested.
+	attempted time.Time
+	// refreshing is set while the pod list is fetched in the background.
+	refreshing bool
+}
+
+// NewKubelet returns a new instance of Kubelet.
+func NewKubelet(cfg KubeletConfig) (*Kubelet, error) {
+	tlsConfig := &tls.Config{
+		//nolint:gosec
+		InsecureSkipVerify: cfg.CAFile == "",
+	}
+	if cfg.CAFile != "" {
+		caCert, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubelet CA: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &Kubelet{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   kubeletRequestTimeout,
+		},
+		containers: make(map[string][]attribute.KeyValue),
+		pods:       make(map[string][]attribute.KeyValue),
+	}, nil
+}
+
+// ContainerMetadata returns the k8s.* attributes of the pod and container with
+// the given cgroupv2 path.
+func (k *Kubelet) ContainerMetadata(cgroupPath string) []attribute.KeyValue {
+	containerID := ContainerIDFromCgroup(cgroupPath)
+	podUID := PodUIDFromCgroup(cgroupPath)
+	if containerID == "" && podUID == "" {
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	attrs, known := k.lookup(containerID, podUID)
+	now := time.Now()
+	if !k.refreshing && (!known || now.Sub(k.fetched) > kubeletCacheLifetime) &&
+		now.Sub(k.attempted) > kubeletMinRefreshInterval {
+		k.attempted = now
+		k.refreshing = true
+		go k.refresh()
+	}
+	return attrs
+}
+
+// lookup returns the cached metadata for the container, or for its pod if the
+// container is unknown. The caller must hold k.mu.
+func (k *Kubelet) lookup(containerID, podUID string) ([]attribute.KeyValue, bool) {
+	if attrs, known := k.containers[containerID]; known {
+		return attrs, true
+	}
+	attrs, known := k.pods[podUID]
+	return attrs, known
+}
+
+// Subset of the pod list returned by the kubelet /pods endpoint.
+type kubeletPodList struct {
+	Items []kubeletPod `json:"items"`
+}
+
+type kubeletPod struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		UID             string            `json:"uid"`
+		Labels          map[string]string `json:"labels"`
+		OwnerReferences []struct {
+			Kind       string `json:"kind"`
+			Name       string `json:"name"`
+			Controller bool   `json:"controller"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Status struct {
+		ContainerStatuses          []kubeletContainerStatus `json:"containerStatuses"`
+		InitContainerStatuses      []kubeletContainerStatus `json:"initContainerStatuses"`
+		EphemeralContainerStatuses []kubeletContainerStatus `json:"ephemeralContainerStatuses"`
+	} `json:"status"`
+}
+
+type kubeletContainerStatus struct {
+	Name string `json:"name"`
+	// ContainerID has the format <runtime>://<id>.
+	ContainerID string `json:"containerID"`
+}
+
+// refresh fetches the pod list from the kubelet and replaces the cached
+// container metadata. The cache is kept if fetching fails.
+func (k *Kubelet) refresh() {
+	containers, pods, err := k.fetchPods()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.refreshing = false
+	if err != nil {
+		log.Warnf("Failed to fetch pods from kubelet: %v", err)
+		return
+	}
+	k.containers = containers
+	k.pods = pods
+	k.fetched = time.Now()
+}
+
+// fetchPods requests the pod list from the kubelet and returns the metadata by
+// container ID and by pod UID.
+func (k *Kubelet) fetchPods() (containers, podsByUID map[string][]attribute.KeyValue,
+	err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kubeletRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(k.cfg.URL, "/")+"/pods", http.NoBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	if k.cfg.TokenFile != "" {
+		token, err := os.ReadFile(k.cfg.TokenFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var pods kubeletPodList
+	if err = json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode pod list: %v", err)
+	}
+	if pods.Items == nil {
+		return nil, nil, errors.New("no pod list in response")
+	}
+
+	containers = make(map[string][]attribute.KeyValue)
+	podsByUID = make(map[string][]attribute.KeyValue, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		podAttrs := podAttributes(pod)
+		podsByUID[pod.Metadata.UID] = podAttrs
+		for _, statuses := range [][]kubeletContainerStatus{
+			pod.Status.ContainerStatuses,
+			pod.Status.InitContainerStatuses,
+			pod.Status.EphemeralContainerStatuses,
+		} {
+			for _, status := range statuses {
+				_, containerID, found := strings.Cut(status.ContainerID, "://")
+				if !found || containerID == "" {
+					continue
+				}
+				attrs := make([]attribute.KeyValue, 0, len(podAttrs)+1)
+				attrs = append(attrs, podAttrs...)
+				attrs = append(attrs, semconv.K8SContainerName(status.Name))
+				containers[containerID] = attrs
+			}
+		}
+	}
+	return containers, podsByUID, nil
+}
+
+// podAttributes returns the attributes that describe pod and its workload.
+func podAttributes(pod *kubeletPod) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.K8SNamespaceName(pod.Metadata.Namespace),
+		semconv.K8SPodName(pod.Metadata.Name),
+		semconv.K8SPodUID(pod.Metadata.UID),
+	}
+
+	for _, owner := range pod.Metadata.OwnerReferences {
+		if !owner.Controller {
+			continue
+		}
+		switch owner.Kind {
+		case "ReplicaSet":
+			attrs = append(attrs, semconv.K8SReplicaSetName(owner.Name))
+			// Deployments name their ReplicaSets <deployment>-<pod-template-hash>.
+			if hash := pod.Metadata.Labels["pod-template-hash"]; hash != "" {
+				if deployment, found := strings.CutSuffix(owner.Name, "-"+hash); found {
+					attrs = append(attrs, semconv.K8SDeploymentName(deployment))
+				}
+			}
+		case "StatefulSet":
+			attrs = append(attrs, semconv.K8SStatefulSetName(owner.Name))
+		case "DaemonSet":
+			attrs = append(attrs, semconv.K8SDaemonSetName(owner.Name))
+		case "Job":
+			attrs = append(attrs, semconv.K8SJobName(owner.Name))
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(pod.Metadata.Labels)) {
+		attrs = append(attrs, attribute.String(podLabelPrefix+key, pod.Metadata.Labels[key]))
+	}
+
+	return attrs
+}
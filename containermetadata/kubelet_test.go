@@ -0,0 +1,131 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package containermetadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const testPodList = `{
+  "kind": "PodList",
+  "items": [
+    {
+      "metadata": {
+        "name": "web-7d9f8b6c5-x2x4z",
+        "namespace": "shop",
+        "uid": "9b2a7f04-3c1e-4d5a-8b6f-0e1d2c3b4a59",
+        "labels": {"app": "web", "pod-template-hash": "7d9f8b6c5"},
+        "ownerReferences": [
+          {"kind": "ReplicaSet", "name": "web-7d9f8b6c5", "controller": true}
+        ]
+      },
+      "status": {
+        "containerStatuses": [
+          {"name": "nginx", "containerID": "containerd://` + testContainerID + `"}
+        ]
+      }
+    }
+  ]
+}`
+
+// newFakeKubelet starts a fake kubelet serving testPodList and counts the requests.
+func newFakeKubelet(t *testing.T, token string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/pods" || r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(testPodList))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestKubelet(t *testing.T) {
+	srv, requests := newFakeKubelet(t, "secret")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0o600))
+
+	k, err := NewKubelet(KubeletConfig{URL: srv.URL, TokenFile: tokenFile})
+	require.NoError(t, err)
+
+	podAttrs := []attribute.KeyValue{
+		attribute.String("k8s.namespace.name", "shop"),
+		attribute.String("k8s.pod.name", "web-7d9f8b6c5-x2x4z"),
+		attribute.String("k8s.pod.uid", "9b2a7f04-3c1e-4d5a-8b6f-0e1d2c3b4a59"),
+		attribute.String("k8s.replicaset.name", "web-7d9f8b6c5"),
+		attribute.String("k8s.deployment.name", "web"),
+		attribute.String("k8s.pod.label.app", "web"),
+		attribute.String("k8s.pod.label.pod-template-hash", "7d9f8b6c5"),
+	}
+
+	cgroupPath := "/kubepods.slice/kubepods-besteffort.slice/" +
+		"kubepods-besteffort-pod9b2a7f04_3c1e_4d5a_8b6f_0e1d2c3b4a59.slice/" +
+		"cri-containerd-" + testContainerID + ".scope"
+
+	// The first lookup does not wait for the pod list.
+	assert.Nil(t, k.ContainerMetadata(cgroupPath))
+	waitRefreshed(t, k)
+	assert.Equal(t, int32(1), requests.Load())
+
+	attrs := k.ContainerMetadata(cgroupPath)
+	assert.Equal(t, append(podAttrs, attribute.String("k8s.container.name", "nginx")), attrs)
+
+	// Containers not listed in the pod status resolve to their pod.
+	attrs = k.ContainerMetadata("/kubepods/besteffort/" +
+		"pod9b2a7f04-3c1e-4d5a-8b6f-0e1d2c3b4a59/" +
+		"0000000000000000000000000000000000000000000000000000000000000000")
+	assert.Equal(t, podAttrs, attrs)
+
+	// Unknown containers do not trigger another request right away.
+	assert.Nil(t, k.ContainerMetadata("/docker-"+
+		"1111111111111111111111111111111111111111111111111111111111111111.scope"))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Paths that do not belong to a container are not looked up.
+	assert.Nil(t, k.ContainerMetadata("/system.slice/sshd.service"))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Unknown containers trigger a refresh once the minimum interval passed.
+	k.mu.Lock()
+	k.attempted = time.Now().Add(-kubeletMinRefreshInterval - time.Second)
+	k.mu.Unlock()
+	assert.Nil(t, k.ContainerMetadata("/docker-"+
+		"1111111111111111111111111111111111111111111111111111111111111111.scope"))
+	waitRefreshed(t, k)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+// waitRefreshed waits for the background refresh of the pod list to finish.
+func waitRefreshed(t *testing.T, k *Kubelet) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		return !k.refreshing
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestKubeletUnauthorized(t *testing.T) {
+	srv, requests := newFakeKubelet(t, "secret")
+
+	k, err := NewKubelet(KubeletConfig{URL: srv.URL})
+	require.NoError(t, err)
+	assert.Nil(t, k.ContainerMetadata("/docker/"+testContainerID))
+	waitRefreshed(t, k)
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Nil(t, k.ContainerMetadata("/docker/"+testContainerID))
+}
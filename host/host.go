@@ -7,6 +7,7 @@ package host // import "go.opentelemetry.io/ebpf-profiler/host"
 import (
 	"encoding/binary"
 	"fmt"
+	"time"
 
 	"go.opentelemetry.io/ebpf-profiler/libpf"
 	"go.opentelemetry.io/ebpf-profiler/times"
@@ -61,4 +62,12 @@ type Trace struct {
 	APMTransactionID libpf.APMTransactionID
 	CPU              int
 	EnvVars          map[string]string
+	CommandLine      string
+	ParentPID        libpf.PID
+	StartTime        time.Time
+	Ancestors        []string
+	Wakeup           libpf.WakeupLink
+	TaskState        string // State of an off-CPU task when it was scheduled out.
+	BlockingReason   string // Reason an off-CPU task blocked, derived from the kernel stack.
+	SamplesPerSecond int    // Sampling frequency an on-CPU sample was collected with.
 }
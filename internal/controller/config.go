@@ -4,41 +4,82 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"go.opentelemetry.io/ebpf-profiler/libpf"
 	"go.opentelemetry.io/ebpf-profiler/reporter"
 	"go.opentelemetry.io/ebpf-profiler/support"
+	"go.opentelemetry.io/ebpf-profiler/tracehandler"
 	"go.opentelemetry.io/ebpf-profiler/tracer"
 )
 
 type Config struct {
 	BpfVerifierLogLevel    uint
+	Cgroups                string
+	CmdlineRedact          string
 	CollAgentAddr          string
+	CollAgentCompression   string
+	CollAgentHeaders       string
+	CollAgentProtocol      string
 	Copyright              bool
 	DisableTLS             bool
+	DockerSocket           string
 	MapScaleFactor         uint
 	MonitorInterval        time.Duration
 	ClockSyncInterval      time.Duration
+	FilterExclude          string
+	FilterInclude          string
+	KernelEvents           string
 	NoKernelVersionCheck   bool
 	PprofAddr              string
+	Probes                 string
 	ProbabilisticInterval  time.Duration
 	ProbabilisticThreshold uint
+	ProcessAncestors       bool
+	ProcessCmdline         bool
+	PythonAsyncio          bool
+	PythonGreenlet         bool
+	PythonQualNames        bool
 	ReporterInterval       time.Duration
 	SamplesPerSecond       int
+	MaxSamplesPerSecond    int
 	SendErrorFrames        bool
+	SoftwareEvents         string
 	Tracers                string
 	VerboseMode            bool
 	Version                bool
 	OffCPUThreshold        uint
+	OffCPUMinDuration      time.Duration
+	OffCPUPIDs             string
+	OffCPUWakeups          bool
+	PIDs                   string
+	FollowChildren         bool
+	FoldedOutput           string
+	FoldedGroupBy          string
+	PprofOutputDir         string
+	PprofMaxBytes          uint64
+	PprofMaxAge            time.Duration
+	Duration               time.Duration
+	Output                 string
+	SpoolDir               string
+	SpoolMaxBytes          uint64
+	SpoolMaxAge            time.Duration
 
 	Reporter reporter.Reporter
 
 	Fs *flag.FlagSet
 
 	IncludeEnvVars string
+
+	KubeletURL       string
+	KubeletTokenFile string
+	KubeletCAFile    string
 }
 
 const (
@@ -55,6 +96,80 @@ func (cfg *Config) Dump() {
 	})
 }
 
+// CollectionAgents returns the addresses of the collection agents from the
+// comma separated CollAgentAddr.
+func (cfg *Config) CollectionAgents() []string {
+	var addrs []string
+	for _, addr := range strings.Split(cfg.CollAgentAddr, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// CollectionAgentHeaders parses the comma separated key=value pairs of
+// CollAgentHeaders.
+func (cfg *Config) CollectionAgentHeaders() (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, header := range strings.Split(cfg.CollAgentHeaders, ",") {
+		if header = strings.TrimSpace(header); header == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(header, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid collection agent header %q", header)
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// CmdlineRedactPatterns compiles the comma separated regular expressions of
+// CmdlineRedact.
+func (cfg *Config) CmdlineRedactPatterns() ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, pattern := range strings.Split(cfg.CmdlineRedact, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid command line redaction pattern: %v", err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// OffCPUPIDList parses the comma separated PIDs of OffCPUPIDs.
+func (cfg *Config) OffCPUPIDList() ([]libpf.PID, error) {
+	return parsePIDList(cfg.OffCPUPIDs, "off-cpu PID")
+}
+
+// PIDList parses the comma separated PIDs of PIDs.
+func (cfg *Config) PIDList() ([]libpf.PID, error) {
+	return parsePIDList(cfg.PIDs, "PID")
+}
+
+// parsePIDList parses a comma separated list of PIDs. The kind is used in
+// error messages.
+func parsePIDList(list, kind string) ([]libpf.PID, error) {
+	var pids []libpf.PID
+	for _, pid := range strings.Split(list, ",") {
+		if pid = strings.TrimSpace(pid); pid == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(pid, 10, 32)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid %s %q", kind, pid)
+		}
+		pids = append(pids, libpf.PID(n))
+	}
+	return pids, nil
+}
+
 // Validate runs validations on the provided configuration, and returns errors
 // if invalid values were provided.
 func (cfg *Config) Validate() error {
@@ -62,6 +177,10 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("invalid sampling frequency: %d", cfg.SamplesPerSecond)
 	}
 
+	if cfg.MaxSamplesPerSecond < 0 {
+		return fmt.Errorf("invalid maximum sampling frequency: %d", cfg.MaxSamplesPerSecond)
+	}
+
 	if cfg.MapScaleFactor > 8 {
 		return fmt.Errorf(
 			"eBPF map scaling factor %d exceeds limit (max: %d)",
@@ -69,6 +188,46 @@ func (cfg *Config) Validate() error {
 		)
 	}
 
+	if _, err := tracehandler.NewFilter(cfg.FilterInclude, cfg.FilterExclude); err != nil {
+		return err
+	}
+
+	if _, err := tracer.ParseProbeSpecs(cfg.Probes); err != nil {
+		return err
+	}
+
+	kernelEvents, err := tracer.ParseKernelEventSpecs(cfg.KernelEvents)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tracer.ParseSoftwareEventSpecs(cfg.SoftwareEvents); err != nil {
+		return err
+	}
+
+	if _, err := cfg.CmdlineRedactPatterns(); err != nil {
+		return err
+	}
+	if cfg.CmdlineRedact != "" && !cfg.ProcessCmdline {
+		return errors.New("command line redaction requires process-cmdline")
+	}
+
+	switch cfg.CollAgentProtocol {
+	case "", reporter.ProtocolGRPC, reporter.ProtocolHTTPProtobuf, reporter.ProtocolHTTPJSON:
+	default:
+		return fmt.Errorf("invalid collection agent protocol: %s", cfg.CollAgentProtocol)
+	}
+
+	switch cfg.CollAgentCompression {
+	case "", reporter.CompressionGzip:
+	default:
+		return fmt.Errorf("invalid collection agent compression: %s", cfg.CollAgentCompression)
+	}
+
+	if _, err := cfg.CollectionAgentHeaders(); err != nil {
+		return err
+	}
+
 	if cfg.BpfVerifierLogLevel > 2 {
 		return fmt.Errorf("invalid eBPF verifier log level: %d", cfg.BpfVerifierLogLevel)
 	}
@@ -97,6 +256,38 @@ func (cfg *Config) Validate() error {
 		)
 	}
 
+	if cfg.OffCPUMinDuration < 0 {
+		return errors.New("invalid argument for off-cpu-min-duration: must not be negative")
+	}
+
+	if cfg.OffCPUThreshold > 0 && cfg.OffCPUMinDuration > 0 {
+		return errors.New("off-cpu-threshold and off-cpu-min-duration are mutually exclusive")
+	}
+
+	offCPUEnabled := cfg.OffCPUThreshold > 0 || cfg.OffCPUMinDuration > 0
+
+	if cfg.OffCPUWakeups && !offCPUEnabled {
+		return errors.New("off-cpu-wakeups requires off-cpu profiling to be enabled " +
+			"with off-cpu-threshold or off-cpu-min-duration")
+	}
+
+	if pids, err := cfg.OffCPUPIDList(); err != nil {
+		return err
+	} else if len(pids) > 0 && !offCPUEnabled {
+		return errors.New("off-cpu-pids requires off-cpu profiling to be enabled " +
+			"with off-cpu-threshold or off-cpu-min-duration")
+	}
+
+	if cfg.Duration < 0 {
+		return fmt.Errorf("invalid duration: %v", cfg.Duration)
+	}
+
+	if pids, err := cfg.PIDList(); err != nil {
+		return err
+	} else if len(pids) == 0 && cfg.FollowChildren {
+		return errors.New("follow-children requires pid")
+	}
+
 	if !cfg.NoKernelVersionCheck {
 		major, minor, patch, err := tracer.GetCurrentKernelVersion()
 		if err != nil {
@@ -123,6 +314,12 @@ func (cfg *Config) Validate() error {
 			return fmt.Errorf("host Agent requires kernel version "+
 				"%d.%d or newer but got %d.%d.%d", minMajor, minMinor, major, minor, patch)
 		}
+
+		// Kernel events pass their origin to the eBPF programs with the attach cookie.
+		if len(kernelEvents) > 0 && (major < 5 || (major == 5 && minor < 15)) {
+			return fmt.Errorf("kernel-events requires kernel version "+
+				"5.15 or newer but got %d.%d.%d", major, minor, patch)
+		}
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package controller // import "go.opentelemetry.io/ebpf-profiler/internal/control
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/tklauser/numcpus"
 
 	"go.opentelemetry.io/ebpf-profiler/host"
+	"go.opentelemetry.io/ebpf-profiler/interpreter/python"
 	"go.opentelemetry.io/ebpf-profiler/libpf"
 	"go.opentelemetry.io/ebpf-profiler/metrics"
 	"go.opentelemetry.io/ebpf-profiler/reporter"
@@ -27,6 +29,11 @@ type Controller struct {
 	config   *Config
 	reporter reporter.Reporter
 	tracer   *tracer.Tracer
+
+	// stopTraceHandling stops the map monitors and the trace handler.
+	stopTraceHandling context.CancelFunc
+	// traceHandlerExited is closed once the trace handler exited.
+	traceHandlerExited <-chan libpf.Void
 }
 
 // New creates a new controller
@@ -67,12 +74,24 @@ func (c *Controller) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse the included tracers: %w", err)
 	}
+	if (c.config.PythonAsyncio || c.config.PythonQualNames || c.config.PythonGreenlet) &&
+		!includeTracers.Has(tracertypes.PythonTracer) {
+		log.Warn("The Python options have no effect without the python tracer")
+	}
 
 	err = c.reporter.Start(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to start reporter: %w", err)
 	}
 
+	var cgroups []string
+	for _, cgroup := range strings.Split(c.config.Cgroups, ",") {
+		cgroup = strings.TrimSpace(cgroup)
+		if cgroup != "" {
+			cgroups = append(cgroups, cgroup)
+		}
+	}
+
 	envVars := libpf.Set[string]{}
 	splittedEnvVars := strings.Split(c.config.IncludeEnvVars, ",")
 	for _, envVar := range splittedEnvVars {
@@ -82,13 +101,51 @@ func (c *Controller) Start(ctx context.Context) error {
 		}
 	}
 
+	cmdlineRedact, err := c.config.CmdlineRedactPatterns()
+	if err != nil {
+		return err
+	}
+
+	probes, err := tracer.ParseProbeSpecs(c.config.Probes)
+	if err != nil {
+		return err
+	}
+
+	kernelEvents, err := tracer.ParseKernelEventSpecs(c.config.KernelEvents)
+	if err != nil {
+		return err
+	}
+
+	softwareEvents, err := tracer.ParseSoftwareEventSpecs(c.config.SoftwareEvents)
+	if err != nil {
+		return err
+	}
+
+	offCPUPIDs, err := c.config.OffCPUPIDList()
+	if err != nil {
+		return err
+	}
+
+	pids, err := c.config.PIDList()
+	if err != nil {
+		return err
+	}
+
+	pythonOptions := python.Options{
+		Asyncio:        c.config.PythonAsyncio,
+		QualifiedNames: c.config.PythonQualNames,
+		Greenlet:       c.config.PythonGreenlet,
+	}
+
 	// Load the eBPF code and map definitions
 	trc, err := tracer.NewTracer(ctx, &tracer.Config{
 		Reporter:               c.reporter,
 		Intervals:              intervals,
 		IncludeTracers:         includeTracers,
+		PythonOptions:          pythonOptions,
 		FilterErrorFrames:      !c.config.SendErrorFrames,
 		SamplesPerSecond:       c.config.SamplesPerSecond,
+		MaxSamplesPerSecond:    c.config.MaxSamplesPerSecond,
 		MapScaleFactor:         int(c.config.MapScaleFactor),
 		KernelVersionCheck:     !c.config.NoKernelVersionCheck,
 		DebugTracer:            c.config.VerboseMode,
@@ -96,7 +153,19 @@ func (c *Controller) Start(ctx context.Context) error {
 		ProbabilisticInterval:  c.config.ProbabilisticInterval,
 		ProbabilisticThreshold: c.config.ProbabilisticThreshold,
 		OffCPUThreshold:        uint32(c.config.OffCPUThreshold),
+		OffCPUMinDuration:      c.config.OffCPUMinDuration,
+		OffCPUPIDs:             offCPUPIDs,
+		OffCPUWakeups:          c.config.OffCPUWakeups,
+		PIDs:                   pids,
+		FollowChildren:         c.config.FollowChildren,
 		IncludeEnvVars:         envVars,
+		IncludeCmdline:         c.config.ProcessCmdline,
+		CmdlineRedact:          cmdlineRedact,
+		IncludeAncestors:       c.config.ProcessAncestors,
+		Cgroups:                cgroups,
+		Probes:                 probes,
+		KernelEvents:           kernelEvents,
+		SoftwareEvents:         softwareEvents,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to load eBPF tracer: %w", err)
@@ -117,13 +186,27 @@ func (c *Controller) Start(ctx context.Context) error {
 	}
 	log.Info("Attached tracer program")
 
-	if c.config.OffCPUThreshold > 0 {
+	if c.config.OffCPUThreshold > 0 || c.config.OffCPUMinDuration > 0 {
 		if err := trc.StartOffCPUProfiling(); err != nil {
 			return fmt.Errorf("failed to start off-cpu profiling: %v", err)
 		}
 		log.Printf("Enabled off-cpu profiling")
 	}
 
+	if len(probes) > 0 {
+		if err := trc.StartProbeProfiling(); err != nil {
+			return fmt.Errorf("failed to start probe profiling: %v", err)
+		}
+		log.Printf("Enabled probe profiling")
+	}
+
+	if len(kernelEvents) > 0 {
+		if err := trc.StartKernelEventProfiling(); err != nil {
+			return fmt.Errorf("failed to start kernel event profiling: %v", err)
+		}
+		log.Printf("Enabled kernel event profiling")
+	}
+
 	if c.config.ProbabilisticThreshold < tracer.ProbabilisticThresholdMax {
 		trc.StartProbabilisticProfiling(ctx)
 		log.Printf("Enabled probabilistic profiling")
@@ -141,38 +224,75 @@ func (c *Controller) Start(ctx context.Context) error {
 	// change this log line update also the system test.
 	log.Printf("Attached sched monitor")
 
-	if err := startTraceHandling(ctx, c.reporter, intervals, trc,
-		traceHandlerCacheSize); err != nil {
+	filter, err := tracehandler.NewFilter(c.config.FilterInclude, c.config.FilterExclude)
+	if err != nil {
+		return fmt.Errorf("failed to parse the trace filter: %w", err)
+	}
+
+	// Trace handling is stopped separately on shutdown, so that the traces
+	// still in flight reach the reporter before it is stopped.
+	handlingCtx, stopTraceHandling := context.WithCancel(ctx)
+	exited, err := startTraceHandling(handlingCtx, c.reporter, intervals, trc,
+		traceHandlerCacheSize, filter)
+	if err != nil {
+		stopTraceHandling()
 		return fmt.Errorf("failed to start trace handling: %w", err)
 	}
+	c.stopTraceHandling = stopTraceHandling
+	c.traceHandlerExited = exited
 
 	return nil
 }
 
-// Shutdown stops the controller
-func (c *Controller) Shutdown() {
-	log.Info("Stop processing ...")
-	if c.reporter != nil {
-		c.reporter.Stop()
+// SetSamplingFrequency changes the number of on-CPU samples per second while
+// the controller is running, e.g. to temporarily raise it during an incident.
+func (c *Controller) SetSamplingFrequency(samplesPerSecond int) error {
+	if c.tracer == nil {
+		return errors.New("controller is not started")
 	}
+	return c.tracer.SetSamplingFrequency(samplesPerSecond)
+}
+
+// TargetsExited returns a channel that is closed once all processes profiling
+// is restricted to with the pid option have exited. The channel is never closed
+// if profiling is not restricted to specific processes.
+func (c *Controller) TargetsExited() <-chan libpf.Void {
+	if c.tracer == nil {
+		return nil
+	}
+	return c.tracer.TargetsExited()
+}
 
+// Shutdown stops the tracer, waits for the trace handler to exit and then stops
+// the reporter, so that it can send out the trace events collected so far.
+func (c *Controller) Shutdown() {
+	log.Info("Stop processing ...")
 	if c.tracer != nil {
 		c.tracer.Close()
 	}
+
+	if c.stopTraceHandling != nil {
+		c.stopTraceHandling()
+		<-c.traceHandlerExited
+	}
+
+	if c.reporter != nil {
+		c.reporter.Stop()
+	}
 }
 
 func startTraceHandling(ctx context.Context, rep reporter.TraceReporter,
-	intervals *times.Times, trc *tracer.Tracer, cacheSize uint32) error {
+	intervals *times.Times, trc *tracer.Tracer, cacheSize uint32,
+	filter *tracehandler.Filter) (<-chan libpf.Void, error) {
 	// Spawn monitors for the various result maps
 	traceCh := make(chan *host.Trace)
 
 	if err := trc.StartMapMonitors(ctx, traceCh); err != nil {
-		return fmt.Errorf("failed to start map monitors: %v", err)
+		return nil, fmt.Errorf("failed to start map monitors: %v", err)
 	}
 
-	_, err := tracehandler.Start(ctx, rep, trc.TraceProcessor(),
-		traceCh, intervals, cacheSize)
-	return err
+	return tracehandler.Start(ctx, rep, trc.TraceProcessor(),
+		traceCh, intervals, cacheSize, tracehandler.WithFilter(filter))
 }
 
 // traceCacheSize defines the maximum number of elements for the caches in tracehandler.
@@ -0,0 +1,348 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package perfmap symbolizes the code of JIT runtimes without a dedicated
+// interpreter package, e.g. LuaJIT, the Erlang BEAM JIT, Julia, Mono or Node
+// started with --perf-basic-prof. These runtimes describe their generated code
+// in /tmp/perf-PID.map or jit-PID.dump files for the Linux perf tool.
+//
+// The parts of the anonymous executable mappings covered by the functions in
+// these files are registered for the native unwinder, which follows the frame
+// pointer chain through them. The resulting native frames are symbolized with
+// the function names from the files, which are read incrementally in the
+// background as the runtime adds code.
+package perfmap // import "go.opentelemetry.io/ebpf-profiler/interpreter/perfmap"
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/host"
+	"go.opentelemetry.io/ebpf-profiler/interpreter"
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/lpm"
+	"go.opentelemetry.io/ebpf-profiler/metrics"
+	"go.opentelemetry.io/ebpf-profiler/proc"
+	"go.opentelemetry.io/ebpf-profiler/process"
+	"go.opentelemetry.io/ebpf-profiler/remotememory"
+	"go.opentelemetry.io/ebpf-profiler/reporter"
+	"go.opentelemetry.io/ebpf-profiler/successfailurecounter"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+// refreshInterval is the minimum time between reading the perf map and jitdump
+// files again to resolve an unknown address.
+const refreshInterval = time.Second
+
+var (
+	// compiler check to make sure the needed interfaces are satisfied
+	_ interpreter.Data     = &perfMapData{}
+	_ interpreter.Instance = &perfMapInstance{}
+)
+
+type perfMapData struct{}
+
+type perfMapInstance struct {
+	interpreter.InstanceStubs
+
+	// JIT symbolization metrics
+	successCount atomic.Uint64
+	failCount    atomic.Uint64
+
+	// nspid is the PID of the process in its PID namespace, which is used in
+	// the names of the perf map and jitdump files.
+	nspid libpf.PID
+
+	// uid is the user ID of the process, which must own the files.
+	uid uint32
+
+	// refreshing is set while the files are read in the background.
+	refreshing atomic.Bool
+
+	// mu protects the fields below. The readers are only used by the
+	// background refresh once they are set.
+	mu sync.Mutex
+
+	// perfMap and jitDump read the files describing the JIT code, if found.
+	perfMap *perfMapReader
+	jitDump *jitDumpReader
+
+	// symbols holds the JIT functions read so far.
+	symbols symbolTable
+
+	// lastRefresh is the time the files were last read.
+	lastRefresh time.Time
+
+	// prefixes holds the parts of the anonymous executable mappings that are
+	// registered for frame pointer unwinding.
+	prefixes libpf.Set[lpm.Prefix]
+}
+
+// Loader matches the main executable of processes. Whether a process writes
+// perf map or jitdump files is only known once it runs, so the files are
+// looked for on every synchronization of its mappings. Runtimes with a
+// dedicated interpreter package, e.g. Node, are matched by its loader first.
+func Loader(_ interpreter.EbpfHandler, info *interpreter.LoaderInfo) (
+	interpreter.Data, error) {
+	ef, err := info.GetELF()
+	if err != nil {
+		return nil, err
+	}
+	if ef.Type == elf.ET_EXEC {
+		return &perfMapData{}, nil
+	}
+	for i := range ef.Progs {
+		if ef.Progs[i].Type == elf.PT_INTERP {
+			return &perfMapData{}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *perfMapData) String() string {
+	return "perfmap"
+}
+
+func (d *perfMapData) Attach(_ interpreter.EbpfHandler, pid libpf.PID,
+	_ libpf.Address, _ remotememory.RemoteMemory) (interpreter.Instance, error) {
+	nspid, err := proc.GetNamespacedPID(pid)
+	if err != nil {
+		return nil, err
+	}
+	var stat unix.Stat_t
+	if err = unix.Stat(fmt.Sprintf("/proc/%d", pid), &stat); err != nil {
+		return nil, fmt.Errorf("failed to get UID of PID %d: %v", pid, err)
+	}
+	return &perfMapInstance{
+		nspid:    nspid,
+		uid:      stat.Uid,
+		prefixes: make(libpf.Set[lpm.Prefix]),
+	}, nil
+}
+
+func (d *perfMapData) Unload(_ interpreter.EbpfHandler) {}
+
+func (i *perfMapInstance) Detach(ebpf interpreter.EbpfHandler, pid libpf.PID) error {
+	var err error
+	for prefix := range i.prefixes {
+		if err2 := ebpf.DeletePidInterpreterMapping(pid, prefix); err2 != nil {
+			err = errors.Join(err,
+				fmt.Errorf("failed to remove page 0x%x/%d: %v",
+					prefix.Key, prefix.Length, err2))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to detach perfMapInstance from PID %d: %v",
+			pid, err)
+	}
+	return nil
+}
+
+// findSources looks for the perf map and jitdump files of the process. The
+// caller must hold the lock.
+func (i *perfMapInstance) findSources(pid libpf.PID, mappings []process.Mapping) {
+	if i.perfMap == nil {
+		perfMapPath := fmt.Sprintf("/proc/%d/root/tmp/perf-%d.map", pid, i.nspid)
+		if _, err := os.Lstat(perfMapPath); err == nil {
+			log.Debugf("Found perf map %s for PID %d", perfMapPath, pid)
+			i.perfMap = &perfMapReader{path: perfMapPath, uid: i.uid}
+		}
+	}
+
+	if i.jitDump == nil {
+		// JIT runtimes map the jitdump file into memory so that perf can find it.
+		jitDumpName := fmt.Sprintf("jit-%d.dump", i.nspid)
+		for idx := range mappings {
+			m := &mappings[idx]
+			if m.IsAnonymous() || path.Base(m.Path) != jitDumpName {
+				continue
+			}
+			jitDumpPath := fmt.Sprintf("/proc/%d/root%s", pid, m.Path)
+			log.Debugf("Found jitdump %s for PID %d", jitDumpPath, pid)
+			i.jitDump = &jitDumpReader{path: jitDumpPath, uid: i.uid}
+			break
+		}
+	}
+}
+
+// startRefresh reads the functions added to the perf map and jitdump files in
+// the background, unless this is already in progress. The files are read
+// without holding any lock, so slow reads do not hold up symbolization. The
+// caller must hold the lock.
+func (i *perfMapInstance) startRefresh() {
+	if !i.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	i.lastRefresh = time.Now()
+	perfMap, jitDump := i.perfMap, i.jitDump
+
+	go func() {
+		defer i.refreshing.Store(false)
+
+		var batch symbolBatch
+		if perfMap != nil {
+			if err := perfMap.read(&batch); err != nil {
+				log.Debugf("Failed to read perf map %s: %v", perfMap.path, err)
+			}
+		}
+		if jitDump != nil {
+			if err := jitDump.read(&batch); err != nil {
+				log.Debugf("Failed to read jitdump %s: %v", jitDump.path, err)
+			}
+		}
+
+		i.mu.Lock()
+		i.symbols.apply(batch)
+		i.mu.Unlock()
+	}()
+}
+
+// jitPrefixes returns the prefixes of the parts of the anonymous executable
+// mappings that are covered by the known JIT functions. The caller must hold
+// the lock.
+func (i *perfMapInstance) jitPrefixes(mappings []process.Mapping) (
+	libpf.Set[lpm.Prefix], error) {
+	prefixes := make(libpf.Set[lpm.Prefix])
+	for idx := range mappings {
+		m := &mappings[idx]
+		if !m.IsExecutable() || !m.IsAnonymous() {
+			continue
+		}
+		start, end, ok := i.symbols.span(libpf.Address(m.Vaddr),
+			libpf.Address(m.Vaddr+m.Length))
+		if !ok {
+			// The mapping may hold code of a runtime with a dedicated
+			// interpreter unwinder, or code not described yet.
+			continue
+		}
+		mappingPrefixes, err := lpm.CalculatePrefixList(uint64(start), uint64(end))
+		if err != nil {
+			return nil, fmt.Errorf("JIT code lpm failure %#x/%#x", start, end-start)
+		}
+		for _, prefix := range mappingPrefixes {
+			prefixes[prefix] = libpf.Void{}
+		}
+	}
+	return prefixes, nil
+}
+
+func (i *perfMapInstance) SynchronizeMappings(ebpf interpreter.EbpfHandler,
+	_ reporter.SymbolReporter, pr process.Process, mappings []process.Mapping) error {
+	pid := pr.PID()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.findSources(pid, mappings)
+	if i.perfMap == nil && i.jitDump == nil {
+		return nil
+	}
+	// The functions read by this refresh are registered with the next
+	// synchronization.
+	i.startRefresh()
+
+	prefixes, err := i.jitPrefixes(mappings)
+	if err != nil {
+		return err
+	}
+	for prefix := range prefixes {
+		if _, exists := i.prefixes[prefix]; exists {
+			continue
+		}
+		// The bias of 0 makes the native frames carry the absolute address,
+		// which is what the files describe.
+		if err := ebpf.UpdatePidInterpreterMapping(pid, prefix,
+			support.ProgUnwindNative, support.FramePointerFileID, 0); err != nil {
+			log.Debugf("Failed to register JIT code page 0x%x/%d: %v",
+				prefix.Key, prefix.Length, err)
+			continue
+		}
+		i.prefixes[prefix] = libpf.Void{}
+	}
+
+	// Remove prefixes no longer covered by JIT functions
+	for prefix := range i.prefixes {
+		if _, ok := prefixes[prefix]; ok {
+			continue
+		}
+		_ = ebpf.DeletePidInterpreterMapping(pid, prefix)
+		delete(i.prefixes, prefix)
+	}
+
+	return nil
+}
+
+// lookup returns the name of the JIT function at addr. If addr is not known
+// yet, e.g. because the code was just generated, the files are read again in
+// the background for the next lookups.
+func (i *perfMapInstance) lookup(addr libpf.Address) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	name, ok := i.symbols.lookup(addr)
+	if !ok && time.Since(i.lastRefresh) >= refreshInterval {
+		i.startRefresh()
+	}
+	return name, ok
+}
+
+func (i *perfMapInstance) Symbolize(symbolReporter reporter.SymbolReporter, frame *host.Frame,
+	trace *libpf.Trace) error {
+	if !frame.Type.IsInterpType(libpf.Native) ||
+		frame.File != host.FileID(support.FramePointerFileID) {
+		return interpreter.ErrMismatchInterpreterType
+	}
+	sfCounter := successfailurecounter.New(&i.successCount, &i.failCount)
+	defer sfCounter.DefaultToFailure()
+
+	addr := libpf.Address(frame.Lineno)
+	if frame.ReturnAddress {
+		// Resolve return addresses to the call instruction preceding them.
+		addr--
+	}
+	name, ok := i.lookup(addr)
+	if !ok {
+		return fmt.Errorf("no JIT symbol for 0x%x", addr)
+	}
+
+	// The fnv hash Write() method calls cannot fail, so it's safe to ignore the errors.
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(name))
+	fileID, err := libpf.FileIDFromBytes(h.Sum(nil))
+	if err != nil {
+		return fmt.Errorf("failed to create a file ID: %v", err)
+	}
+
+	frameID := libpf.NewFrameID(fileID, 0)
+	trace.AppendFrameID(libpf.JITFrame, frameID)
+
+	symbolReporter.FrameMetadata(&reporter.FrameMetadataArgs{
+		FrameID:      frameID,
+		FunctionName: name,
+	})
+
+	sfCounter.ReportSuccess()
+	return nil
+}
+
+func (i *perfMapInstance) GetAndResetMetrics() ([]metrics.Metric, error) {
+	return []metrics.Metric{
+		{
+			ID:    metrics.IDJITSymbolizationSuccess,
+			Value: metrics.MetricValue(i.successCount.Swap(0)),
+		},
+		{
+			ID:    metrics.IDJITSymbolizationFailure,
+			Value: metrics.MetricValue(i.failCount.Swap(0)),
+		},
+	}, nil
+}
@@ -0,0 +1,390 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package perfmap // import "go.opentelemetry.io/ebpf-profiler/interpreter/perfmap"
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+)
+
+// maxSymbols limits the number of JIT symbols kept per process.
+const maxSymbols = 1 << 20
+
+// symbol is a region of JIT code and the name of the function it contains.
+type symbol struct {
+	start libpf.Address
+	end   libpf.Address
+	name  string
+}
+
+// symbolTable maps addresses of JIT code to function names.
+type symbolTable struct {
+	symbols []symbol
+	// sorted is false if symbols were added since the last lookup.
+	sorted bool
+}
+
+// add adds the function name for the JIT code of the given size at start.
+func (t *symbolTable) add(start libpf.Address, size uint64, name string) {
+	if size == 0 || len(t.symbols) >= maxSymbols {
+		return
+	}
+	t.symbols = append(t.symbols, symbol{
+		start: start,
+		end:   start + libpf.Address(size),
+		name:  name,
+	})
+	t.sorted = false
+}
+
+// sort orders the symbols by address. Of several symbols at the same address
+// the last added one is kept, as JIT runtimes reuse the memory of freed code.
+func (t *symbolTable) sort() {
+	slices.SortStableFunc(t.symbols, func(a, b symbol) int {
+		switch {
+		case a.start < b.start:
+			return -1
+		case a.start > b.start:
+			return 1
+		}
+		return 0
+	})
+	n := 0
+	for i := range t.symbols {
+		if n > 0 && t.symbols[n-1].start == t.symbols[i].start {
+			n--
+		}
+		t.symbols[n] = t.symbols[i]
+		n++
+	}
+	t.symbols = t.symbols[:n]
+	t.sorted = true
+}
+
+// symbolChange is a function added to or moved within the JIT code.
+type symbolChange struct {
+	symbol
+	// moved is set if the code was moved from oldStart. The name is then
+	// taken from the function at oldStart.
+	moved    bool
+	oldStart libpf.Address
+}
+
+// symbolBatch collects the changes read from the perf map and jitdump files,
+// so that the files are read without holding the lock of the symbol table.
+type symbolBatch []symbolChange
+
+// add records the function name for the JIT code of the given size at start.
+func (b *symbolBatch) add(start libpf.Address, size uint64, name string) {
+	*b = append(*b, symbolChange{
+		symbol: symbol{start: start, end: start + libpf.Address(size), name: name},
+	})
+}
+
+// move records that the JIT code at oldStart was moved to newStart.
+func (b *symbolBatch) move(oldStart, newStart libpf.Address, size uint64) {
+	*b = append(*b, symbolChange{
+		symbol:   symbol{start: newStart, end: newStart + libpf.Address(size)},
+		moved:    true,
+		oldStart: oldStart,
+	})
+}
+
+// apply adds the changes of the batch to the table in the order they were read.
+func (t *symbolTable) apply(b symbolBatch) {
+	for _, change := range b {
+		name := change.name
+		if change.moved {
+			var ok bool
+			if name, ok = t.lookup(change.oldStart); !ok {
+				continue
+			}
+		}
+		t.add(change.start, uint64(change.end-change.start), name)
+	}
+}
+
+// lookup returns the function name of the JIT code at addr.
+func (t *symbolTable) lookup(addr libpf.Address) (string, bool) {
+	if !t.sorted {
+		t.sort()
+	}
+	idx := sort.Search(len(t.symbols), func(i int) bool {
+		return t.symbols[i].start > addr
+	})
+	if idx == 0 {
+		return "", false
+	}
+	sym := &t.symbols[idx-1]
+	if addr >= sym.end {
+		return "", false
+	}
+	return sym.name, true
+}
+
+// span returns the range of [start, end) that is covered by known functions.
+func (t *symbolTable) span(start, end libpf.Address) (spanStart, spanEnd libpf.Address,
+	ok bool) {
+	if !t.sorted {
+		t.sort()
+	}
+	first := sort.Search(len(t.symbols), func(i int) bool {
+		return t.symbols[i].start >= start
+	})
+	last := sort.Search(len(t.symbols), func(i int) bool {
+		return t.symbols[i].start >= end
+	})
+	if first >= last {
+		return 0, 0, false
+	}
+	return t.symbols[first].start, min(t.symbols[last-1].end, end), true
+}
+
+// openFile opens the perf map or jitdump file at path for reading. As the files
+// are written by unprivileged processes, it only opens regular files owned by
+// uid, and does not follow symbolic links or block on FIFOs.
+func openFile(path string, uid uint32) (*os.File, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	var stat unix.Stat_t
+	if err = unix.Fstat(fd, &stat); err != nil {
+		_ = unix.Close(fd)
+		return nil, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFREG {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	if stat.Uid != uid {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("%s is owned by UID %d instead of %d", path, stat.Uid, uid)
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// perfMapReader incrementally reads a perf map file as written by JIT runtimes
+// to /tmp/perf-PID.map. Each line describes one function: "START SIZE NAME",
+// with START and SIZE in hexadecimal.
+type perfMapReader struct {
+	path string
+	// uid is the user ID the file must be owned by.
+	uid uint32
+	// offset is the file offset up to which complete lines were read.
+	offset int64
+}
+
+// read adds the functions written to the perf map since the last call to batch.
+func (r *perfMapReader) read(batch *symbolBatch) error {
+	f, err := openFile(r.path, r.uid)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < r.offset {
+		// The file was truncated and is written anew.
+		r.offset = 0
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(f, r.offset, info.Size()-r.offset))
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			// An incomplete last line is read again once it is complete.
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		r.offset += int64(len(line))
+		if start, size, name, ok := parsePerfMapLine(line); ok {
+			batch.add(start, size, name)
+		}
+	}
+}
+
+// parsePerfMapLine parses one line of a perf map file.
+func parsePerfMapLine(line string) (start libpf.Address, size uint64, name string, ok bool) {
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+	if len(fields) != 3 {
+		return 0, 0, "", false
+	}
+	addr, err := strconv.ParseUint(strings.TrimPrefix(fields[0], "0x"), 16, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+	size, err = strconv.ParseUint(strings.TrimPrefix(fields[1], "0x"), 16, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+	return libpf.Address(addr), size, strings.TrimSpace(fields[2]), true
+}
+
+// The jitdump format is described in tools/perf/Documentation/jitdump-specification.txt
+// of the Linux kernel sources.
+const (
+	// jitDumpMagic is the magic number at the start of jitdump files.
+	jitDumpMagic = 0x4A695444
+	// jitDumpHeaderSize is the size of the fixed part of the file header.
+	jitDumpHeaderSize = 40
+	// jitDumpRecordHeaderSize is the size of the header preceding each record.
+	jitDumpRecordHeaderSize = 16
+	// jitDumpMaxNameLength limits the length of the function names read.
+	jitDumpMaxNameLength = 1024
+
+	jitCodeLoad  = 0
+	jitCodeMove  = 1
+	jitCodeClose = 3
+
+	// jitCodeLoadSize is the size of the fixed part of a JIT_CODE_LOAD record
+	// following the record header: pid, tid, vma, code_addr, code_size, code_index.
+	jitCodeLoadSize = 40
+	// jitCodeMoveSize is the size of a JIT_CODE_MOVE record following the record
+	// header: pid, tid, vma, old_code_addr, new_code_addr, code_size, code_index.
+	jitCodeMoveSize = 48
+)
+
+// jitDumpReader incrementally reads a jitdump file as written by JIT runtimes
+// to jit-PID.dump.
+type jitDumpReader struct {
+	path string
+	// uid is the user ID the file must be owned by.
+	uid uint32
+	// offset is the file offset of the next record, or 0 if the file header
+	// was not read yet.
+	offset int64
+	// byteOrder is the byte order the file was written in.
+	byteOrder binary.ByteOrder
+	// closed is set once the JIT_CODE_CLOSE record was read.
+	closed bool
+}
+
+// read adds the functions loaded or moved since the last call to batch.
+func (r *jitDumpReader) read(batch *symbolBatch) error {
+	if r.closed {
+		return nil
+	}
+
+	f, err := openFile(r.path, r.uid)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	if r.offset == 0 {
+		if size < jitDumpHeaderSize {
+			return nil
+		}
+		var header [jitDumpHeaderSize]byte
+		if _, err = f.ReadAt(header[:], 0); err != nil {
+			return err
+		}
+		switch {
+		case binary.LittleEndian.Uint32(header[0:]) == jitDumpMagic:
+			r.byteOrder = binary.LittleEndian
+		case binary.BigEndian.Uint32(header[0:]) == jitDumpMagic:
+			r.byteOrder = binary.BigEndian
+		default:
+			return fmt.Errorf("invalid jitdump magic in %s", r.path)
+		}
+		headerSize := int64(r.byteOrder.Uint32(header[8:]))
+		if headerSize < jitDumpHeaderSize {
+			return fmt.Errorf("invalid jitdump header size %d in %s", headerSize, r.path)
+		}
+		r.offset = headerSize
+	}
+
+	var recordHeader [jitDumpRecordHeaderSize]byte
+	for r.offset+jitDumpRecordHeaderSize <= size {
+		if _, err = f.ReadAt(recordHeader[:], r.offset); err != nil {
+			return err
+		}
+		id := r.byteOrder.Uint32(recordHeader[0:])
+		recordSize := int64(r.byteOrder.Uint32(recordHeader[4:]))
+		if recordSize < jitDumpRecordHeaderSize {
+			return fmt.Errorf("invalid jitdump record size %d in %s", recordSize, r.path)
+		}
+		if r.offset+recordSize > size {
+			// The record is not completely written yet.
+			return nil
+		}
+
+		body := r.offset + jitDumpRecordHeaderSize
+		bodySize := recordSize - jitDumpRecordHeaderSize
+		switch id {
+		case jitCodeLoad:
+			if err = r.readCodeLoad(f, body, bodySize, batch); err != nil {
+				return err
+			}
+		case jitCodeMove:
+			if err = r.readCodeMove(f, body, bodySize, batch); err != nil {
+				return err
+			}
+		case jitCodeClose:
+			r.closed = true
+			return nil
+		}
+		r.offset += recordSize
+	}
+	return nil
+}
+
+// readCodeLoad reads a JIT_CODE_LOAD record.
+func (r *jitDumpReader) readCodeLoad(f io.ReaderAt, offset, size int64,
+	batch *symbolBatch) error {
+	if size <= jitCodeLoadSize {
+		return fmt.Errorf("invalid JIT_CODE_LOAD record size %d", size)
+	}
+	buf := make([]byte, min(size, jitCodeLoadSize+jitDumpMaxNameLength))
+	if _, err := f.ReadAt(buf, offset); err != nil {
+		return err
+	}
+	codeAddr := r.byteOrder.Uint64(buf[16:])
+	codeSize := r.byteOrder.Uint64(buf[24:])
+	name, _, _ := bytes.Cut(buf[jitCodeLoadSize:], []byte{0})
+	batch.add(libpf.Address(codeAddr), codeSize, string(name))
+	return nil
+}
+
+// readCodeMove reads a JIT_CODE_MOVE record.
+func (r *jitDumpReader) readCodeMove(f io.ReaderAt, offset, size int64,
+	batch *symbolBatch) error {
+	if size < jitCodeMoveSize {
+		return fmt.Errorf("invalid JIT_CODE_MOVE record size %d", size)
+	}
+	var buf [jitCodeMoveSize]byte
+	if _, err := f.ReadAt(buf[:], offset); err != nil {
+		return err
+	}
+	oldCodeAddr := r.byteOrder.Uint64(buf[16:])
+	newCodeAddr := r.byteOrder.Uint64(buf[24:])
+	codeSize := r.byteOrder.Uint64(buf[32:])
+	batch.move(libpf.Address(oldCodeAddr), libpf.Address(newCodeAddr), codeSize)
+	return nil
+}
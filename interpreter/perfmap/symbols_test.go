@@ -0,0 +1,170 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package perfmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+)
+
+func TestSymbolTable(t *testing.T) {
+	var table symbolTable
+	table.add(0x2000, 0x100, "second")
+	table.add(0x1000, 0x100, "first")
+	table.add(0x2000, 0x80, "second-recompiled")
+
+	tests := map[libpf.Address]string{
+		0x0fff: "",
+		0x1000: "first",
+		0x10ff: "first",
+		0x1100: "",
+		0x2000: "second-recompiled",
+		0x207f: "second-recompiled",
+		0x2080: "",
+	}
+	for addr, expected := range tests {
+		name, ok := table.lookup(addr)
+		assert.Equal(t, expected != "", ok, "0x%x", addr)
+		assert.Equal(t, expected, name, "0x%x", addr)
+	}
+
+	start, end, ok := table.span(0x0, 0x10000)
+	require.True(t, ok)
+	assert.Equal(t, libpf.Address(0x1000), start)
+	assert.Equal(t, libpf.Address(0x2080), end)
+	start, end, ok = table.span(0x1800, 0x2040)
+	require.True(t, ok)
+	assert.Equal(t, libpf.Address(0x2000), start)
+	assert.Equal(t, libpf.Address(0x2040), end)
+	_, _, ok = table.span(0x3000, 0x4000)
+	assert.False(t, ok)
+}
+
+func TestOpenFile(t *testing.T) {
+	dir := t.TempDir()
+	uid := uint32(os.Getuid())
+
+	path := filepath.Join(dir, "perf-42.map")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	f, err := openFile(path, uid)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = openFile(path, uid+1)
+	require.Error(t, err, "file of another user")
+
+	link := filepath.Join(dir, "perf-43.map")
+	require.NoError(t, os.Symlink(path, link))
+	_, err = openFile(link, uid)
+	require.Error(t, err, "symbolic link")
+
+	fifo := filepath.Join(dir, "perf-44.map")
+	require.NoError(t, unix.Mkfifo(fifo, 0o644))
+	_, err = openFile(fifo, uid)
+	require.Error(t, err, "FIFO")
+}
+
+func TestParsePerfMapLine(t *testing.T) {
+	start, size, name, ok := parsePerfMapLine("7f1c2a400000 1a0 LazyCompile:*foo /app/x.js:1\n")
+	require.True(t, ok)
+	assert.Equal(t, libpf.Address(0x7f1c2a400000), start)
+	assert.Equal(t, uint64(0x1a0), size)
+	assert.Equal(t, "LazyCompile:*foo /app/x.js:1", name)
+
+	start, size, name, ok = parsePerfMapLine("0x1000 0x20 trace_1\n")
+	require.True(t, ok)
+	assert.Equal(t, libpf.Address(0x1000), start)
+	assert.Equal(t, uint64(0x20), size)
+	assert.Equal(t, "trace_1", name)
+
+	_, _, _, ok = parsePerfMapLine("garbage\n")
+	assert.False(t, ok)
+}
+
+func TestPerfMapReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "perf-42.map")
+	require.NoError(t, os.WriteFile(path, []byte("1000 10 one\n2000 10 tw"), 0o644))
+
+	var table symbolTable
+	var batch symbolBatch
+	r := &perfMapReader{path: path, uid: uint32(os.Getuid())}
+	require.NoError(t, r.read(&batch))
+	table.apply(batch)
+	name, ok := table.lookup(0x1000)
+	assert.True(t, ok)
+	assert.Equal(t, "one", name)
+	_, ok = table.lookup(0x2000)
+	assert.False(t, ok, "incomplete line must not be parsed")
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("o\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	batch = nil
+	require.NoError(t, r.read(&batch))
+	table.apply(batch)
+	name, ok = table.lookup(0x2008)
+	assert.True(t, ok)
+	assert.Equal(t, "two", name)
+}
+
+// jitDumpRecord encodes a jitdump record with the given id and body.
+func jitDumpRecord(id uint32, body []byte) []byte {
+	record := binary.LittleEndian.AppendUint32(nil, id)
+	record = binary.LittleEndian.AppendUint32(record, uint32(jitDumpRecordHeaderSize+len(body)))
+	record = binary.LittleEndian.AppendUint64(record, 0)
+	return append(record, body...)
+}
+
+func TestJitDumpReader(t *testing.T) {
+	var buf bytes.Buffer
+	header := binary.LittleEndian.AppendUint32(nil, jitDumpMagic)
+	header = binary.LittleEndian.AppendUint32(header, 1)
+	header = binary.LittleEndian.AppendUint32(header, jitDumpHeaderSize)
+	header = append(header, make([]byte, jitDumpHeaderSize-len(header))...)
+	buf.Write(header)
+
+	load := make([]byte, jitCodeLoadSize)
+	binary.LittleEndian.PutUint64(load[16:], 0x1000)
+	binary.LittleEndian.PutUint64(load[24:], 0x40)
+	load = append(load, "jitted_fn\x00"...)
+	load = append(load, 0x90, 0x90, 0xc3) // code
+	buf.Write(jitDumpRecord(jitCodeLoad, load))
+
+	move := make([]byte, jitCodeMoveSize)
+	binary.LittleEndian.PutUint64(move[16:], 0x1000)
+	binary.LittleEndian.PutUint64(move[24:], 0x3000)
+	binary.LittleEndian.PutUint64(move[32:], 0x40)
+	buf.Write(jitDumpRecord(jitCodeMove, move))
+
+	buf.Write(jitDumpRecord(jitCodeClose, nil))
+
+	path := filepath.Join(t.TempDir(), "jit-42.dump")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	var batch symbolBatch
+	r := &jitDumpReader{path: path, uid: uint32(os.Getuid())}
+	require.NoError(t, r.read(&batch))
+	assert.True(t, r.closed)
+
+	var table symbolTable
+	table.apply(batch)
+
+	for _, addr := range []libpf.Address{0x1010, 0x3010} {
+		name, ok := table.lookup(addr)
+		assert.True(t, ok, "0x%x", addr)
+		assert.Equal(t, "jitted_fn", name)
+	}
+}
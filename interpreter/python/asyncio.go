@@ -0,0 +1,269 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package python // import "go.opentelemetry.io/ebpf-profiler/interpreter/python"
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/remotememory"
+	"go.opentelemetry.io/ebpf-profiler/util"
+)
+
+const (
+	// asyncioTaskNameLabel is the label holding the name of the asyncio task
+	// a Python stack belongs to.
+	asyncioTaskNameLabel = "python.asyncio.task.name"
+
+	// maxAsyncioTasks limits the number of awaiting tasks that are unwound.
+	maxAsyncioTasks = 16
+	// maxAsyncioFrames limits the number of coroutine frames unwound per task.
+	maxAsyncioFrames = 32
+
+	// frameSuspended is the gi_frame_state of a coroutine waiting in an await.
+	frameSuspended = -1
+)
+
+// asyncioStructs holds the offsets needed to reconstruct the await chain of
+// asyncio tasks. The C implementation of asyncio in Modules/_asynciomodule.c
+// provides no introspection data, so they are hard coded per Python version.
+type asyncioStructs struct {
+	PyObject struct {
+		Type uint // ob_type
+	}
+	PyTypeObject struct {
+		Name uint // tp_name
+	}
+	// https://github.com/python/cpython/blob/v3.11.7/Include/cpython/genobject.h#L14
+	PyGenObject struct {
+		FrameState uint // gi_frame_state
+		IFrame     uint // gi_iframe
+	}
+	// https://github.com/python/cpython/blob/v3.11.7/Include/internal/pycore_frame.h#L47
+	PyInterpreterFrame struct {
+		Code       uint // f_code
+		PrevInstr  uint // prev_instr
+		StackTop   uint // stacktop
+		LocalsPlus uint // localsplus
+	}
+	PyASCIIObject struct {
+		Data uint
+	}
+	PyLongObject struct {
+		Size   uint // ob_size, or lv_tag on Python 3.12+
+		Digits uint // ob_digit
+		// Tagged is set if the number of digits is stored in lv_tag.
+		Tagged bool
+	}
+	PyCFunctionObject struct {
+		Ml   uint // m_ml
+		Self uint // m_self
+	}
+	FutureObj struct {
+		Callback0 uint // fut_callback0
+	}
+	TaskObj struct {
+		Coro uint // task_coro
+		Name uint // task_name
+	}
+	// The Handle._callback slot of an asyncio.Handle and the task of the
+	// TaskStepMethWrapper it holds are read in eBPF.
+	Handle struct {
+		Callback uint
+	}
+	TaskStepMethWrapper struct {
+		Task uint // sw_task
+	}
+}
+
+// newAsyncioStructs returns the asyncio offsets for the given Python version,
+// or nil if the version is not supported.
+func newAsyncioStructs(version uint16, freeThreaded bool, strData uint) *asyncioStructs {
+	if version < pythonVer(3, 11) || version > pythonVer(3, 13) {
+		return nil
+	}
+
+	a := &asyncioStructs{}
+	a.PyObject.Type = 8
+	a.PyTypeObject.Name = 24
+	a.PyASCIIObject.Data = strData
+	a.PyLongObject.Size = 16
+	a.PyLongObject.Digits = 24
+	a.PyCFunctionObject.Ml = 16
+	a.PyCFunctionObject.Self = 24
+	a.FutureObj.Callback0 = 24
+	a.PyInterpreterFrame.PrevInstr = 56
+	a.PyInterpreterFrame.StackTop = 64
+	a.PyInterpreterFrame.LocalsPlus = 72
+	a.Handle.Callback = 24
+	a.TaskStepMethWrapper.Task = 16
+
+	switch version {
+	case pythonVer(3, 11):
+		a.PyGenObject.FrameState = 75
+		a.PyGenObject.IFrame = 80
+		a.PyInterpreterFrame.Code = 32
+		a.TaskObj.Coro = 136
+	case pythonVer(3, 12):
+		a.PyGenObject.FrameState = 67
+		a.PyGenObject.IFrame = 72
+		a.PyLongObject.Tagged = true
+		a.TaskObj.Coro = 136
+	case pythonVer(3, 13):
+		a.PyGenObject.FrameState = 67
+		a.PyGenObject.IFrame = 72
+		a.PyLongObject.Tagged = true
+		a.TaskObj.Coro = 120
+	}
+	a.TaskObj.Name = a.TaskObj.Coro + 8
+
+	if freeThreaded {
+		// All object members follow the larger PyObject_HEAD.
+		for _, offset := range []*uint{
+			&a.PyObject.Type, &a.PyTypeObject.Name,
+			&a.PyGenObject.FrameState, &a.PyGenObject.IFrame,
+			&a.PyLongObject.Size, &a.PyLongObject.Digits,
+			&a.PyCFunctionObject.Ml, &a.PyCFunctionObject.Self,
+			&a.FutureObj.Callback0, &a.TaskObj.Coro, &a.TaskObj.Name,
+			&a.Handle.Callback, &a.TaskStepMethWrapper.Task,
+		} {
+			*offset += freeThreadedHeadExtra
+		}
+	}
+	return a
+}
+
+// asyncioFrame is the frame of a suspended coroutine.
+type asyncioFrame struct {
+	// code is the address of the PyCodeObject of the coroutine.
+	code libpf.Address
+	// instr is the instruction pointer of the frame.
+	instr libpf.Address
+}
+
+// isType checks whether the type of the object at addr has the given name.
+func (a *asyncioStructs) isType(rm remotememory.RemoteMemory, addr libpf.Address,
+	name string) bool {
+	if addr == 0 {
+		return false
+	}
+	typ := rm.Ptr(addr + libpf.Address(a.PyObject.Type))
+	if typ == 0 {
+		return false
+	}
+	return rm.StringPtr(typ+libpf.Address(a.PyTypeObject.Name)) == name
+}
+
+// taskName returns the name of the asyncio Task at addr. Python 3.12+ stores the
+// number of default task names, which are formatted on demand.
+func (a *asyncioStructs) taskName(rm remotememory.RemoteMemory, task libpf.Address) string {
+	name := rm.Ptr(task + libpf.Address(a.TaskObj.Name))
+	switch {
+	case a.isType(rm, name, "str"):
+		s := rm.String(name + libpf.Address(a.PyASCIIObject.Data))
+		if !util.IsValidString(s) {
+			return ""
+		}
+		return s
+	case a.isType(rm, name, "int"):
+		size := rm.Uint64(name + libpf.Address(a.PyLongObject.Size))
+		if a.PyLongObject.Tagged {
+			size >>= 3
+		}
+		if size == 0 || size > 2 {
+			return ""
+		}
+		digits := rm.Ptr(name + libpf.Address(a.PyLongObject.Digits))
+		// PyLong digits are 30 bits wide.
+		n := uint64(digits) & (1<<30 - 1)
+		if size == 2 {
+			n |= (uint64(digits) >> 32) << 30
+		}
+		return fmt.Sprintf("Task-%d", n)
+	}
+	return ""
+}
+
+// awaitingTask returns the asyncio Task awaiting the completion of the Task at
+// addr, or 0. The awaiting Task adds its task_wakeup method as the first done
+// callback.
+func (a *asyncioStructs) awaitingTask(rm remotememory.RemoteMemory,
+	task libpf.Address) libpf.Address {
+	callback := rm.Ptr(task + libpf.Address(a.FutureObj.Callback0))
+	if !a.isType(rm, callback, "builtin_function_or_method") {
+		return 0
+	}
+	methodDef := rm.Ptr(callback + libpf.Address(a.PyCFunctionObject.Ml))
+	if methodDef == 0 || rm.StringPtr(methodDef) != "task_wakeup" {
+		return 0
+	}
+	awaiting := rm.Ptr(callback + libpf.Address(a.PyCFunctionObject.Self))
+	if !a.isType(rm, awaiting, "_asyncio.Task") {
+		return 0
+	}
+	return awaiting
+}
+
+// coroutineFrames returns the frames of the suspended coroutines of the Task at
+// addr, innermost first. Starting with the coroutine of the Task, the chain is
+// followed through the object each coroutine awaits, which is on top of the
+// value stack of its frame.
+func (a *asyncioStructs) coroutineFrames(rm remotememory.RemoteMemory,
+	task libpf.Address) []asyncioFrame {
+	var frames []asyncioFrame
+	coro := rm.Ptr(task + libpf.Address(a.TaskObj.Coro))
+	for len(frames) < maxAsyncioFrames && a.isType(rm, coro, "coroutine") {
+		frame := coro + libpf.Address(a.PyGenObject.IFrame)
+		code := rm.Ptr(frame + libpf.Address(a.PyInterpreterFrame.Code))
+		if code == 0 {
+			break
+		}
+		frames = append(frames, asyncioFrame{
+			code:  code,
+			instr: rm.Ptr(frame + libpf.Address(a.PyInterpreterFrame.PrevInstr)),
+		})
+
+		if int8(rm.Uint8(coro+libpf.Address(a.PyGenObject.FrameState))) != frameSuspended {
+			break
+		}
+		stackTop := int32(rm.Uint32(frame + libpf.Address(a.PyInterpreterFrame.StackTop)))
+		if stackTop < 1 {
+			break
+		}
+		coro = rm.Ptr(frame + libpf.Address(a.PyInterpreterFrame.LocalsPlus) +
+			libpf.Address(stackTop-1)*8)
+	}
+	slices.Reverse(frames)
+	return frames
+}
+
+// walkTask returns the name of the asyncio Task at addr and the coroutine frames
+// of the Tasks awaiting it, innermost first.
+func (a *asyncioStructs) walkTask(rm remotememory.RemoteMemory,
+	task libpf.Address) (string, []asyncioFrame, error) {
+	if !a.isType(rm, task, "_asyncio.Task") {
+		return "", nil, errors.New("not an asyncio task")
+	}
+	name := a.taskName(rm, task)
+
+	var frames []asyncioFrame
+	for range maxAsyncioTasks {
+		task = a.awaitingTask(rm, task)
+		if task == 0 {
+			break
+		}
+		frames = append(frames, a.coroutineFrames(rm, task)...)
+	}
+	return name, frames, nil
+}
+
+// isHandleRun reports whether the code object with the given qualified name and
+// source file is Handle._run of asyncio, which runs the steps of asyncio tasks.
+func isHandleRun(qualName, sourceFileName string) bool {
+	return qualName == "Handle._run" && path.Base(sourceFileName) == "events.py" &&
+		path.Base(path.Dir(sourceFileName)) == "asyncio"
+}
@@ -0,0 +1,165 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package python
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/remotememory"
+)
+
+// fakeHeap lays out Python objects in a flat memory buffer.
+type fakeHeap struct {
+	mem  []byte
+	next libpf.Address
+	a    *asyncioStructs
+}
+
+// alloc reserves size bytes and returns their address.
+func (h *fakeHeap) alloc(size int) libpf.Address {
+	addr := h.next
+	h.next += libpf.Address(size+7) &^ 7
+	return addr
+}
+
+func (h *fakeHeap) putPtr(addr, value libpf.Address) {
+	binary.LittleEndian.PutUint64(h.mem[addr:], uint64(value))
+}
+
+func (h *fakeHeap) cstring(s string) libpf.Address {
+	addr := h.alloc(len(s) + 1)
+	copy(h.mem[addr:], s)
+	return addr
+}
+
+// object allocates an object of a new type with the given name.
+func (h *fakeHeap) object(typeName string, size int) libpf.Address {
+	typ := h.alloc(int(h.a.PyTypeObject.Name) + 8)
+	h.putPtr(typ+libpf.Address(h.a.PyTypeObject.Name), h.cstring(typeName))
+	obj := h.alloc(size)
+	h.putPtr(obj+libpf.Address(h.a.PyObject.Type), typ)
+	return obj
+}
+
+func (h *fakeHeap) str(s string) libpf.Address {
+	obj := h.object("str", int(h.a.PyASCIIObject.Data)+len(s)+1)
+	copy(h.mem[obj+libpf.Address(h.a.PyASCIIObject.Data):], s)
+	return obj
+}
+
+// coroutine allocates a coroutine executing code, which awaits the given object.
+func (h *fakeHeap) coroutine(code, awaits libpf.Address) libpf.Address {
+	a := h.a
+	coro := h.object("coroutine", int(a.PyGenObject.IFrame+a.PyInterpreterFrame.LocalsPlus)+8)
+	frame := coro + libpf.Address(a.PyGenObject.IFrame)
+	h.mem[coro+libpf.Address(a.PyGenObject.FrameState)] = 0xff // suspended
+	h.putPtr(frame+libpf.Address(a.PyInterpreterFrame.Code), code)
+	h.putPtr(frame+libpf.Address(a.PyInterpreterFrame.PrevInstr), code+0x100)
+	binary.LittleEndian.PutUint32(h.mem[frame+libpf.Address(a.PyInterpreterFrame.StackTop):], 1)
+	h.putPtr(frame+libpf.Address(a.PyInterpreterFrame.LocalsPlus), awaits)
+	return coro
+}
+
+func (h *fakeHeap) task(coro, name libpf.Address) libpf.Address {
+	task := h.object("_asyncio.Task", int(h.a.TaskObj.Name)+8)
+	h.putPtr(task+libpf.Address(h.a.TaskObj.Coro), coro)
+	h.putPtr(task+libpf.Address(h.a.TaskObj.Name), name)
+	return task
+}
+
+// await makes task wait for the completion of awaited.
+func (h *fakeHeap) await(task, awaited libpf.Address) {
+	methodDef := h.alloc(8)
+	h.putPtr(methodDef, h.cstring("task_wakeup"))
+	callback := h.object("builtin_function_or_method",
+		int(h.a.PyCFunctionObject.Self)+8)
+	h.putPtr(callback+libpf.Address(h.a.PyCFunctionObject.Ml), methodDef)
+	h.putPtr(callback+libpf.Address(h.a.PyCFunctionObject.Self), task)
+	h.putPtr(awaited+libpf.Address(h.a.FutureObj.Callback0), callback)
+}
+
+func TestAsyncioWalkTask(t *testing.T) {
+	tests := []struct {
+		version      uint16
+		freeThreaded bool
+	}{
+		{version: pythonVer(3, 11)},
+		{version: pythonVer(3, 12)},
+		{version: pythonVer(3, 13)},
+		{version: pythonVer(3, 13), freeThreaded: true},
+	}
+	for _, test := range tests {
+		a := newAsyncioStructs(test.version, test.freeThreaded, 40)
+		require.NotNil(t, a)
+		h := &fakeHeap{mem: make([]byte, 0x4000), next: 8, a: a}
+		rm := remotememory.RemoteMemory{ReaderAt: bytes.NewReader(h.mem)}
+
+		// main awaits worker, which awaits the running task.
+		futureIter := h.object("_asyncio.FutureIter", int(a.PyObject.Type)+8)
+		worker := h.coroutine(0x1000, futureIter)
+		helper := h.coroutine(0x2000, futureIter)
+		workerTask := h.task(h.coroutine(0x3000, helper), h.str("worker"))
+		mainTask := h.task(h.coroutine(0x4000, futureIter), h.str("main"))
+		running := h.task(worker, h.str("running"))
+		h.await(workerTask, running)
+		h.await(mainTask, workerTask)
+
+		name, frames, err := a.walkTask(rm, running)
+		require.NoError(t, err)
+		assert.Equal(t, "running", name)
+		assert.Equal(t, []asyncioFrame{
+			{code: 0x2000, instr: 0x2100},
+			{code: 0x3000, instr: 0x3100},
+			{code: 0x4000, instr: 0x4100},
+		}, frames)
+
+		_, _, err = a.walkTask(rm, futureIter)
+		assert.Error(t, err)
+	}
+}
+
+func TestAsyncioTaskName(t *testing.T) {
+	tests := map[uint16]struct {
+		size   uint64
+		digits uint64
+	}{
+		pythonVer(3, 11): {size: 1, digits: 42},
+		pythonVer(3, 12): {size: 1 << 3, digits: 42},
+	}
+	for version, test := range tests {
+		a := newAsyncioStructs(version, false, 40)
+		h := &fakeHeap{mem: make([]byte, 0x1000), next: 8, a: a}
+		rm := remotememory.RemoteMemory{ReaderAt: bytes.NewReader(h.mem)}
+
+		number := h.object("int", int(a.PyLongObject.Digits)+8)
+		binary.LittleEndian.PutUint64(h.mem[number+libpf.Address(a.PyLongObject.Size):],
+			test.size)
+		binary.LittleEndian.PutUint64(h.mem[number+libpf.Address(a.PyLongObject.Digits):],
+			test.digits)
+		task := h.task(0, number)
+		assert.Equal(t, "Task-42", a.taskName(rm, task))
+	}
+}
+
+func TestIsHandleRun(t *testing.T) {
+	for _, test := range []struct {
+		qualName, sourceFileName string
+		want                     bool
+	}{
+		{"Handle._run", "/usr/lib/python3.12/asyncio/events.py", true},
+		// A coroutine driven with coro.send() from an ordinary function.
+		{"drive", "/app/main.py", false},
+		{"Handle._run", "/app/events.py", false},
+		{"_run", "/usr/lib/python3.12/asyncio/events.py", false},
+	} {
+		assert.Equal(t, test.want, isHandleRun(test.qualName, test.sourceFileName),
+			"%s in %s", test.qualName, test.sourceFileName)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package python // import "go.opentelemetry.io/ebpf-profiler/interpreter/python"
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/interpreter"
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/libpf/pfelf"
+	"go.opentelemetry.io/ebpf-profiler/process"
+	"go.opentelemetry.io/ebpf-profiler/remotememory"
+	"go.opentelemetry.io/ebpf-profiler/reporter"
+)
+
+// greenletIDLabel is the label identifying the greenlet a Python stack belongs
+// to. It holds the address of the greenlet object, which is the same for all of
+// its stacks while it is alive.
+const greenletIDLabel = "python.greenlet.id"
+
+// greenletStateSymbol is part of the mangled name of the thread local variable
+// of greenlet 2.0+ that points to the greenlet thread state of the thread. The
+// pointer is 1 until the thread uses greenlets, and 0 once the thread exited.
+const greenletStateSymbol = "g_thread_state_global"
+
+// greenletCurrentOffset is the offset of current_greenlet in the greenlet thread
+// state, which follows main_greenlet.
+const greenletCurrentOffset = 8
+
+// errGreenletNotRelocated is returned if the TLS module ID of the greenlet
+// extension module is not known yet, because the dynamic linker has not
+// relocated the module after mapping it.
+var errGreenletNotRelocated = errors.New("greenlet module is not relocated yet")
+
+// greenletRegex matches the greenlet extension module, which is greenlet/_greenlet
+// since greenlet 1.0 and the top level greenlet module before.
+var greenletRegex = regexp.MustCompile(`(?:^|/)_?greenlet\.[^/]*so$`)
+
+// greenletTLS locates the greenlet thread state pointer in the thread local
+// storage of a thread.
+type greenletTLS struct {
+	// module is the TLS module ID of the greenlet extension module, or 0 if
+	// greenlet is not supported.
+	module uint64
+	// offset is the offset of the thread state pointer in the TLS block of
+	// the module.
+	offset uint64
+}
+
+// SynchronizeMappings enables the greenlet support once the greenlet extension
+// module is loaded. Greenlet based servers like gevent or eventlet switch C stacks
+// under the interpreter, and each greenlet has its own chain of Python frames.
+func (p *pythonInstance) SynchronizeMappings(ebpf interpreter.EbpfHandler,
+	_ reporter.SymbolReporter, pr process.Process, mappings []process.Mapping) error {
+	if !p.d.greenlet || p.greenletLoaded {
+		return nil
+	}
+	for idx := range mappings {
+		m := &mappings[idx]
+		if !m.IsExecutable() || !greenletRegex.MatchString(m.Path) {
+			continue
+		}
+		tls, err := openGreenletTLS(pr, m)
+		if errors.Is(err, errGreenletNotRelocated) {
+			// Retry on the next synchronization.
+			return nil
+		}
+		p.greenletLoaded = true
+		if err != nil {
+			log.Debugf("PID %d uses unsupported greenlets from %s: %v",
+				pr.PID(), m.Path, err)
+			return nil
+		}
+		log.Debugf("PID %d uses greenlets from %s", pr.PID(), m.Path)
+		p.greenlet = tls
+		if !p.procInfoInserted {
+			return nil
+		}
+		return p.UpdateTSDInfo(ebpf, pr.PID(), p.tsdInfo)
+	}
+	return nil
+}
+
+// openGreenletTLS locates the greenlet thread state pointer of the greenlet
+// extension module mapped by m.
+func openGreenletTLS(pr process.Process, m *process.Mapping) (greenletTLS, error) {
+	ef, err := pr.OpenELF(m.Path)
+	if err != nil {
+		return greenletTLS{}, err
+	}
+	defer ef.Close()
+
+	mapper := ef.GetAddressMapper()
+	vaddr, ok := mapper.FileOffsetToVirtualAddress(m.FileOffset)
+	if !ok {
+		return greenletTLS{}, fmt.Errorf("failed to map file offset %#x", m.FileOffset)
+	}
+	return findGreenletTLS(ef, pr.GetRemoteMemory(), libpf.Address(m.Vaddr-vaddr))
+}
+
+// findGreenletTLS locates the greenlet thread state pointer of the greenlet
+// extension module ef loaded with the given bias.
+func findGreenletTLS(ef *pfelf.File, rm remotememory.RemoteMemory,
+	bias libpf.Address) (greenletTLS, error) {
+	offset, err := greenletStateOffset(ef)
+	if err != nil {
+		return greenletTLS{}, err
+	}
+	// The module ID is only known at runtime, and glibc stores it in the GOT
+	// for the dynamic TLS access models.
+	slot, err := ef.TLSModuleIDAddress()
+	if err != nil {
+		return greenletTLS{}, err
+	}
+	module := rm.Uint64(bias + slot)
+	if module == 0 {
+		return greenletTLS{}, errGreenletNotRelocated
+	}
+	return greenletTLS{module: module, offset: offset}, nil
+}
+
+// greenletStateOffset returns the offset of the thread state pointer in the TLS
+// block of the greenlet extension module.
+func greenletStateOffset(ef *pfelf.File) (uint64, error) {
+	if syms, err := ef.ReadSymbols(); err == nil {
+		offset := uint64(0)
+		found := false
+		syms.VisitAll(func(sym libpf.Symbol) {
+			if strings.Contains(string(sym.Name), greenletStateSymbol) {
+				offset = uint64(sym.Address)
+				found = true
+			}
+		})
+		if found {
+			return offset, nil
+		}
+	}
+	// Stripped modules have no symbol for the thread state pointer, which is
+	// found if it is the only thread local variable of the module.
+	for i := range ef.Progs {
+		if ef.Progs[i].Type == elf.PT_TLS && ef.Progs[i].Memsz == 8 {
+			return 0, nil
+		}
+	}
+	return 0, errors.New("greenlet thread state not found, greenlet < 2.0 is not supported")
+}
+
+// symbolizeGreenlet labels the trace with the running greenlet.
+func symbolizeGreenlet(greenlet libpf.Address, trace *libpf.Trace) {
+	trace.SetCustomLabel(greenletIDLabel, fmt.Sprintf("%#x", uint64(greenlet)))
+}
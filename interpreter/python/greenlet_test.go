@@ -0,0 +1,133 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package python
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/libpf/pfelf"
+	"go.opentelemetry.io/ebpf-profiler/remotememory"
+)
+
+// Base64-encoded x86-64 stand-in for the greenlet extension module. It has the
+// thread local g_thread_state_global at offset 0 of its TLS block, accessed with
+// the local dynamic TLS model. Built from:
+//
+//	struct ThreadState { void *main_greenlet, *current_greenlet; };
+//	static __thread struct ThreadState *g_thread_state_global = (struct ThreadState *)1;
+//	__attribute__((noinline)) void *g_initialstub(struct ThreadState *state)
+//	{ g_thread_state_global = state; return state->current_greenlet; }
+//	__attribute__((noinline)) int slp_switch(void) { return g_thread_state_global != 0; }
+//	void *PyInit__greenlet(void) { return (void *)(long)slp_switch(); }
+//
+// with gcc -O1 -fPIC -shared -nostdlib -Wl,--build-id=none -Wl,-z,norelro.
+var greenletModule = `f0VMRgIBAQAAAAAAAAAAAAMAPgABAAAAAAAAAAAAAABAAAAAAAAAAHAHAAAAAAAAAAAAAEAAOAAG
+AEAAEgARAAEAAAAFAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA/AMAAAAAAAD8AwAAAAAAAAAQ
+AAAAAAAAAQAAAAYAAAAABAAAAAAAAAAUAAAAAAAAABQAAAAAAABgAQAAAAAAAGABAAAAAAAAABAA
+AAAAAAACAAAABgAAAAgEAAAAAAAACBQAAAAAAAAIFAAAAAAAACABAAAAAAAAIAEAAAAAAAAIAAAA
+AAAAAAcAAAAEAAAAAAQAAAAAAAAAFAAAAAAAAAAUAAAAAAAACAAAAAAAAAAIAAAAAAAAAAgAAAAA
+AAAAUOV0ZAQAAABAAwAAAAAAAEADAAAAAAAAQAMAAAAAAAAsAAAAAAAAACwAAAAAAAAABAAAAAAA
+AABR5XRkBgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAAAAAAAA
+AAMAAAACAAAAAQAAAAYAAAAgACkAAABAAgIAAAADAAAABAAAAEWefC03hCRAUwXCRwAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAA8AAAAQAAAAAAAAAAAAAAAAAAAAAAAAAB4AAAASAAcADQMAAAAAAAAj
+AAAAAAAAACkAAAASAAcAMAMAAAAAAAAQAAAAAAAAAAEAAAASAAcA8AIAAAAAAAAdAAAAAAAAAABn
+X2luaXRpYWxzdHViAF9fdGxzX2dldF9hZGRyAHNscF9zd2l0Y2gAUHlJbml0X19ncmVlbmxldAAA
+AAAAAAAoFQAAAAAAABAAAAAAAAAAAAAAAAAAAABQFQAAAAAAAAcAAAACAAAAAAAAAAAAAABYFQAA
+AAAAAAcAAAABAAAAAAAAAAAAAAD/NXoSAAD/JXwSAAAPH0AA/yV6EgAAaAAAAADp4P////8lchIA
+AGgBAAAA6dD///9TSIn7SI09LRIAAOjg////SImYAAAAAEiLQwhbw0iD7AhIjT0QEgAA6MP///9I
+g7gAAAAAAA+VwA+2wEiDxAjDSIPsCOiX////SJhIg8QIwwEbAzssAAAABAAAAID///+YAAAAsP//
+/0gAAADN////ZAAAAPD///98AAAAAAAAABQAAAAAAAAAAXpSAAF4EAEbDAcIkAEAABgAAAAcAAAA
+YP///x0AAAAAQQ4QgwJbDggAAAAUAAAAOAAAAGH///8jAAAAAEQOEF4OCAAYAAAAUAAAAGz///8Q
+AAAAAEQOEEsOCAAAAAAAIAAAAGwAAADg/v//MAAAAAAOEEYOGEoPC3cIgAA/GjsqMyQiAAAAAAEA
+AAAAAAAA9f7/bwAAAACQAQAAAAAAAAUAAAAAAAAAOAIAAAAAAAAGAAAAAAAAAMABAAAAAAAACgAA
+AAAAAAA6AAAAAAAAAAsAAAAAAAAAGAAAAAAAAAADAAAAAAAAADgVAAAAAAAAAgAAAAAAAAAwAAAA
+AAAAABQAAAAAAAAABwAAAAAAAAAXAAAAAAAAAJACAAAAAAAABwAAAAAAAAB4AgAAAAAAAAgAAAAA
+AAAAGAAAAAAAAAAJAAAAAAAAABgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAgUAAAAAAAAAAAAAAAAAAAAAAAAAAAAANYCAAAAAAAA
+5gIAAAAAAABHQ0M6IChEZWJpYW4gMTIuMi4wLTE0K2RlYjEydTEpIDEyLjIuMAAAAAAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAQAAAAYACgAAAAAAAAAAAAgAAAAAAAAAFwAAAAEACwAIFAAAAAAAAAAA
+AAAAAAAAIAAAAAAACABAAwAAAAAAAAAAAAAAAAAAMwAAAAEADQA4FQAAAAAAAAAAAAAAAAAASQAA
+ABIABwAwAwAAAAAAABAAAAAAAAAAWgAAABIABwDwAgAAAAAAAB0AAAAAAAAAaAAAABIABwANAwAA
+AAAAACMAAAAAAAAAcwAAABAAAAAAAAAAAAAAAAAAAAAAAAAAAGdfdGhyZWFkX3N0YXRlX2dsb2Jh
+bABfRFlOQU1JQwBfX0dOVV9FSF9GUkFNRV9IRFIAX0dMT0JBTF9PRkZTRVRfVEFCTEVfAFB5SW5p
+dF9fZ3JlZW5sZXQAZ19pbml0aWFsc3R1YgBzbHBfc3dpdGNoAF9fdGxzX2dldF9hZGRyAAAuc3lt
+dGFiAC5zdHJ0YWIALnNoc3RydGFiAC5nbnUuaGFzaAAuZHluc3ltAC5keW5zdHIALnJlbGEuZHlu
+AC5yZWxhLnBsdAAudGV4dAAuZWhfZnJhbWVfaGRyAC5laF9mcmFtZQAudGRhdGEALmR5bmFtaWMA
+LmdvdAAuZ290LnBsdAAuY29tbWVudAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAGwAAAPb//28CAAAAAAAAAJABAAAAAAAAkAEA
+AAAAAAAwAAAAAAAAAAIAAAAAAAAACAAAAAAAAAAAAAAAAAAAACUAAAALAAAAAgAAAAAAAADAAQAA
+AAAAAMABAAAAAAAAeAAAAAAAAAADAAAAAQAAAAgAAAAAAAAAGAAAAAAAAAAtAAAAAwAAAAIAAAAA
+AAAAOAIAAAAAAAA4AgAAAAAAADoAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAANQAAAAQA
+AAACAAAAAAAAAHgCAAAAAAAAeAIAAAAAAAAYAAAAAAAAAAIAAAAAAAAACAAAAAAAAAAYAAAAAAAA
+AD8AAAAEAAAAQgAAAAAAAACQAgAAAAAAAJACAAAAAAAAMAAAAAAAAAACAAAADQAAAAgAAAAAAAAA
+GAAAAAAAAABEAAAAAQAAAAYAAAAAAAAAwAIAAAAAAADAAgAAAAAAADAAAAAAAAAAAAAAAAAAAAAQ
+AAAAAAAAABAAAAAAAAAASQAAAAEAAAAGAAAAAAAAAPACAAAAAAAA8AIAAAAAAABQAAAAAAAAAAAA
+AAAAAAAAAQAAAAAAAAAAAAAAAAAAAE8AAAABAAAAAgAAAAAAAABAAwAAAAAAAEADAAAAAAAALAAA
+AAAAAAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAABdAAAAAQAAAAIAAAAAAAAAcAMAAAAAAABwAwAA
+AAAAAIwAAAAAAAAAAAAAAAAAAAAIAAAAAAAAAAAAAAAAAAAAZwAAAAEAAAADBAAAAAAAAAAUAAAA
+AAAAAAQAAAAAAAAIAAAAAAAAAAAAAAAAAAAACAAAAAAAAAAAAAAAAAAAAG4AAAAGAAAAAwAAAAAA
+AAAIFAAAAAAAAAgEAAAAAAAAIAEAAAAAAAADAAAAAAAAAAgAAAAAAAAAEAAAAAAAAAB3AAAAAQAA
+AAMAAAAAAAAAKBUAAAAAAAAoBQAAAAAAABAAAAAAAAAAAAAAAAAAAAAIAAAAAAAAAAgAAAAAAAAA
+fAAAAAEAAAADAAAAAAAAADgVAAAAAAAAOAUAAAAAAAAoAAAAAAAAAAAAAAAAAAAACAAAAAAAAAAI
+AAAAAAAAAIUAAAABAAAAMAAAAAAAAAAAAAAAAAAAAGAFAAAAAAAAJwAAAAAAAAAAAAAAAAAAAAEA
+AAAAAAAAAQAAAAAAAAABAAAAAgAAAAAAAAAAAAAAAAAAAAAAAACIBQAAAAAAANgAAAAAAAAAEAAA
+AAUAAAAIAAAAAAAAABgAAAAAAAAACQAAAAMAAAAAAAAAAAAAAAAAAAAAAAAAYAYAAAAAAACCAAAA
+AAAAAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAABEAAAADAAAAAAAAAAAAAAAAAAAAAAAAAOIGAAAA
+AAAAjgAAAAAAAAAAAAAAAAAAAAEAAAAAAAAAAAAAAAAAAAA=`
+
+func TestGreenletRegex(t *testing.T) {
+	tests := map[string]bool{
+		"/usr/lib/python3/dist-packages/greenlet/_greenlet.cpython-311-x86_64-linux-gnu.so": true,
+		"/venv/lib/python3.8/site-packages/greenlet.cpython-38-x86_64-linux-gnu.so":         true,
+		"_greenlet.cpython-312-aarch64-linux-gnu.so":                                        true,
+		"/usr/lib/libgreenlet.so":                                                           false,
+		"/venv/lib/python3.8/site-packages/greenlet/__init__.py":                            false,
+	}
+	for path, want := range tests {
+		assert.Equal(t, want, greenletRegex.MatchString(path), path)
+	}
+}
+
+func TestFindGreenletTLS(t *testing.T) {
+	buf, err := base64.StdEncoding.DecodeString(greenletModule)
+	require.NoError(t, err)
+	ef, err := pfelf.NewFile(bytes.NewReader(buf), 0, false)
+	require.NoError(t, err)
+
+	// The dynamic linker stored the module ID in the GOT at 0x1528.
+	const bias = 0x10000
+	mem := make([]byte, 0x12000)
+	binary.LittleEndian.PutUint64(mem[bias+0x1528:], 3)
+	rm := remotememory.RemoteMemory{ReaderAt: bytes.NewReader(mem)}
+
+	tls, err := findGreenletTLS(ef, rm, bias)
+	require.NoError(t, err)
+	assert.Equal(t, greenletTLS{module: 3, offset: 0}, tls)
+
+	// The module ID is not set before the module is relocated.
+	_, err = findGreenletTLS(ef, rm, 0)
+	require.ErrorIs(t, err, errGreenletNotRelocated)
+}
+
+func TestSymbolizeGreenlet(t *testing.T) {
+	first := &libpf.Trace{}
+	symbolizeGreenlet(0x7f0012345678, first)
+	assert.Equal(t, map[string]string{greenletIDLabel: "0x7f0012345678"}, first.CustomLabels)
+
+	// The stacks of a greenlet share the label, and other greenlets differ.
+	again := &libpf.Trace{}
+	symbolizeGreenlet(0x7f0012345678, again)
+	assert.Equal(t, first.CustomLabels, again.CustomLabels)
+	other := &libpf.Trace{}
+	symbolizeGreenlet(0x7f0012345000, other)
+	assert.NotEqual(t, first.CustomLabels, other.CustomLabels)
+}
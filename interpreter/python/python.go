@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"path"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -40,12 +41,33 @@ import (
 import "C"
 
 // The following regexs are intended to match either a path to a Python binary or
-// library.
+// library. The third group holds the ABI flags, where 't' marks free-threaded builds.
 var (
-	pythonRegex    = regexp.MustCompile(`^(?:.*/)?python(\d)\.(\d+)(d|m|dm)?$`)
-	libpythonRegex = regexp.MustCompile(`^(?:.*/)?libpython(\d)\.(\d+)[^/]*`)
+	pythonRegex    = regexp.MustCompile(`^(?:.*/)?python(\d)\.(\d+)(d|m|dm|t|td)?$`)
+	libpythonRegex = regexp.MustCompile(`^(?:.*/)?libpython(\d)\.(\d+)(t?)[^/]*`)
 )
 
+// moduleDirRegex matches the directories below which Python files are named
+// after their module, e.g. site-packages/requests/api.py is 'requests.api'.
+var moduleDirRegex = regexp.MustCompile(
+	`/(?:lib/python\d\.\d+t?/)?(?:site|dist)-packages/|/lib/python\d\.\d+t?/`)
+
+// Limits for reading the method resolution order and the dictionaries of classes
+// to find the class defining a method.
+const (
+	maxMROLength        = 32
+	maxClassDictSize    = 1 << 16
+	maxClassDictEntries = 4096
+)
+
+// pyTPFlagsHeapType is the tp_flags bit of classes defined in Python code.
+const pyTPFlagsHeapType = 1 << 9
+
+// freeThreadedHeadExtra is the size the free-threaded (Py_GIL_DISABLED) build
+// adds to PyObject_HEAD for the owning thread ID and the per-thread and shared
+// reference counts.
+const freeThreadedHeadExtra = 16
+
 // pythonVer builds a version number from readable numbers
 func pythonVer(major, minor int) uint16 {
 	return uint16(major)*0x100 + uint16(minor)
@@ -55,8 +77,22 @@ func pythonVer(major, minor int) uint16 {
 type pythonData struct {
 	version uint16
 
+	// freeThreaded is set for free-threaded (Py_GIL_DISABLED) builds.
+	freeThreaded bool
+
 	autoTLSKey libpf.SymbolValue
 
+	// asyncio holds the offsets to reconstruct the await chain of asyncio tasks,
+	// or nil if this is disabled.
+	asyncio *asyncioStructs
+
+	// qualifiedNames is set if functions are reported as Class.method along with
+	// the name of their module.
+	qualifiedNames bool
+
+	// greenlet is set if Python stacks are labeled with the running greenlet.
+	greenlet bool
+
 	// vmStructs reflects the Python Interpreter introspection data we want
 	// need to extract data from the runtime. The fields are named as they are
 	// in the Python code. Eventually some of these fields will be read from
@@ -64,8 +100,12 @@ type pythonData struct {
 	vmStructs struct {
 		// https://github.com/python/cpython/blob/deaf509e8fc6e0363bd6f26d52ad42f976ec42f2/Include/cpython/object.h#L148
 		PyTypeObject struct {
+			Name      libpf.Address `name:"tp_name"`
 			BasicSize libpf.Address `name:"tp_basicsize"`
+			Flags     libpf.Address `name:"tp_flags"`
 			Members   libpf.Address `name:"tp_members"`
+			Dict      libpf.Address `name:"tp_dict"`
+			Mro       libpf.Address `name:"tp_mro"`
 		}
 		// https://github.com/python/cpython/blob/deaf509e8fc6e0363bd6f26d52ad42f976ec42f2/Include/structmember.h#L18
 		PyMemberDef struct {
@@ -88,6 +128,7 @@ type pythonData struct {
 			Lnotab         uint `name:"co_lnotab"`
 			Linetable      uint `name:"co_linetable"` // Python 3.10+
 			QualName       uint `name:"co_qualname"`  // Python 3.11+
+			VarNames       uint `name:"co_varnames"`  // Python < 3.11
 		}
 		// https://github.com/python/cpython/blob/deaf509e8fc6e0363bd6f26d52ad42f976ec42f2/Include/object.h#L109
 		PyVarObject struct {
@@ -96,11 +137,39 @@ type pythonData struct {
 		PyBytesObject struct {
 			Sizeof uint
 		}
+		// https://github.com/python/cpython/blob/deaf509e8fc6e0363bd6f26d52ad42f976ec42f2/Include/cpython/tupleobject.h#L5
+		PyTupleObject struct {
+			ObItem uint `name:"ob_item"`
+		}
+		// https://github.com/python/cpython/blob/v3.10.0/Include/cpython/dictobject.h
+		PyDictObject struct {
+			Keys uint `name:"ma_keys"`
+		}
+		// https://github.com/python/cpython/blob/v3.10.0/Objects/dict-common.h
+		PyDictKeysObject struct {
+			Size     uint `name:"dk_size"`
+			NEntries uint `name:"dk_nentries"`
+			Indices  uint `name:"dk_indices"`
+		}
+		PyDictKeyEntry struct {
+			Sizeof uint
+			Value  uint `name:"me_value"`
+		}
+		// https://github.com/python/cpython/blob/v3.10.0/Include/funcobject.h
+		PyFunctionObject struct {
+			Code uint `name:"func_code"`
+		}
+		// https://github.com/python/cpython/blob/v3.10.0/Objects/funcobject.c
+		// staticmethod objects have the same layout.
+		PyClassMethodObject struct {
+			Callable uint `name:"cm_callable"`
+		}
 		// https://github.com/python/cpython/blob/deaf509e8fc6e0363bd6f26d52ad42f976ec42f2/Include/cpython/pystate.h#L82
 		PyThreadState struct {
 			Frame uint `name:"frame"`
 		}
 		PyFrameObject struct {
+			Sizeof      uint
 			Back        uint `name:"f_back"`
 			Code        uint `name:"f_code"`
 			LastI       uint `name:"f_lasti"`
@@ -117,10 +186,13 @@ type pythonData struct {
 var _ interpreter.Data = &pythonData{}
 
 func (d *pythonData) String() string {
+	if d.freeThreaded {
+		return fmt.Sprintf("Python %d.%dt", d.version>>8, d.version&0xff)
+	}
 	return fmt.Sprintf("Python %d.%d", d.version>>8, d.version&0xff)
 }
 
-func (d *pythonData) Attach(_ interpreter.EbpfHandler, _ libpf.PID, bias libpf.Address,
+func (d *pythonData) Attach(ebpf interpreter.EbpfHandler, pid libpf.PID, bias libpf.Address,
 	rm remotememory.RemoteMemory) (interpreter.Instance, error) {
 	addrToCodeObject, err :=
 		freelru.New[libpf.Address, *pythonCodeObject](interpreter.LruFunctionCacheSize,
@@ -129,11 +201,21 @@ func (d *pythonData) Attach(_ interpreter.EbpfHandler, _ libpf.PID, bias libpf.A
 		return nil, err
 	}
 
+	classMethods, err :=
+		freelru.New[classMethodKey, *pythonCodeObject](interpreter.LruFunctionCacheSize,
+			classMethodKey.hash32)
+	if err != nil {
+		return nil, err
+	}
+
 	i := &pythonInstance{
 		d:                d,
 		rm:               rm,
 		bias:             C.u64(bias),
+		ebpf:             ebpf,
+		pid:              pid,
 		addrToCodeObject: addrToCodeObject,
+		classMethods:     classMethods,
 	}
 
 	switch {
@@ -164,6 +246,14 @@ type pythonCodeObject struct {
 	// sourceFileName is the extracted co_filename field
 	sourceFileName string
 
+	// moduleName is the name of the module derived from co_filename, if enabled
+	// and known
+	moduleName string
+
+	// method is set if the first argument is named 'self' or 'cls', so that the
+	// code can be attributed to the class of the argument on Python < 3.11
+	method bool
+
 	// For Python version < 3.10 lineTable is the extracted co_lnotab, and contains the
 	// "bytecode index" to "line number" mapping data.
 	// For Python version >= 3.10 lineTable is the extracted co_linetable.
@@ -330,6 +420,7 @@ func (m *pythonCodeObject) symbolize(symbolReporter reporter.SymbolReporter, bci
 			SourceFile:     m.sourceFileName,
 			SourceLine:     lineNo,
 			FunctionOffset: functionOffset,
+			ModuleName:     m.moduleName,
 		})
 	}
 }
@@ -348,6 +439,11 @@ type pythonInstance struct {
 	rm   remotememory.RemoteMemory
 	bias C.u64
 
+	// ebpf and pid are used to update the process info once the code object
+	// of Handle._run is found.
+	ebpf interpreter.EbpfHandler
+	pid  libpf.PID
+
 	// addrToCodeObject maps a Python Code object to a pythonCodeObject which caches
 	// the needed data from it.
 	addrToCodeObject *freelru.LRU[libpf.Address, *pythonCodeObject]
@@ -358,9 +454,40 @@ type pythonInstance struct {
 
 	// procInfoInserted tracks whether we've already inserted process info into BPF maps.
 	procInfoInserted bool
+
+	// classMethods maps a code object and the class of its first argument to
+	// the pythonCodeObject attributed to the class defining it.
+	classMethods *freelru.LRU[classMethodKey, *pythonCodeObject]
+
+	// greenletLoaded is set once the process has loaded the greenlet extension
+	// module.
+	greenletLoaded bool
+
+	// greenlet locates the running greenlet, if the greenlet extension module is
+	// supported.
+	greenlet greenletTLS
+
+	// handleRunCode is the address of the code object of asyncio's Handle._run,
+	// or 0 until a frame of it was symbolized.
+	handleRunCode libpf.Address
+
+	// tsdInfo is the last TSD information sent to eBPF.
+	tsdInfo tpbase.TSDInfo
 }
 
 var _ interpreter.Instance = &pythonInstance{}
+var _ interpreter.PrecededSymbolizer = &pythonInstance{}
+
+// classMethodKey identifies a code object run with a given class of its first
+// argument.
+type classMethodKey struct {
+	code  libpf.Address
+	class libpf.Address
+}
+
+func (k classMethodKey) hash32() uint32 {
+	return k.code.Hash32() ^ k.class.Hash32()
+}
 
 func (p *pythonInstance) GetAndResetMetrics() ([]metrics.Metric, error) {
 	addrToCodeObjectStats := p.addrToCodeObject.ResetMetrics()
@@ -420,6 +547,23 @@ func (p *pythonInstance) UpdateTSDInfo(ebpf interpreter.EbpfHandler, pid libpf.P
 		PyCodeObject_co_firstlineno:    C.u8(vm.PyCodeObject.FirstLineno),
 		PyCodeObject_sizeof:            C.u8(vm.PyCodeObject.Sizeof),
 	}
+	if a := d.asyncio; a != nil {
+		cdata.PyFrameObject_localsplus = C.u8(a.PyInterpreterFrame.LocalsPlus)
+		cdata.Handle_callback = C.u8(a.Handle.Callback)
+		cdata.TaskStepMethWrapper_task = C.u8(a.TaskStepMethWrapper.Task)
+		cdata.Handle_run_code = C.u64(p.handleRunCode)
+	}
+	if d.qualifiedNames && d.version < pythonVer(3, 11) {
+		// The tp_basicsize of PyFrame_Type is the size of PyFrameObject, which
+		// ends with the first element of f_localsplus.
+		cdata.PyFrameObject_f_localsplus = C.u16(vm.PyFrameObject.Sizeof - 8)
+		cdata.PyTypeObject_tp_flags = C.u8(vm.PyTypeObject.Flags)
+	}
+	if p.greenlet.module != 0 {
+		cdata.greenlet_tls_module = C.u64(p.greenlet.module)
+		cdata.greenlet_tls_offset = C.u64(p.greenlet.offset)
+		cdata.greenlet_current = C.u8(greenletCurrentOffset)
+	}
 
 	err := ebpf.UpdateProcData(libpf.Python, pid, unsafe.Pointer(&cdata))
 	if err != nil {
@@ -427,6 +571,7 @@ func (p *pythonInstance) UpdateTSDInfo(ebpf interpreter.EbpfHandler, pid libpf.P
 	}
 
 	p.procInfoInserted = true
+	p.tsdInfo = tsdInfo
 	return err
 }
 
@@ -534,6 +679,20 @@ func (p *pythonInstance) getCodeObject(addr libpf.Address,
 			addr)
 	}
 
+	var moduleName string
+	var method bool
+	if p.d.qualifiedNames {
+		moduleName = pythonModuleName(sourcePath)
+		if vms.PyCodeObject.VarNames != 0 && argCount > 0 {
+			varNames := npsr.Ptr(cobj, vms.PyCodeObject.VarNames)
+			firstArg := p.rm.Ptr(varNames + libpf.Address(vms.PyTupleObject.ObItem))
+			switch p.rm.String(data + firstArg) {
+			case "self", "cls":
+				method = true
+			}
+		}
+	}
+
 	ebpfChecksumCalculated := (argCount << 25) + (kwonlyArgCount << 18) +
 		(flags << 10) + firstLineNo
 	if ebpfChecksum != ebpfChecksumCalculated {
@@ -568,6 +727,8 @@ func (p *pythonInstance) getCodeObject(addr libpf.Address,
 		version:        p.d.version,
 		name:           name,
 		sourceFileName: sourceFileName,
+		moduleName:     moduleName,
+		method:         method,
 		firstLineNo:    firstLineNo,
 		lineTable:      lineTable,
 		ebpfChecksum:   ebpfChecksum,
@@ -577,12 +738,245 @@ func (p *pythonInstance) getCodeObject(addr libpf.Address,
 	return pco, nil
 }
 
+// pythonModuleName derives the name of the module from the path of its source
+// file. Files below the standard library or a site-packages directory are named
+// by their path, others by their file name.
+func pythonModuleName(sourcePath string) string {
+	if name, ok := strings.CutPrefix(sourcePath, "<frozen "); ok {
+		return strings.TrimSuffix(name, ">")
+	}
+	name, ok := strings.CutSuffix(sourcePath, ".py")
+	if !ok {
+		return ""
+	}
+	if loc := moduleDirRegex.FindAllStringIndex(name, -1); loc != nil {
+		name = strings.TrimSuffix(name[loc[len(loc)-1][1]:], "/__init__")
+		return strings.ReplaceAll(name, "/", ".")
+	}
+	dir, name := path.Split(name)
+	if name == "__init__" {
+		name = path.Base(dir)
+	}
+	return name
+}
+
+// classMethod returns the code object m at addr attributed to the class defining
+// it, found in the method resolution order of class. It is named Class.method and
+// gets its own file ID. If the defining class is not found, m is returned.
+func (p *pythonInstance) classMethod(m *pythonCodeObject,
+	addr, class libpf.Address) *pythonCodeObject {
+	key := classMethodKey{code: addr, class: class}
+	if cm, ok := p.classMethods.Get(key); ok && cm.ebpfChecksum == m.ebpfChecksum {
+		return cm
+	}
+	cm := p.newClassMethod(m, p.definingClass(addr, class))
+	p.classMethods.Add(key, cm)
+	return cm
+}
+
+func (p *pythonInstance) newClassMethod(m *pythonCodeObject,
+	class libpf.Address) *pythonCodeObject {
+	if class == 0 {
+		return m
+	}
+	name := p.rm.StringPtr(class + p.d.vmStructs.PyTypeObject.Name)
+	if !util.IsValidString(name) {
+		return m
+	}
+	// The names of types created by extension modules include their module.
+	name = name[strings.LastIndexByte(name, '.')+1:]
+	if name == "" {
+		return m
+	}
+
+	h := fnv.New128a()
+	_, _ = h.Write(m.fileID.Bytes())
+	_, _ = h.Write([]byte(name))
+	fileID, err := libpf.FileIDFromBytes(h.Sum(nil))
+	if err != nil {
+		return m
+	}
+
+	cm := *m
+	cm.name = name + "." + m.name
+	cm.fileID = fileID
+	return &cm
+}
+
+// definingClass returns the class in the method resolution order of class whose
+// dictionary holds the function of the code object at addr, or 0 if there is none.
+// Only classes defined in Python code are searched.
+func (p *pythonInstance) definingClass(addr, class libpf.Address) libpf.Address {
+	vms := &p.d.vmStructs
+	mro := p.rm.Ptr(class + vms.PyTypeObject.Mro)
+	if mro == 0 {
+		return 0
+	}
+	numClasses := p.rm.Uint64(mro + libpf.Address(vms.PyVarObject.ObSize))
+	if numClasses == 0 || numClasses > maxMROLength {
+		return 0
+	}
+	classes := make([]byte, numClasses*8)
+	if err := p.rm.Read(mro+libpf.Address(vms.PyTupleObject.ObItem), classes); err != nil {
+		return 0
+	}
+	for i := range uint(numClasses) {
+		base := npsr.Ptr(classes, i*8)
+		if p.rm.Uint64(base+vms.PyTypeObject.Flags)&pyTPFlagsHeapType == 0 {
+			continue
+		}
+		if p.dictHoldsCode(p.rm.Ptr(base+vms.PyTypeObject.Dict), addr) {
+			return base
+		}
+	}
+	return 0
+}
+
+// dictHoldsCode reports whether the dictionary at dict holds the function of the
+// code object at addr, or a classmethod or staticmethod wrapping it.
+func (p *pythonInstance) dictHoldsCode(dict, addr libpf.Address) bool {
+	vms := &p.d.vmStructs
+	if dict == 0 {
+		return false
+	}
+	keys := p.rm.Ptr(dict + libpf.Address(vms.PyDictObject.Keys))
+	if keys == 0 {
+		return false
+	}
+	size := p.rm.Uint64(keys + libpf.Address(vms.PyDictKeysObject.Size))
+	numEntries := min(p.rm.Uint64(keys+libpf.Address(vms.PyDictKeysObject.NEntries)),
+		maxClassDictEntries)
+	if size > maxClassDictSize || numEntries > size {
+		return false
+	}
+
+	// The entries follow the hash table indices, whose size depends on the
+	// size of the table.
+	indexSize := uint64(8)
+	switch {
+	case size <= 0xff:
+		indexSize = 1
+	case size <= 0xffff:
+		indexSize = 2
+	case size <= 0xffffffff:
+		indexSize = 4
+	}
+	entrySize := uint64(vms.PyDictKeyEntry.Sizeof)
+	entries := make([]byte, numEntries*entrySize)
+	entriesAddr := keys + libpf.Address(uint64(vms.PyDictKeysObject.Indices)+size*indexSize)
+	if err := p.rm.Read(entriesAddr, entries); err != nil {
+		return false
+	}
+	for i := range uint(numEntries) {
+		value := npsr.Ptr(entries, i*uint(entrySize)+vms.PyDictKeyEntry.Value)
+		if value == 0 {
+			continue
+		}
+		code := p.rm.Ptr(value + libpf.Address(vms.PyFunctionObject.Code))
+		if code == addr {
+			return true
+		}
+		function := p.rm.Ptr(value + libpf.Address(vms.PyClassMethodObject.Callable))
+		if function != 0 &&
+			p.rm.Ptr(function+libpf.Address(vms.PyFunctionObject.Code)) == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// codeObjectID returns the ID the eBPF unwinder calculates for the PyCodeObject
+// at addr.
+func (p *pythonInstance) codeObjectID(addr libpf.Address) uint32 {
+	vms := &p.d.vmStructs
+	argCount := p.rm.Uint32(addr + libpf.Address(vms.PyCodeObject.ArgCount))
+	kwonlyArgCount := p.rm.Uint32(addr + libpf.Address(vms.PyCodeObject.KwOnlyArgCount))
+	flags := p.rm.Uint32(addr + libpf.Address(vms.PyCodeObject.Flags))
+	firstLineNo := p.rm.Uint32(addr + libpf.Address(vms.PyCodeObject.FirstLineno))
+	return (argCount << 25) + (kwonlyArgCount << 18) + (flags << 10) + firstLineNo
+}
+
+// setHandleRunCode lets the eBPF unwinder read the running asyncio Task from the
+// frames of Handle._run, whose code object is at addr.
+func (p *pythonInstance) setHandleRunCode(addr libpf.Address) {
+	log.Debugf("PID %d runs asyncio Handle._run at 0x%x", p.pid, addr)
+	p.handleRunCode = addr
+	if !p.procInfoInserted {
+		return
+	}
+	if err := p.UpdateTSDInfo(p.ebpf, p.pid, p.tsdInfo); err != nil {
+		log.Debugf("Failed to update Python process info of PID %d: %v", p.pid, err)
+	}
+}
+
+// symbolizeAsyncioTask appends the coroutine frames of the asyncio tasks awaiting
+// the Task at addr, and labels the trace with the name of the Task.
+func (p *pythonInstance) symbolizeAsyncioTask(symbolReporter reporter.SymbolReporter,
+	task libpf.Address, trace *libpf.Trace) {
+	if p.d.asyncio == nil {
+		return
+	}
+	name, frames, err := p.d.asyncio.walkTask(p.rm, task)
+	if err != nil {
+		log.Debugf("Failed to read asyncio task at 0x%x: %v", task, err)
+		return
+	}
+
+	codeSize := libpf.Address(p.d.vmStructs.PyCodeObject.Sizeof)
+	for _, frame := range frames {
+		method, err := p.getCodeObject(frame.code, p.codeObjectID(frame.code))
+		if err != nil {
+			log.Debugf("Failed to get python object of asyncio task at 0x%x: %v", task, err)
+			break
+		}
+		// Calculate the bytecode index the same way as the eBPF unwinder.
+		var lastI uint32
+		if frame.instr > frame.code+codeSize {
+			lastI = uint32(frame.instr-frame.code-codeSize) >> 1
+		}
+		method.symbolize(symbolReporter, lastI, p.getFuncOffset, trace)
+	}
+	if name != "" {
+		trace.SetCustomLabel(asyncioTaskNameLabel, name)
+	}
+}
+
 func (p *pythonInstance) Symbolize(symbolReporter reporter.SymbolReporter,
 	frame *host.Frame, trace *libpf.Trace) error {
+	return p.SymbolizePreceded(symbolReporter, nil, frame, trace)
+}
+
+func (p *pythonInstance) SymbolizePreceded(symbolReporter reporter.SymbolReporter,
+	prev, frame *host.Frame, trace *libpf.Trace) error {
 	if !frame.Type.IsInterpType(libpf.Python) {
 		return interpreter.ErrMismatchInterpreterType
 	}
 
+	if frame.Lineno == libpf.AddressOrLineno(C.PY_CLASS_MARKER) {
+		// The pseudo frame is consumed by the frame following it.
+		return nil
+	}
+	// The pseudo frame preceding a frame carries the class of its first argument.
+	var class libpf.Address
+	if prev != nil && prev.Type.IsInterpType(libpf.Python) &&
+		prev.Lineno == libpf.AddressOrLineno(C.PY_CLASS_MARKER) {
+		class = libpf.Address(prev.File)
+	}
+
+	if frame.Lineno == libpf.AddressOrLineno(C.PY_GREENLET_MARKER) {
+		symbolizeGreenlet(libpf.Address(frame.File), trace)
+		return nil
+	}
+
+	if frame.Lineno == libpf.AddressOrLineno(C.PY_ASYNCIO_TASK_MARKER) {
+		// The pseudo frame of the running asyncio Task is replaced by the
+		// frames of the tasks awaiting it, if any. These change while the
+		// Task runs, so the trace is symbolized anew every time.
+		trace.Dynamic = true
+		p.symbolizeAsyncioTask(symbolReporter, libpf.Address(frame.File), trace)
+		return nil
+	}
+
 	// Extract the Python frame bitfields from the file and line variables
 	ptr := libpf.Address(frame.File)
 	lastI := uint32(frame.Lineno>>32) & 0x0fffffff
@@ -596,6 +990,13 @@ func (p *pythonInstance) Symbolize(symbolReporter reporter.SymbolReporter,
 	if err != nil {
 		return fmt.Errorf("failed to get python object %x: %v", objectID, err)
 	}
+	if p.d.asyncio != nil && p.handleRunCode == 0 &&
+		isHandleRun(method.name, method.sourceFileName) {
+		p.setHandleRunCode(ptr)
+	}
+	if class != 0 && method.method {
+		method = p.classMethod(method, ptr, class)
+	}
 	method.symbolize(symbolReporter, lastI, p.getFuncOffset, trace)
 	sfCounter.ReportSuccess()
 	return nil
@@ -684,7 +1085,32 @@ func decodeStub(ef *pfelf.File, addrBase libpf.SymbolValue, symbolName libpf.Sym
 	return libpf.SymbolValueInvalid
 }
 
+// Loader loads the Python interpreter support.
 func Loader(ebpf interpreter.EbpfHandler, info *interpreter.LoaderInfo) (interpreter.Data, error) {
+	return loader(ebpf, info, Options{})
+}
+
+// Options holds the optional features of the Python interpreter support.
+type Options struct {
+	// Asyncio reconstructs the await chain of asyncio tasks.
+	Asyncio bool
+	// QualifiedNames reports functions as Class.method along with their module.
+	QualifiedNames bool
+	// Greenlet labels stacks with the running greenlet.
+	Greenlet bool
+}
+
+// NewLoader returns a Loader for the Python interpreter support with the given
+// optional features.
+func NewLoader(opts Options) interpreter.Loader {
+	return func(ebpf interpreter.EbpfHandler, info *interpreter.LoaderInfo) (
+		interpreter.Data, error) {
+		return loader(ebpf, info, opts)
+	}
+}
+
+func loader(ebpf interpreter.EbpfHandler, info *interpreter.LoaderInfo,
+	opts Options) (interpreter.Data, error) {
 	mainDSO := false
 	matches := libpythonRegex.FindStringSubmatch(info.FileName())
 	if matches == nil {
@@ -729,6 +1155,17 @@ func Loader(ebpf interpreter.EbpfHandler, info *interpreter.LoaderInfo) (interpr
 			(maxVer>>8)&0xff, maxVer&0xff)
 	}
 
+	// Free-threaded builds carry the 't' ABI flag in their file name, and export
+	// the functions managing the shared reference count.
+	freeThreaded := strings.Contains(matches[3], "t")
+	if _, err = ef.LookupSymbolAddress("_Py_DecRefShared"); err == nil {
+		freeThreaded = true
+	}
+	if freeThreaded && version < pythonVer(3, 13) {
+		return nil, fmt.Errorf("unsupported free-threaded Python %d.%d (need >= 3.13)",
+			major, minor)
+	}
+
 	if version >= pythonVer(3, 7) {
 		if pyruntimeAddr, err = ef.LookupSymbolAddress("_PyRuntime"); err != nil {
 			return nil, fmt.Errorf("_PyRuntime not defined: %v", err)
@@ -769,22 +1206,40 @@ func Loader(ebpf interpreter.EbpfHandler, info *interpreter.LoaderInfo) (interpr
 	}
 
 	pd := &pythonData{
-		version:    version,
-		autoTLSKey: autoTLSKey,
+		version:        version,
+		freeThreaded:   freeThreaded,
+		autoTLSKey:     autoTLSKey,
+		qualifiedNames: opts.QualifiedNames,
+		greenlet:       opts.Greenlet,
 	}
 	vms := &pd.vmStructs
 
 	// Introspection data not available for these structures
+	vms.PyTypeObject.Name = 24
 	vms.PyTypeObject.BasicSize = 32
+	vms.PyTypeObject.Flags = 168
 	vms.PyTypeObject.Members = 240
+	vms.PyTypeObject.Dict = 264
+	vms.PyTypeObject.Mro = 344
 	vms.PyMemberDef.Name = 0
 	vms.PyMemberDef.Offset = 16
 	vms.PyMemberDef.Sizeof = 40
 
 	vms.PyASCIIObject.Data = 48
 	vms.PyVarObject.ObSize = 16
+	vms.PyTupleObject.ObItem = 24
 	vms.PyThreadState.Frame = 24
 
+	// Python < 3.11 layouts to find the class defining a method
+	vms.PyDictObject.Keys = 32
+	vms.PyDictKeysObject.Size = 8
+	vms.PyDictKeysObject.NEntries = 32
+	vms.PyDictKeysObject.Indices = 40
+	vms.PyDictKeyEntry.Sizeof = 24
+	vms.PyDictKeyEntry.Value = 16
+	vms.PyFunctionObject.Code = 16
+	vms.PyClassMethodObject.Callable = 16
+
 	switch version {
 	case pythonVer(3, 11):
 		// Starting with 3.11 we no longer can extract needed information from
@@ -822,6 +1277,21 @@ func Loader(ebpf interpreter.EbpfHandler, info *interpreter.LoaderInfo) (interpr
 		vms.PyASCIIObject.Data = 40
 	}
 
+	if freeThreaded {
+		// The larger PyObject_HEAD moves the members of all objects. The layouts
+		// of _PyInterpreterFrame and PyThreadState are the same as in the default
+		// build, and the thread state is still found per thread via autoTSSkey.
+		vms.PyTypeObject.Name += freeThreadedHeadExtra
+		vms.PyTypeObject.BasicSize += freeThreadedHeadExtra
+		vms.PyTypeObject.Flags += freeThreadedHeadExtra
+		vms.PyTypeObject.Members += freeThreadedHeadExtra
+		vms.PyTypeObject.Dict += freeThreadedHeadExtra
+		vms.PyTypeObject.Mro += freeThreadedHeadExtra
+		vms.PyVarObject.ObSize += freeThreadedHeadExtra
+		vms.PyTupleObject.ObItem += freeThreadedHeadExtra
+		vms.PyASCIIObject.Data += freeThreadedHeadExtra
+	}
+
 	// Read the introspection data from objects types that have it
 	if err := pd.readIntrospectionData(ef, "PyCode_Type", &vms.PyCodeObject); err != nil {
 		return nil, err
@@ -833,6 +1303,14 @@ func Loader(ebpf interpreter.EbpfHandler, info *interpreter.LoaderInfo) (interpr
 		return nil, err
 	}
 
+	if opts.Asyncio {
+		pd.asyncio = newAsyncioStructs(version, freeThreaded, vms.PyASCIIObject.Data)
+		if pd.asyncio == nil {
+			log.Warnf("asyncio tasks are not supported on Python %d.%d (need >= 3.11)",
+				major, minor)
+		}
+	}
+
 	if err := ebpf.UpdateInterpreterOffsets(support.ProgUnwindPython, info.FileID(),
 		interpRanges); err != nil {
 		return nil, err
@@ -4,10 +4,17 @@
 package python
 
 import (
+	"bytes"
+	"encoding/binary"
 	"regexp"
 	"testing"
 
+	"github.com/elastic/go-freelru"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/remotememory"
 )
 
 func TestFrozenNameToFileName(t *testing.T) {
@@ -59,14 +66,16 @@ func TestPythonRegexs(t *testing.T) {
 	shouldMatch := map[*regexp.Regexp][]string{
 		pythonRegex: {
 			"python3.6", "./python3.6", "/foo/bar/python3.6", "./foo/bar/python3.6",
-			"python3.7", "./python3.7", "/foo/bar/python3.7", "./foo/bar/python3.7"},
+			"python3.7", "./python3.7", "/foo/bar/python3.7", "./foo/bar/python3.7",
+			"python3.13t", "/usr/bin/python3.13t"},
 		libpythonRegex: {
 			"libpython3.6", "./libpython3.6", "/foo/bar/libpython3.6",
 			"./foo/bar/libpython3.6", "/foo/bar/libpython3.6.so.1",
 			"/usr/lib64/libpython3.6m.so.1.0",
 			"libpython3.7", "./libpython3.7", "/foo/bar/libpython3.7",
 			"./foo/bar/libpython3.7", "/foo/bar/libpython3.7.so.1",
-			"/foo/bar/libpython3.7m.so.1"},
+			"/foo/bar/libpython3.7m.so.1",
+			"/usr/lib/libpython3.13t.so.1.0"},
 	}
 
 	for regex, strings := range shouldMatch {
@@ -79,7 +88,8 @@ func TestPythonRegexs(t *testing.T) {
 	shouldNotMatch := map[*regexp.Regexp][]string{
 		pythonRegex: {
 			"foopython3.6", "pyt hon3.6", "pyth/on3.6", "python",
-			"foopython3.7", "pyt hon3.7", "pyth/on3.7", "python"},
+			"foopython3.7", "pyt hon3.7", "pyth/on3.7", "python",
+			"python3.13x"},
 		libpythonRegex: {
 			"foolibpython3.6", "lib python3.6", "lib/python3.6",
 			"foolibpython3.7", "lib python3.7", "lib/python3.7"},
@@ -92,3 +102,128 @@ func TestPythonRegexs(t *testing.T) {
 		}
 	}
 }
+
+func TestPythonRegexABIFlags(t *testing.T) {
+	tests := map[string]string{
+		"/usr/bin/python3.13":             "",
+		"/usr/bin/python3.13t":            "t",
+		"/usr/lib/libpython3.13.so.1.0":   "",
+		"/usr/lib/libpython3.13t.so.1.0":  "t",
+		"/usr/lib64/libpython3.6m.so.1.0": "",
+	}
+	for name, flags := range tests {
+		matches := libpythonRegex.FindStringSubmatch(name)
+		if matches == nil {
+			matches = pythonRegex.FindStringSubmatch(name)
+		}
+		if assert.NotNil(t, matches, name) {
+			assert.Equal(t, flags, matches[3], name)
+		}
+	}
+}
+
+func TestPythonModuleName(t *testing.T) {
+	tests := map[string]string{
+		"/usr/lib/python3.9/json/decoder.py":                         "json.decoder",
+		"/usr/lib/python3.13t/asyncio/__init__.py":                   "asyncio",
+		"/venv/lib/python3.10/site-packages/requests/api.py":         "requests.api",
+		"/usr/lib/python3/dist-packages/gunicorn/workers/ggevent.py": "gunicorn.workers.ggevent",
+		"/usr/local/lib/python3.8/site-packages/flask/__init__.py":   "flask",
+		"/srv/app/server.py":                                         "server",
+		"/srv/app/handlers/__init__.py":                              "handlers",
+		"<frozen importlib._bootstrap>":                              "importlib._bootstrap",
+		"<string>":                                                   "",
+		"/usr/lib/python3.9/lib-dynload/_json.cpython-39-x86_64.so":  "",
+	}
+	for sourcePath, module := range tests {
+		assert.Equal(t, module, pythonModuleName(sourcePath), sourcePath)
+	}
+}
+
+func TestClassMethod(t *testing.T) {
+	mem := make([]byte, 0x2000)
+	putPtr := func(addr, value uint64) {
+		binary.LittleEndian.PutUint64(mem[addr:], value)
+	}
+	// class defines the heap type at addr with the name at name, the dictionary
+	// at dict and the method resolution order at mro.
+	class := func(addr, name, dict, mro uint64, bases ...uint64) {
+		putPtr(addr+24, name)
+		putPtr(addr+168, pyTPFlagsHeapType)
+		putPtr(addr+264, dict)
+		putPtr(addr+344, mro)
+		putPtr(mro+16, uint64(len(bases)))
+		for i, base := range bases {
+			putPtr(mro+24+uint64(i)*8, base)
+		}
+	}
+	// dict defines the dictionary at addr holding the given values.
+	dict := func(addr uint64, values ...uint64) {
+		keys := addr + 0x40
+		putPtr(addr+32, keys)
+		putPtr(keys+8, 8)
+		putPtr(keys+32, uint64(len(values)))
+		for i, value := range values {
+			putPtr(keys+40+8+uint64(i)*24+16, value)
+		}
+	}
+
+	copy(mem[0x100:], "Base\x00")
+	copy(mem[0x110:], "app.Derived\x00")
+	class(0x400, 0x100, 0x1000, 0x1100, 0x400, 0xc00)
+	class(0x800, 0x110, 0x1200, 0x1300, 0x800, 0x400, 0xc00)
+	// Functions of the code objects at 0x1800, 0x1880 and 0x1a00, and a
+	// classmethod wrapping the one of 0x1880.
+	putPtr(0x1900+16, 0x1800)
+	putPtr(0x1980+16, 0x1880)
+	putPtr(0x19c0+16, 0x1a00)
+	putPtr(0x1940+16, 0x1980)
+	dict(0x1000, 0x1900, 0x1940)
+	dict(0x1200, 0x19c0)
+
+	d := &pythonData{}
+	vms := &d.vmStructs
+	vms.PyTypeObject.Name = 24
+	vms.PyTypeObject.Flags = 168
+	vms.PyTypeObject.Dict = 264
+	vms.PyTypeObject.Mro = 344
+	vms.PyVarObject.ObSize = 16
+	vms.PyTupleObject.ObItem = 24
+	vms.PyDictObject.Keys = 32
+	vms.PyDictKeysObject.Size = 8
+	vms.PyDictKeysObject.NEntries = 32
+	vms.PyDictKeysObject.Indices = 40
+	vms.PyDictKeyEntry.Sizeof = 24
+	vms.PyDictKeyEntry.Value = 16
+	vms.PyFunctionObject.Code = 16
+	vms.PyClassMethodObject.Callable = 16
+	classMethods, err := freelru.New[classMethodKey, *pythonCodeObject](16,
+		classMethodKey.hash32)
+	require.NoError(t, err)
+	p := &pythonInstance{
+		d:            d,
+		rm:           remotememory.RemoteMemory{ReaderAt: bytes.NewReader(mem)},
+		classMethods: classMethods,
+	}
+
+	run := &pythonCodeObject{name: "run", fileID: libpf.NewFileID(1, 2)}
+	baseRun := p.classMethod(run, 0x1800, 0x400)
+	assert.Equal(t, "Base.run", baseRun.name)
+	assert.NotEqual(t, run.fileID, baseRun.fileID)
+	assert.Equal(t, "run", run.name)
+
+	// Inherited methods are attributed to the class defining them.
+	derivedRun := p.classMethod(run, 0x1800, 0x800)
+	assert.Equal(t, "Base.run", derivedRun.name)
+	assert.Equal(t, baseRun.fileID, derivedRun.fileID)
+	assert.Same(t, derivedRun, p.classMethod(run, 0x1800, 0x800))
+
+	create := &pythonCodeObject{name: "create", fileID: libpf.NewFileID(3, 4)}
+	assert.Equal(t, "Base.create", p.classMethod(create, 0x1880, 0x800).name)
+
+	stop := &pythonCodeObject{name: "stop", fileID: libpf.NewFileID(5, 6)}
+	assert.Equal(t, "Derived.stop", p.classMethod(stop, 0x1a00, 0x800).name)
+	// The class of the argument does not define the method.
+	assert.Same(t, stop, p.classMethod(stop, 0x1a00, 0x400))
+	assert.Same(t, stop, p.classMethod(stop, 0x1a00, 0x1f00))
+}
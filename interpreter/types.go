@@ -151,3 +151,12 @@ type Instance interface {
 	// the counters to their initial value.
 	GetAndResetMetrics() ([]metrics.Metric, error)
 }
+
+// PrecededSymbolizer is implemented by Instances whose eBPF unwinder pushes pseudo
+// frames that carry data for the frame following them.
+type PrecededSymbolizer interface {
+	// SymbolizePreceded symbolizes frame like Instance.Symbolize, given the frame
+	// preceding it in the same trace.
+	SymbolizePreceded(symbolReporter reporter.SymbolReporter, prev, frame *host.Frame,
+		trace *libpf.Trace) error
+}
@@ -6,8 +6,11 @@ package libpf // import "go.opentelemetry.io/ebpf-profiler/libpf"
 import (
 	"bufio"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
+	"syscall"
 
 	lru "github.com/elastic/go-freelru"
 	log "github.com/sirupsen/logrus"
@@ -17,6 +20,9 @@ var (
 	cgroupv2PathPattern = regexp.MustCompile(`0:.*?:(.*)`)
 )
 
+// cgroupv2Mount is the mount point of the cgroupv2 hierarchy.
+const cgroupv2Mount = "/sys/fs/cgroup"
+
 // LookupCgroupv2 returns the cgroupv2 ID for pid.
 func LookupCgroupv2(cgrouplru *lru.SyncedLRU[PID, string], pid PID) (string, error) {
 	id, ok := cgrouplru.Get(pid)
@@ -57,3 +63,42 @@ func LookupCgroupv2(cgrouplru *lru.SyncedLRU[PID, string], pid PID) (string, err
 
 	return genericCgroupv2, nil
 }
+
+// Cgroupv2IDs returns the IDs of the cgroupv2 cgroup at path and of all its
+// descendants. The path is relative to the cgroupv2 hierarchy, in the format
+// returned by LookupCgroupv2. The ID of a cgroup is the inode number of its
+// directory, which is also what bpf_get_current_cgroup_id returns.
+func Cgroupv2IDs(path string) ([]uint64, error) {
+	return cgroupv2IDs(filepath.Join(cgroupv2Mount, path))
+}
+
+func cgroupv2IDs(dir string) ([]uint64, error) {
+	var ids []uint64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Cgroups may disappear while walking the hierarchy.
+			if path != dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("unexpected file info for %s", path)
+		}
+		ids = append(ids, stat.Ino)
+		return nil
+	})
+	return ids, err
+}
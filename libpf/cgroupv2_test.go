@@ -0,0 +1,36 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package libpf
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupv2IDs(t *testing.T) {
+	root := t.TempDir()
+	tenant := filepath.Join(root, "tenant.slice")
+	container := filepath.Join(tenant, "container.scope")
+	require.NoError(t, os.MkdirAll(container, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(tenant, "cgroup.procs"), nil, 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "other.slice"), 0o755))
+
+	inode := func(path string) uint64 {
+		var st syscall.Stat_t
+		require.NoError(t, syscall.Stat(path, &st))
+		return st.Ino
+	}
+
+	ids, err := cgroupv2IDs(tenant)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint64{inode(tenant), inode(container)}, ids)
+
+	_, err = cgroupv2IDs(filepath.Join(root, "missing.slice"))
+	require.Error(t, err)
+}
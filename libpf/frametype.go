@@ -51,6 +51,8 @@ const (
 	DotnetFrame FrameType = support.FrameMarkerDotnet
 	// GoFrame identifies Go frames.
 	GoFrame FrameType = support.FrameMarkerGo
+	// JITFrame identifies JIT frames symbolized with perf map or jitdump files.
+	JITFrame FrameType = support.FrameMarkerJIT
 	// AbortFrame identifies frames that report that further unwinding was aborted due to an error.
 	AbortFrame FrameType = support.FrameMarkerAbort
 )
@@ -33,6 +33,8 @@ const (
 	Dotnet InterpreterType = support.FrameMarkerDotnet
 	// Go identifies Go code.
 	Go InterpreterType = support.FrameMarkerGo
+	// JIT identifies JIT code described by perf map or jitdump files.
+	JIT InterpreterType = support.FrameMarkerJIT
 )
 
 // Pseudo-interpreters without a corresponding frame type.
@@ -68,6 +70,7 @@ var interpreterTypeToString = map[InterpreterType]string{
 	Dotnet:  "dotnet",
 	APMInt:  "apm-integration",
 	Go:      "go",
+	JIT:     "jit",
 }
 
 var stringToInterpreterType = make(map[string]InterpreterType, len(interpreterTypeToString))
@@ -63,3 +63,10 @@ type SourceLineno uint64
 
 // Origin determines the source of a trace.
 type Origin int
+
+// WakeupLink links the off-CPU trace of a task to the wakeup trace of the task
+// that woke it up. The zero value indicates that the waker is unknown.
+type WakeupLink struct {
+	WakerPID, WakerTID PID
+	WakeePID, WakeeTID PID
+}
@@ -550,6 +550,53 @@ func (f *File) TLSDescriptors() (map[string]libpf.Address, error) {
 
 func (f *File) insertTLSDescriptorsForSection(descs map[string]libpf.Address,
 	relaSection *Section) error {
+	return f.visitRelocations(relaSection, func(ty uint32) bool {
+		return (f.Machine == elf.EM_AARCH64 && elf.R_AARCH64(ty) == elf.R_AARCH64_TLSDESC) ||
+			(f.Machine == elf.EM_X86_64 && elf.R_X86_64(ty) == elf.R_X86_64_TLSDESC)
+	}, func(rela *elf.Rela64, _ *elf.Sym64, symStr string) {
+		descs[symStr] = libpf.Address(rela.Off)
+	})
+}
+
+// TLSModuleIDAddress returns the address of the GOT entry the dynamic linker
+// fills with the TLS module ID of the ELF, as used by the general and local
+// dynamic TLS access models.
+func (f *File) TLSModuleIDAddress() (libpf.Address, error) {
+	if err := f.LoadSections(); err != nil {
+		return 0, err
+	}
+
+	addr := libpf.Address(0)
+	for i := range f.Sections {
+		section := &f.Sections[i]
+		// NOTE: SHT_REL is not relevant for the archs that we care about
+		if section.Type != elf.SHT_RELA {
+			continue
+		}
+		err := f.visitRelocations(section, func(ty uint32) bool {
+			return (f.Machine == elf.EM_AARCH64 &&
+				elf.R_AARCH64(ty) == elf.R_AARCH64_TLS_DTPMOD64) ||
+				(f.Machine == elf.EM_X86_64 && elf.R_X86_64(ty) == elf.R_X86_64_DTPMOD64)
+		}, func(rela *elf.Rela64, sym *elf.Sym64, _ string) {
+			// The module ID of a symbol defined elsewhere is not of this ELF.
+			if rela.Info>>32 == 0 || elf.SectionIndex(sym.Shndx) != elf.SHN_UNDEF {
+				addr = libpf.Address(rela.Off)
+			}
+		})
+		if err != nil {
+			return 0, err
+		}
+		if addr != 0 {
+			return addr, nil
+		}
+	}
+	return 0, errors.New("no TLS module ID relocation found")
+}
+
+// visitRelocations calls visitor for the relocations of the given types in the
+// relocation section, along with their symbol and its name.
+func (f *File) visitRelocations(relaSection *Section, matchType func(ty uint32) bool,
+	visitor func(rela *elf.Rela64, sym *elf.Sym64, symStr string)) error {
 	if relaSection.Link > uint32(len(f.Sections)) {
 		return errors.New("rela section link is out-of-bounds")
 	}
@@ -590,9 +637,7 @@ func (f *File) insertTLSDescriptorsForSection(descs map[string]libpf.Address,
 	for i := 0; i < len(relaData); i += relaSz {
 		rela := (*elf.Rela64)(unsafe.Pointer(&relaData[i]))
 
-		ty := rela.Info & 0xffff
-		if !(f.Machine == elf.EM_AARCH64 && elf.R_AARCH64(ty) == elf.R_AARCH64_TLSDESC) &&
-			!(f.Machine == elf.EM_X86_64 && elf.R_X86_64(ty) == elf.R_X86_64_TLSDESC) {
+		if !matchType(uint32(rela.Info & 0xffff)) {
 			continue
 		}
 
@@ -609,7 +654,7 @@ func (f *File) insertTLSDescriptorsForSection(descs map[string]libpf.Address,
 			return errors.New("failed to get relocation name string")
 		}
 
-		descs[symStr] = libpf.Address(rela.Off)
+		visitor(rela, &sym, symStr)
 	}
 
 	return nil
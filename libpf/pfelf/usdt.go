@@ -0,0 +1,135 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package pfelf // import "go.opentelemetry.io/ebpf-profiler/libpf/pfelf"
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// stapsdtNoteType is the ELF note type of SystemTap SDT (USDT) probes.
+const stapsdtNoteType = 3
+
+// USDTProbe describes a statically defined tracing probe found in the
+// .note.stapsdt section, as defined in
+// https://sourceware.org/systemtap/wiki/UserSpaceProbeImplementation
+type USDTProbe struct {
+	// Provider is the name of the probe provider, e.g. "python".
+	Provider string
+	// Name is the name of the probe, e.g. "function__entry".
+	Name string
+	// Address is the virtual address of the probe location.
+	Address uint64
+	// SemaphoreAddress is the virtual address of the semaphore that enables
+	// the probe, or 0 if the probe has no semaphore.
+	SemaphoreAddress uint64
+	// Arguments describes the probe arguments in assembler syntax.
+	Arguments string
+}
+
+// USDTProbes returns the USDT probes defined in the .note.stapsdt section.
+func (f *File) USDTProbes() ([]USDTProbe, error) {
+	notes := f.Section(".note.stapsdt")
+	if notes == nil {
+		return nil, nil
+	}
+	data, err := notes.Data(maxBytesLargeSection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .note.stapsdt: %v", err)
+	}
+
+	// The probe addresses need to be adjusted if the file was prelinked after
+	// the notes were created. The note records the link time address of the
+	// .stapsdt.base section, which allows to compute the offset.
+	var baseAddress uint64
+	if base := f.Section(".stapsdt.base"); base != nil {
+		baseAddress = base.Addr
+	}
+
+	return parseUSDTNotes(data, baseAddress)
+}
+
+// parseUSDTNotes parses the content of a .note.stapsdt section. baseAddress is
+// the address of the .stapsdt.base section, or 0 if there is none.
+func parseUSDTNotes(data []byte, baseAddress uint64) ([]USDTProbe, error) {
+	var probes []USDTProbe
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return nil, errors.New("truncated note header")
+		}
+		nameSize := binary.LittleEndian.Uint32(data[0:4])
+		descSize := binary.LittleEndian.Uint32(data[4:8])
+		noteType := binary.LittleEndian.Uint32(data[8:12])
+
+		// Name and descriptor are padded to 4 byte alignment.
+		nameEnd := 12 + uint64(nameSize)
+		descStart := alignUp(nameEnd, 4)
+		descEnd := descStart + uint64(descSize)
+		if descEnd > uint64(len(data)) {
+			return nil, errors.New("truncated note")
+		}
+		name := data[12:nameEnd]
+		desc := data[descStart:descEnd]
+		data = data[min(alignUp(descEnd, 4), uint64(len(data))):]
+
+		if noteType != stapsdtNoteType || !bytes.Equal(name, []byte("stapsdt\x00")) {
+			continue
+		}
+
+		probe, err := parseUSDTDesc(desc, baseAddress)
+		if err != nil {
+			return nil, err
+		}
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// parseUSDTDesc parses the descriptor of a stapsdt note. It holds the probe
+// address, the link time address of .stapsdt.base and the semaphore address,
+// followed by the NUL terminated provider, name and arguments.
+func parseUSDTDesc(desc []byte, baseAddress uint64) (USDTProbe, error) {
+	if len(desc) < 3*8 {
+		return USDTProbe{}, errors.New("truncated stapsdt note")
+	}
+	probe := USDTProbe{
+		Address:          binary.LittleEndian.Uint64(desc[0:8]),
+		SemaphoreAddress: binary.LittleEndian.Uint64(desc[16:24]),
+	}
+	if noteBase := binary.LittleEndian.Uint64(desc[8:16]); baseAddress != 0 && noteBase != 0 {
+		probe.Address += baseAddress - noteBase
+	}
+
+	strs := bytes.SplitN(desc[24:], []byte{0}, 4)
+	if len(strs) < 3 {
+		return USDTProbe{}, errors.New("truncated stapsdt note strings")
+	}
+	probe.Provider = string(strs[0])
+	probe.Name = string(strs[1])
+	probe.Arguments = string(strs[2])
+	return probe, nil
+}
+
+// alignUp rounds value up to a multiple of align, which must be a power of 2.
+func alignUp(value, align uint64) uint64 {
+	return (value + align - 1) &^ (align - 1)
+}
+
+// VirtualAddressToFileOffset converts a virtual address to the offset in the
+// file it is loaded from, e.g. to attach uprobes.
+func (f *File) VirtualAddressToFileOffset(addr uint64) (uint64, bool) {
+	for i := range f.Progs {
+		p := &f.Progs[i]
+		if p.Type != elf.PT_LOAD {
+			continue
+		}
+		if addr >= p.Vaddr && addr < p.Vaddr+p.Filesz {
+			return addr - p.Vaddr + p.Off, true
+		}
+	}
+	return 0, false
+}
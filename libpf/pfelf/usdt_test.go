@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package pfelf
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stapsdtNote builds a .note.stapsdt entry as emitted by <sys/sdt.h>.
+func stapsdtNote(noteType uint32, pc, base, semaphore uint64, strs string) []byte {
+	desc := binary.LittleEndian.AppendUint64(nil, pc)
+	desc = binary.LittleEndian.AppendUint64(desc, base)
+	desc = binary.LittleEndian.AppendUint64(desc, semaphore)
+	desc = append(desc, strs...)
+
+	name := []byte("stapsdt\x00")
+	note := binary.LittleEndian.AppendUint32(nil, uint32(len(name)))
+	note = binary.LittleEndian.AppendUint32(note, uint32(len(desc)))
+	note = binary.LittleEndian.AppendUint32(note, noteType)
+	note = append(note, name...)
+	note = append(note, desc...)
+	for len(note)%4 != 0 {
+		note = append(note, 0)
+	}
+	return note
+}
+
+func TestParseUSDTNotes(t *testing.T) {
+	var data []byte
+	data = append(data, stapsdtNote(3, 0x1234, 0x5000, 0,
+		"python\x00function__entry\x008@%rbx 8@%rbp -4@%eax\x00")...)
+	// Notes of other types are skipped.
+	data = append(data, stapsdtNote(1, 0x9999, 0x5000, 0, "x\x00y\x00\x00")...)
+	data = append(data, stapsdtNote(3, 0x2000, 0x5000, 0x8010,
+		"libc\x00memory_malloc_retry\x008@%rbp\x00")...)
+
+	probes, err := parseUSDTNotes(data, 0x6000)
+	require.NoError(t, err)
+	assert.Equal(t, []USDTProbe{
+		{
+			Provider:  "python",
+			Name:      "function__entry",
+			Address:   0x2234,
+			Arguments: "8@%rbx 8@%rbp -4@%eax",
+		},
+		{
+			Provider:         "libc",
+			Name:             "memory_malloc_retry",
+			Address:          0x3000,
+			SemaphoreAddress: 0x8010,
+			Arguments:        "8@%rbp",
+		},
+	}, probes)
+
+	// Without .stapsdt.base the addresses are used as is.
+	probes, err = parseUSDTNotes(data, 0)
+	require.NoError(t, err)
+	require.Len(t, probes, 2)
+	assert.Equal(t, uint64(0x1234), probes[0].Address)
+
+	_, err = parseUSDTNotes(data[:len(data)-8], 0)
+	require.Error(t, err)
+}
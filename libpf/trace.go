@@ -14,6 +14,21 @@ type Trace struct {
 	MappingEnd         []Address
 	MappingFileOffsets []uint64
 	Hash               TraceHash
+	// CustomLabels holds labels attached to the trace during symbolization,
+	// e.g. the name of the asyncio task a Python stack belongs to. May be nil.
+	CustomLabels map[string]string
+	// Dynamic is set if the symbolization depends on process state that may
+	// change while the eBPF trace stays the same, e.g. the await chain of an
+	// asyncio task. Such traces are not cached by their eBPF trace hash.
+	Dynamic bool
+}
+
+// SetCustomLabel attaches a label to the trace.
+func (trace *Trace) SetCustomLabel(key, value string) {
+	if trace.CustomLabels == nil {
+		trace.CustomLabels = make(map[string]string)
+	}
+	trace.CustomLabels[key] = value
 }
 
 // AppendFrame appends a frame to the columnar frame array without mapping information.
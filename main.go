@@ -7,18 +7,24 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	//nolint:gosec
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"golang.org/x/sys/unix"
 
+	"go.opentelemetry.io/ebpf-profiler/containermetadata"
 	"go.opentelemetry.io/ebpf-profiler/internal/controller"
 	"go.opentelemetry.io/ebpf-profiler/internal/helpers"
 	"go.opentelemetry.io/ebpf-profiler/reporter"
 	"go.opentelemetry.io/ebpf-profiler/times"
+	"go.opentelemetry.io/ebpf-profiler/tracer"
 	"go.opentelemetry.io/ebpf-profiler/vc"
 
 	log "github.com/sirupsen/logrus"
@@ -108,15 +114,89 @@ func mainWithExitCode() exitCode {
 		return exitFailure
 	}
 
-	// hostname and sourceIP will be populated from the root namespace.
-	hostname, sourceIP, err := helpers.GetHostnameAndSourceIP(cfg.CollAgentAddr)
+	rep, err := newReporter(cfg, intervals, kernelVersion)
 	if err != nil {
 		log.Error(err)
 		return exitFailure
 	}
+	cfg.Reporter = rep
+
+	log.Infof("Starting OTEL profiling agent %s (revision %s, build timestamp %s)",
+		vc.Version(), vc.Revision(), vc.BuildTimestamp())
+
+	ctlr := controller.New(cfg)
+	err = ctlr.Start(ctx)
+	if err != nil {
+		return failure("Failed to start agent controller: %v", err)
+	}
+	defer ctlr.Shutdown()
+
+	if cfg.MaxSamplesPerSecond > cfg.SamplesPerSecond {
+		handleSamplingSignals(ctx, ctlr, cfg)
+	}
+
+	var timeout <-chan time.Time
+	if cfg.Duration > 0 {
+		log.Infof("Profiling for %v", cfg.Duration)
+		timer := time.NewTimer(cfg.Duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Block waiting for a signal to indicate the program should terminate, for
+	// all processes profiling is restricted to to exit, or for the duration to
+	// pass.
+	select {
+	case <-ctx.Done():
+	case <-ctlr.TargetsExited():
+	case <-timeout:
+	}
+
+	log.Info("Exiting ...")
+	return exitSuccess
+}
+
+// handleSamplingSignals spawns a goroutine that raises the sampling frequency to
+// the maximum on SIGUSR1 and restores the configured one on SIGUSR2, e.g. to
+// collect more samples during an incident.
+func handleSamplingSignals(ctx context.Context, ctlr *controller.Controller,
+	cfg *controller.Config) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGUSR1, unix.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-sigs:
+				samplesPerSecond := cfg.SamplesPerSecond
+				if sig == unix.SIGUSR1 {
+					samplesPerSecond = cfg.MaxSamplesPerSecond
+				}
+				if err := ctlr.SetSamplingFrequency(samplesPerSecond); err != nil {
+					log.Errorf("Failed to change sampling frequency: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// newReporter creates the reporter selected by the command line arguments.
+// If several destinations are selected, the profiles are sent to all of them.
+func newReporter(cfg *controller.Config, intervals *times.Times,
+	kernelVersion string) (reporter.Reporter, error) {
+	kernelEvents, err := tracer.ParseKernelEventSpecs(cfg.KernelEvents)
+	if err != nil {
+		return nil, err
+	}
+	softwareEvents, err := tracer.ParseSoftwareEventSpecs(cfg.SoftwareEvents)
+	if err != nil {
+		return nil, err
+	}
 
-	rep, err := reporter.NewOTLP(&reporter.Config{
-		CollAgentAddr:            cfg.CollAgentAddr,
+	baseCfg := reporter.Config{
 		DisableTLS:               cfg.DisableTLS,
 		MaxRPCMsgSize:            32 << 20, // 32 MiB
 		MaxGRPCRetries:           5,
@@ -130,30 +210,119 @@ func mainWithExitCode() exitCode {
 		CGroupCacheElements: 1024,
 		SamplesPerSecond:    cfg.SamplesPerSecond,
 		KernelVersion:       kernelVersion,
-		HostName:            hostname,
-		IPAddress:           sourceIP,
-	})
-	if err != nil {
-		log.Error(err)
-		return exitFailure
+		EventOrigins: append(tracer.SoftwareEventOrigins(softwareEvents),
+			tracer.KernelEventOrigins(kernelEvents)...),
 	}
-	cfg.Reporter = rep
 
-	log.Infof("Starting OTEL profiling agent %s (revision %s, build timestamp %s)",
-		vc.Version(), vc.Revision(), vc.BuildTimestamp())
+	var containerMetadata containermetadata.Combined
+	if cfg.KubeletURL != "" {
+		kubelet, err := containermetadata.NewKubelet(containermetadata.KubeletConfig{
+			URL:       cfg.KubeletURL,
+			TokenFile: cfg.KubeletTokenFile,
+			CAFile:    cfg.KubeletCAFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+		containerMetadata = append(containerMetadata, kubelet)
+	}
+	if cfg.DockerSocket != "" {
+		containerMetadata = append(containerMetadata,
+			containermetadata.NewDocker(cfg.DockerSocket))
+	}
+	switch len(containerMetadata) {
+	case 0:
+	case 1:
+		baseCfg.ContainerMetadata = containerMetadata[0]
+	default:
+		baseCfg.ContainerMetadata = containerMetadata
+	}
 
-	ctlr := controller.New(cfg)
-	err = ctlr.Start(ctx)
+	var reporters []reporter.Reporter
+
+	if cfg.FoldedOutput != "" {
+		repCfg := baseCfg
+		repCfg.FoldedOutput = cfg.FoldedOutput
+		repCfg.FoldedGroupBy = cfg.FoldedGroupBy
+		rep, err := reporter.NewFolded(&repCfg)
+		if err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, rep)
+	}
+
+	if cfg.Output != "" {
+		repCfg := baseCfg
+		repCfg.PprofOutputFile = cfg.Output
+		rep, err := reporter.NewPprof(&repCfg)
+		if err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, rep)
+	}
+
+	if cfg.PprofOutputDir != "" {
+		repCfg := baseCfg
+		repCfg.PprofOutputDir = cfg.PprofOutputDir
+		repCfg.PprofMaxBytes = int64(cfg.PprofMaxBytes)
+		repCfg.PprofMaxAge = cfg.PprofMaxAge
+		rep, err := reporter.NewPprof(&repCfg)
+		if err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, rep)
+	}
+
+	collAgentHeaders, err := cfg.CollectionAgentHeaders()
 	if err != nil {
-		return failure("Failed to start agent controller: %v", err)
+		return nil, err
 	}
-	defer ctlr.Shutdown()
 
-	// Block waiting for a signal to indicate the program should terminate
-	<-ctx.Done()
+	collAgentAddrs := cfg.CollectionAgents()
+	if len(collAgentAddrs) == 0 && len(reporters) == 0 {
+		// Keep the behavior of reporting an error for the missing address.
+		collAgentAddrs = []string{""}
+	}
+	for i, addr := range collAgentAddrs {
+		// The collection agent address may be an OTLP/HTTP URL, but only its host
+		// is relevant to determine the source IP.
+		collAgentHost := addr
+		if u, err := url.Parse(collAgentHost); err == nil && u.Host != "" {
+			collAgentHost = u.Host
+		}
+
+		// hostname and sourceIP will be populated from the root namespace.
+		hostname, sourceIP, err := helpers.GetHostnameAndSourceIP(collAgentHost)
+		if err != nil {
+			return nil, err
+		}
 
-	log.Info("Exiting ...")
-	return exitSuccess
+		repCfg := baseCfg
+		repCfg.CollAgentAddr = addr
+		repCfg.Protocol = cfg.CollAgentProtocol
+		repCfg.HTTPHeaders = collAgentHeaders
+		repCfg.HTTPCompression = cfg.CollAgentCompression
+		repCfg.HostName = hostname
+		repCfg.IPAddress = sourceIP
+		repCfg.SpoolDir = cfg.SpoolDir
+		if cfg.SpoolDir != "" && len(collAgentAddrs) > 1 {
+			// Every collection agent needs its own spool.
+			repCfg.SpoolDir = filepath.Join(cfg.SpoolDir, strconv.Itoa(i))
+		}
+		repCfg.SpoolMaxBytes = int64(cfg.SpoolMaxBytes)
+		repCfg.SpoolMaxAge = cfg.SpoolMaxAge
+
+		rep, err := reporter.NewOTLP(&repCfg)
+		if err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, rep)
+	}
+
+	if len(reporters) == 1 {
+		return reporters[0], nil
+	}
+	return reporter.NewFanout(reporters...)
 }
 
 func failure(msg string, args ...interface{}) exitCode {
@@ -641,6 +641,36 @@ const (
 	// Number of Go frames that failed symbolization
 	IDGoSymbolizationFailure = 277
 
+	// Number of profiles waiting in the on-disk spool for delivery
+	IDReporterSpoolBacklog = 278
+
+	// Total size of the profiles waiting in the on-disk spool for delivery
+	IDReporterSpoolBacklogBytes = 279
+
+	// Number of spooled profiles dropped because of the spool size or age limits
+	IDReporterSpoolDropped = 280
+
+	// Number of traces dropped by the trace filter rules
+	IDTraceFiltered = 281
+
+	// Number of off-CPU intervals dropped for being shorter than the minimum duration
+	IDOffCPUDroppedShortIntervals = 282
+
+	// Number of online CPUs with attached sampling perf events
+	IDSampledCPUs = 283
+
+	// Number of successfully symbolized JIT frames
+	IDJITSymbolizationSuccess = 284
+
+	// Number of JIT frames that failed symbolization
+	IDJITSymbolizationFailure = 285
+
+	// Number of spooled profiles dropped because they could not be decoded
+	IDReporterSpoolCorrupted = 286
+
+	// Number of spooled profiles dropped because the backend rejected them
+	IDReporterSpoolRejected = 287
+
 	// max number of ID values, keep this as *last entry*
-	IDMax = 278
+	IDMax = 288
 )
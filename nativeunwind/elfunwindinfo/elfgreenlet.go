@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package elfunwindinfo // import "go.opentelemetry.io/ebpf-profiler/nativeunwind/elfunwindinfo"
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	sdtypes "go.opentelemetry.io/ebpf-profiler/nativeunwind/stackdeltatypes"
+)
+
+// greenletFunctionsStopDelta lists the functions of the greenlet Python extension
+// module for which we should not attempt to unwind further. They are matched as
+// part of the symbol name, which is mangled since greenlet 2.0 is written in C++.
+var greenletFunctionsStopDelta = []string{
+	// Greenlets are started on top of the C stack of the greenlet starting
+	// them. The stack below the bootstrap belongs to other greenlets, which
+	// may have switched away and have their stack saved to the heap.
+	"g_initialstub",
+	// The stack switch itself replaces the stack pointer.
+	"slp_switch",
+}
+
+// parseGreenlet marks the greenlet bootstrap and stack switch functions as stack
+// roots, if the ELF is the greenlet extension module. The functions are found in
+// the symbol table, so this does not work for stripped modules.
+func (ee *elfExtractor) parseGreenlet(filter *extractionFilter) {
+	ef := ee.file
+	if _, err := ef.LookupSymbol("PyInit__greenlet"); err != nil {
+		// Before greenlet 1.0 the extension module was the top level module.
+		if _, err = ef.LookupSymbol("PyInit_greenlet"); err != nil {
+			return
+		}
+	}
+	syms, err := ef.ReadSymbols()
+	if err != nil {
+		return
+	}
+	syms.VisitAll(func(sym libpf.Symbol) {
+		if sym.Size == 0 {
+			return
+		}
+		for _, name := range greenletFunctionsStopDelta {
+			if !strings.Contains(string(sym.Name), name) {
+				continue
+			}
+			start := uintptr(sym.Address)
+			filter.stopHook(start, start+uintptr(sym.Size))
+			ee.deltas.AddEx(sdtypes.StackDelta{
+				Address: uint64(sym.Address),
+				Info:    sdtypes.UnwindInfoStop,
+			}, false)
+			return
+		}
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package elfunwindinfo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf/pfelf"
+	sdtypes "go.opentelemetry.io/ebpf-profiler/nativeunwind/stackdeltatypes"
+)
+
+// Base64-encoded x86-64 stand-in for the greenlet extension module with the
+// functions PyInit__greenlet, g_initialstub at 0x2f0 (29 bytes) and slp_switch
+// at 0x30d (35 bytes), built with gcc -O1 -fPIC -shared -nostdlib.
+var greenletModule = `f0VMRgIBAQAAAAAAAAAAAAMAPgABAAAAAAAAAAAAAABAAAAAAAAAAHAHAAAAAAAAAAAAAEAAOAAG
+AEAAEgARAAEAAAAFAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA/AMAAAAAAAD8AwAAAAAAAAAQ
+AAAAAAAAAQAAAAYAAAAABAAAAAAAAAAUAAAAAAAAABQAAAAAAABgAQAAAAAAAGABAAAAAAAAABAA
+AAAAAAACAAAABgAAAAgEAAAAAAAACBQAAAAAAAAIFAAAAAAAACABAAAAAAAAIAEAAAAAAAAIAAAA
+AAAAAAcAAAAEAAAAAAQAAAAAAAAAFAAAAAAAAAAUAAAAAAAACAAAAAAAAAAIAAAAAAAAAAgAAAAA
+AAAAUOV0ZAQAAABAAwAAAAAAAEADAAAAAAAAQAMAAAAAAAAsAAAAAAAAACwAAAAAAAAABAAAAAAA
+AABR5XRkBgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAAAAAAAA
+AAMAAAACAAAAAQAAAAYAAAAgACkAAABAAgIAAAADAAAABAAAAEWefC03hCRAUwXCRwAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAA8AAAAQAAAAAAAAAAAAAAAAAAAAAAAAAB4AAAASAAcADQMAAAAAAAAj
+AAAAAAAAACkAAAASAAcAMAMAAAAAAAAQAAAAAAAAAAEAAAASAAcA8AIAAAAAAAAdAAAAAAAAAABn
+X2luaXRpYWxzdHViAF9fdGxzX2dldF9hZGRyAHNscF9zd2l0Y2gAUHlJbml0X19ncmVlbmxldAAA
+AAAAAAAoFQAAAAAAABAAAAAAAAAAAAAAAAAAAABQFQAAAAAAAAcAAAACAAAAAAAAAAAAAABYFQAA
+AAAAAAcAAAABAAAAAAAAAAAAAAD/NXoSAAD/JXwSAAAPH0AA/yV6EgAAaAAAAADp4P////8lchIA
+AGgBAAAA6dD///9TSIn7SI09LRIAAOjg////SImYAAAAAEiLQwhbw0iD7AhIjT0QEgAA6MP///9I
+g7gAAAAAAA+VwA+2wEiDxAjDSIPsCOiX////SJhIg8QIwwEbAzssAAAABAAAAID///+YAAAAsP//
+/0gAAADN////ZAAAAPD///98AAAAAAAAABQAAAAAAAAAAXpSAAF4EAEbDAcIkAEAABgAAAAcAAAA
+YP///x0AAAAAQQ4QgwJbDggAAAAUAAAAOAAAAGH///8jAAAAAEQOEF4OCAAYAAAAUAAAAGz///8Q
+AAAAAEQOEEsOCAAAAAAAIAAAAGwAAADg/v//MAAAAAAOEEYOGEoPC3cIgAA/GjsqMyQiAAAAAAEA
+AAAAAAAA9f7/bwAAAACQAQAAAAAAAAUAAAAAAAAAOAIAAAAAAAAGAAAAAAAAAMABAAAAAAAACgAA
+AAAAAAA6AAAAAAAAAAsAAAAAAAAAGAAAAAAAAAADAAAAAAAAADgVAAAAAAAAAgAAAAAAAAAwAAAA
+AAAAABQAAAAAAAAABwAAAAAAAAAXAAAAAAAAAJACAAAAAAAABwAAAAAAAAB4AgAAAAAAAAgAAAAA
+AAAAGAAAAAAAAAAJAAAAAAAAABgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAgUAAAAAAAAAAAAAAAAAAAAAAAAAAAAANYCAAAAAAAA
+5gIAAAAAAABHQ0M6IChEZWJpYW4gMTIuMi4wLTE0K2RlYjEydTEpIDEyLjIuMAAAAAAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAQAAAAYACgAAAAAAAAAAAAgAAAAAAAAAFwAAAAEACwAIFAAAAAAAAAAA
+AAAAAAAAIAAAAAAACABAAwAAAAAAAAAAAAAAAAAAMwAAAAEADQA4FQAAAAAAAAAAAAAAAAAASQAA
+ABIABwAwAwAAAAAAABAAAAAAAAAAWgAAABIABwDwAgAAAAAAAB0AAAAAAAAAaAAAABIABwANAwAA
+AAAAACMAAAAAAAAAcwAAABAAAAAAAAAAAAAAAAAAAAAAAAAAAGdfdGhyZWFkX3N0YXRlX2dsb2Jh
+bABfRFlOQU1JQwBfX0dOVV9FSF9GUkFNRV9IRFIAX0dMT0JBTF9PRkZTRVRfVEFCTEVfAFB5SW5p
+dF9fZ3JlZW5sZXQAZ19pbml0aWFsc3R1YgBzbHBfc3dpdGNoAF9fdGxzX2dldF9hZGRyAAAuc3lt
+dGFiAC5zdHJ0YWIALnNoc3RydGFiAC5nbnUuaGFzaAAuZHluc3ltAC5keW5zdHIALnJlbGEuZHlu
+AC5yZWxhLnBsdAAudGV4dAAuZWhfZnJhbWVfaGRyAC5laF9mcmFtZQAudGRhdGEALmR5bmFtaWMA
+LmdvdAAuZ290LnBsdAAuY29tbWVudAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAGwAAAPb//28CAAAAAAAAAJABAAAAAAAAkAEA
+AAAAAAAwAAAAAAAAAAIAAAAAAAAACAAAAAAAAAAAAAAAAAAAACUAAAALAAAAAgAAAAAAAADAAQAA
+AAAAAMABAAAAAAAAeAAAAAAAAAADAAAAAQAAAAgAAAAAAAAAGAAAAAAAAAAtAAAAAwAAAAIAAAAA
+AAAAOAIAAAAAAAA4AgAAAAAAADoAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAANQAAAAQA
+AAACAAAAAAAAAHgCAAAAAAAAeAIAAAAAAAAYAAAAAAAAAAIAAAAAAAAACAAAAAAAAAAYAAAAAAAA
+AD8AAAAEAAAAQgAAAAAAAACQAgAAAAAAAJACAAAAAAAAMAAAAAAAAAACAAAADQAAAAgAAAAAAAAA
+GAAAAAAAAABEAAAAAQAAAAYAAAAAAAAAwAIAAAAAAADAAgAAAAAAADAAAAAAAAAAAAAAAAAAAAAQ
+AAAAAAAAABAAAAAAAAAASQAAAAEAAAAGAAAAAAAAAPACAAAAAAAA8AIAAAAAAABQAAAAAAAAAAAA
+AAAAAAAAAQAAAAAAAAAAAAAAAAAAAE8AAAABAAAAAgAAAAAAAABAAwAAAAAAAEADAAAAAAAALAAA
+AAAAAAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAABdAAAAAQAAAAIAAAAAAAAAcAMAAAAAAABwAwAA
+AAAAAIwAAAAAAAAAAAAAAAAAAAAIAAAAAAAAAAAAAAAAAAAAZwAAAAEAAAADBAAAAAAAAAAUAAAA
+AAAAAAQAAAAAAAAIAAAAAAAAAAAAAAAAAAAACAAAAAAAAAAAAAAAAAAAAG4AAAAGAAAAAwAAAAAA
+AAAIFAAAAAAAAAgEAAAAAAAAIAEAAAAAAAADAAAAAAAAAAgAAAAAAAAAEAAAAAAAAAB3AAAAAQAA
+AAMAAAAAAAAAKBUAAAAAAAAoBQAAAAAAABAAAAAAAAAAAAAAAAAAAAAIAAAAAAAAAAgAAAAAAAAA
+fAAAAAEAAAADAAAAAAAAADgVAAAAAAAAOAUAAAAAAAAoAAAAAAAAAAAAAAAAAAAACAAAAAAAAAAI
+AAAAAAAAAIUAAAABAAAAMAAAAAAAAAAAAAAAAAAAAGAFAAAAAAAAJwAAAAAAAAAAAAAAAAAAAAEA
+AAAAAAAAAQAAAAAAAAABAAAAAgAAAAAAAAAAAAAAAAAAAAAAAACIBQAAAAAAANgAAAAAAAAAEAAA
+AAUAAAAIAAAAAAAAABgAAAAAAAAACQAAAAMAAAAAAAAAAAAAAAAAAAAAAAAAYAYAAAAAAACCAAAA
+AAAAAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAABEAAAADAAAAAAAAAAAAAAAAAAAAAAAAAOIGAAAA
+AAAAjgAAAAAAAAAAAAAAAAAAAAEAAAAAAAAAAAAAAAAAAAA=`
+
+func parseGreenletFile(t *testing.T, data string) (*extractionFilter, sdtypes.StackDeltaArray) {
+	t.Helper()
+	buf, err := base64.StdEncoding.DecodeString(data)
+	require.NoError(t, err)
+	ef, err := pfelf.NewFile(bytes.NewReader(buf), 0, false)
+	require.NoError(t, err)
+
+	filter := &extractionFilter{}
+	deltas := sdtypes.StackDeltaArray{}
+	ee := &elfExtractor{file: ef, deltas: &deltas}
+	ee.parseGreenlet(filter)
+	return filter, deltas
+}
+
+func TestParseGreenlet(t *testing.T) {
+	filter, deltas := parseGreenletFile(t, greenletModule)
+	require.ElementsMatch(t, []addressRange{
+		{start: 0x2f0, end: 0x30d},
+		{start: 0x30d, end: 0x330},
+	}, filter.stopRanges)
+	require.True(t, filter.unsortedFrames)
+	require.ElementsMatch(t, sdtypes.StackDeltaArray{
+		{Address: 0x2f0, Info: sdtypes.UnwindInfoStop},
+		{Address: 0x30d, Info: sdtypes.UnwindInfoStop},
+	}, deltas)
+
+	// Other ELF files are left alone.
+	filter, deltas = parseGreenletFile(t, usrBinVolname)
+	require.Empty(t, filter.stopRanges)
+	require.Empty(t, deltas)
+}
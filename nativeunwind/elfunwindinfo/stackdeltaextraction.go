@@ -27,6 +27,10 @@ type extractionFilter struct {
 	// should be excluded from .eh_frame extraction.
 	start, end uintptr
 
+	// stopRanges contains the functions which are stack roots, and should be
+	// excluded from .eh_frame extraction.
+	stopRanges []addressRange
+
 	// ehFrames is true if .eh_frame stack deltas are found
 	ehFrames bool
 
@@ -37,6 +41,11 @@ type extractionFilter struct {
 	unsortedFrames bool
 }
 
+// addressRange is a block of virtual addresses from start to end, exclusive.
+type addressRange struct {
+	start, end uintptr
+}
+
 var _ ehframeHooks = &extractionFilter{}
 
 // fdeHook filters out .eh_frame data that is superseded by .gopclntab data
@@ -48,6 +57,11 @@ func (f *extractionFilter) fdeHook(_ *cieInfo, fde *fdeInfo) bool {
 		}
 		f.unsortedFrames = true
 	}
+	for _, r := range f.stopRanges {
+		if fde.ipStart >= r.start && fde.ipStart < r.end {
+			return false
+		}
+	}
 	// Parse functions outside the gopclntab area
 	if fde.ipStart < f.start || fde.ipStart > f.end {
 		// This is here to set the flag only when we have collected at least
@@ -69,6 +83,13 @@ func (f *extractionFilter) golangHook(start, end uintptr) {
 	f.golangFrames = true
 }
 
+// stopHook reports a function which is a stack root. Its stop delta is added
+// out of order.
+func (f *extractionFilter) stopHook(start, end uintptr) {
+	f.stopRanges = append(f.stopRanges, addressRange{start: start, end: end})
+	f.unsortedFrames = true
+}
+
 // elfExtractor is the main context for parsing stack deltas from an ELF
 type elfExtractor struct {
 	ref  *pfelf.Reference
@@ -137,6 +158,7 @@ func ExtractELF(elfRef *pfelf.Reference, interval *sdtypes.IntervalData) error {
 	if err = ee.parseGoPclntab(); err != nil {
 		return fmt.Errorf("failure to parse golang stack deltas: %v", err)
 	}
+	ee.parseGreenlet(&filter)
 	if err = ee.parseEHFrame(); err != nil {
 		return fmt.Errorf("failure to parse eh_frame stack deltas: %v", err)
 	}
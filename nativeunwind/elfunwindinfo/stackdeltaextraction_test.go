@@ -157,3 +157,14 @@ func TestExtractStackDeltasFromFilename(t *testing.T) {
 	}
 	require.Equal(t, data.Deltas[:len(firstDeltas)], firstDeltas)
 }
+
+func TestExtractionFilterStopRanges(t *testing.T) {
+	filter := &extractionFilter{}
+	filter.stopHook(0x2000, 0x2100)
+	require.True(t, filter.unsortedFrames)
+
+	require.True(t, filter.fdeHook(nil, &fdeInfo{ipStart: 0x1000, sorted: true}))
+	require.False(t, filter.fdeHook(nil, &fdeInfo{ipStart: 0x2000, sorted: true}))
+	require.False(t, filter.fdeHook(nil, &fdeInfo{ipStart: 0x20f0, sorted: true}))
+	require.True(t, filter.fdeHook(nil, &fdeInfo{ipStart: 0x2100, sorted: true}))
+}
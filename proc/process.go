@@ -0,0 +1,198 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package proc // import "go.opentelemetry.io/ebpf-profiler/proc"
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+)
+
+// clockTicksPerSecond is the unit of the times in /proc/PID/stat (USER_HZ). The
+// kernel exposes it as a fixed 100 to user space on all supported architectures.
+const clockTicksPerSecond = 100
+
+// ProcessStat holds the fields of /proc/PID/stat that are of interest to us.
+type ProcessStat struct {
+	// Comm is the name of the process, as in /proc/PID/comm.
+	Comm string
+	// State is the state of the process, e.g. 'R' for running or 'Z' for zombie.
+	State byte
+	// PPID is the PID of the parent process.
+	PPID libpf.PID
+	// StartTime is the time the process was started.
+	StartTime time.Time
+}
+
+// bootTime returns the system boot time from the btime line of /proc/stat.
+var bootTime = sync.OnceValues(func() (time.Time, error) {
+	file, err := os.Open(defaultMountPoint + "/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+	return parseBootTime(bufio.NewScanner(file))
+})
+
+func parseBootTime(scanner *bufio.Scanner) (time.Time, error) {
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), "btime ")
+		if !found {
+			continue
+		}
+		btime, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse btime %q: %v", value, err)
+		}
+		return time.Unix(btime, 0), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, errors.New("no btime found")
+}
+
+// GetProcessStat returns the parent PID and the start time of the process pid
+// from /proc/PID/stat.
+func GetProcessStat(pid libpf.PID) (ProcessStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("%s/%d/stat", defaultMountPoint, pid))
+	if err != nil {
+		return ProcessStat{}, err
+	}
+	btime, err := bootTime()
+	if err != nil {
+		return ProcessStat{}, fmt.Errorf("failed to get boot time: %v", err)
+	}
+	return parseProcessStat(data, btime)
+}
+
+// parseProcessStat parses the content of /proc/PID/stat. The fields are
+// documented in proc(5).
+func parseProcessStat(data []byte, btime time.Time) (ProcessStat, error) {
+	// The comm field is enclosed in parentheses and may contain spaces and
+	// parentheses itself, so the remaining fields follow the last ')'.
+	start := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if start < 0 || end < start {
+		return ProcessStat{}, errors.New("unexpected format")
+	}
+
+	// fields[0] is the state (field 3), so field N is at fields[N-3].
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return ProcessStat{}, fmt.Errorf("too few fields: %d", len(fields))
+	}
+
+	ppid, err := strconv.ParseInt(fields[4-3], 10, 32)
+	if err != nil {
+		return ProcessStat{}, fmt.Errorf("failed to parse ppid %q: %v", fields[4-3], err)
+	}
+	startTicks, err := strconv.ParseUint(fields[22-3], 10, 64)
+	if err != nil {
+		return ProcessStat{}, fmt.Errorf("failed to parse starttime %q: %v",
+			fields[22-3], err)
+	}
+
+	return ProcessStat{
+		Comm:      string(data[start+1 : end]),
+		State:     fields[3-3][0],
+		PPID:      libpf.PID(ppid),
+		StartTime: btime.Add(time.Duration(startTicks) * time.Second / clockTicksPerSecond),
+	}, nil
+}
+
+// ListPIDs returns the PIDs of all processes in /proc.
+func ListPIDs() ([]libpf.PID, error) {
+	entries, err := os.ReadDir(defaultMountPoint)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]libpf.PID, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		pids = append(pids, libpf.PID(pid))
+	}
+	return pids, nil
+}
+
+// ListTIDs returns the thread IDs of the process pid from /proc/PID/task.
+func ListTIDs(pid libpf.PID) ([]libpf.PID, error) {
+	entries, err := os.ReadDir(fmt.Sprintf("%s/%d/task", defaultMountPoint, pid))
+	if err != nil {
+		return nil, err
+	}
+	tids := make([]libpf.PID, 0, len(entries))
+	for _, entry := range entries {
+		tid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		tids = append(tids, libpf.PID(tid))
+	}
+	return tids, nil
+}
+
+// GetCmdline returns the command line arguments of the process pid from
+// /proc/PID/cmdline. It returns an empty slice for kernel threads.
+func GetCmdline(pid libpf.PID) ([]string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("%s/%d/cmdline", defaultMountPoint, pid))
+	if err != nil {
+		return nil, err
+	}
+	return parseCmdline(data), nil
+}
+
+// parseCmdline splits the NUL separated arguments of /proc/PID/cmdline.
+func parseCmdline(data []byte) []string {
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return []string{}
+	}
+	return strings.Split(string(data), "\x00")
+}
+
+// GetNamespacedPID returns the PID of the process pid in its innermost PID
+// namespace, as listed last in the NSpid line of /proc/PID/status. This is the
+// PID a containerized process knows itself by, e.g. in /tmp/perf-PID.map.
+func GetNamespacedPID(pid libpf.PID) (libpf.PID, error) {
+	data, err := os.ReadFile(fmt.Sprintf("%s/%d/status", defaultMountPoint, pid))
+	if err != nil {
+		return 0, err
+	}
+	return parseNamespacedPID(data, pid)
+}
+
+// parseNamespacedPID extracts the innermost PID from the content of
+// /proc/PID/status. Kernels before 4.1 have no NSpid line, in which case pid is
+// returned.
+func parseNamespacedPID(data []byte, pid libpf.PID) (libpf.PID, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "NSpid:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return 0, errors.New("empty NSpid line")
+		}
+		nspid, err := strconv.ParseUint(fields[len(fields)-1], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid NSpid %q: %v", value, err)
+		}
+		return libpf.PID(nspid), nil
+	}
+	return pid, scanner.Err()
+}
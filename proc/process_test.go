@@ -0,0 +1,118 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package proc
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+)
+
+func TestParseBootTime(t *testing.T) {
+	stat := "cpu  1 2 3 4\nintr 5 6\nctxt 7\nbtime 1700000000\nprocesses 8\n"
+	btime, err := parseBootTime(bufio.NewScanner(strings.NewReader(stat)))
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1700000000, 0), btime)
+
+	_, err = parseBootTime(bufio.NewScanner(strings.NewReader("cpu 1 2 3 4\n")))
+	require.Error(t, err)
+}
+
+func TestParseProcessStat(t *testing.T) {
+	btime := time.Unix(1700000000, 0)
+	tests := map[string]struct {
+		data    string
+		want    ProcessStat
+		wantErr bool
+	}{
+		"simple": {
+			data: "1234 (bash) S 1000 1234 1234 34816 1300 4194304 1 2 3 4 5 6 7 8 " +
+				"20 0 1 0 12345 9000000 1000 18446744073709551615\n",
+			want: ProcessStat{
+				Comm:      "bash",
+				State:     'S',
+				PPID:      1000,
+				StartTime: btime.Add(123450 * time.Millisecond),
+			},
+		},
+		"comm with spaces and parentheses": {
+			data: "42 (a (b) c) R 1 42 42 0 -1 4194560 1 2 3 4 5 6 7 8 " +
+				"20 0 1 0 250 9000000 1000\n",
+			want: ProcessStat{
+				Comm:      "a (b) c",
+				State:     'R',
+				PPID:      1,
+				StartTime: btime.Add(2500 * time.Millisecond),
+			},
+		},
+		"truncated": {
+			data:    "42 (sh) S 1 42 42\n",
+			wantErr: true,
+		},
+		"no comm": {
+			data:    "42 sh S 1 42 42\n",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			stat, err := parseProcessStat([]byte(tc.data), btime)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, stat)
+		})
+	}
+}
+
+func TestParseCmdline(t *testing.T) {
+	assert.Equal(t, []string{"env", "", "sh"}, parseCmdline([]byte("env\x00\x00sh\x00")))
+	assert.Equal(t, []string{"python3", "worker.py"},
+		parseCmdline([]byte("python3\x00worker.py\x00")))
+	assert.Empty(t, parseCmdline(nil))
+}
+
+func TestParseNamespacedPID(t *testing.T) {
+	tests := map[string]struct {
+		status string
+		want   libpf.PID
+	}{
+		"host":      {status: "Name:\tsh\nNSpid:\t1234\n", want: 1234},
+		"container": {status: "Name:\tsh\nNSpid:\t1234\t7\n", want: 7},
+		"no NSpid":  {status: "Name:\tsh\nPid:\t1234\n", want: 1234},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pid, err := parseNamespacedPID([]byte(tc.status), 1234)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, pid)
+		})
+	}
+}
+
+func TestGetProcessStatSelf(t *testing.T) {
+	pid := libpf.PID(os.Getpid())
+	stat, err := GetProcessStat(pid)
+	require.NoError(t, err)
+	assert.Equal(t, libpf.PID(os.Getppid()), stat.PPID)
+	assert.False(t, stat.StartTime.After(time.Now()))
+
+	cmdline, err := GetCmdline(pid)
+	require.NoError(t, err)
+	assert.Equal(t, os.Args, cmdline)
+
+	tids, err := ListTIDs(pid)
+	require.NoError(t, err)
+	assert.Contains(t, tids, pid)
+}
@@ -22,6 +22,7 @@ import (
 	golang "go.opentelemetry.io/ebpf-profiler/interpreter/go"
 	"go.opentelemetry.io/ebpf-profiler/interpreter/hotspot"
 	"go.opentelemetry.io/ebpf-profiler/interpreter/nodev8"
+	"go.opentelemetry.io/ebpf-profiler/interpreter/perfmap"
 	"go.opentelemetry.io/ebpf-profiler/interpreter/perl"
 	"go.opentelemetry.io/ebpf-profiler/interpreter/php"
 	"go.opentelemetry.io/ebpf-profiler/interpreter/python"
@@ -101,6 +102,7 @@ func NewExecutableInfoManager(
 	sdp nativeunwind.StackDeltaProvider,
 	ebpf pmebpf.EbpfHandler,
 	includeTracers types.IncludedTracers,
+	pythonOptions python.Options,
 ) (*ExecutableInfoManager, error) {
 	// Initialize interpreter loaders.
 	interpreterLoaders := make([]interpreter.Loader, 0)
@@ -108,7 +110,7 @@ func NewExecutableInfoManager(
 		interpreterLoaders = append(interpreterLoaders, perl.Loader)
 	}
 	if includeTracers.Has(types.PythonTracer) {
-		interpreterLoaders = append(interpreterLoaders, python.Loader)
+		interpreterLoaders = append(interpreterLoaders, python.NewLoader(pythonOptions))
 	}
 	if includeTracers.Has(types.PHPTracer) {
 		interpreterLoaders = append(interpreterLoaders, php.Loader, php.OpcacheLoader)
@@ -131,6 +133,11 @@ func NewExecutableInfoManager(
 
 	interpreterLoaders = append(interpreterLoaders, apmint.Loader)
 
+	// The perf map loader matches any main executable, so it needs to come last.
+	if includeTracers.Has(types.PerfMapTracer) {
+		interpreterLoaders = append(interpreterLoaders, perfmap.Loader)
+	}
+
 	deferredFileIDs, err := lru.NewSynced[host.FileID, libpf.Void](deferredFileIDSize,
 		func(id host.FileID) uint32 { return uint32(id) })
 	if err != nil {
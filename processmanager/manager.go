@@ -8,6 +8,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"time"
 
 	lru "github.com/elastic/go-freelru"
@@ -18,6 +19,7 @@ import (
 	"go.opentelemetry.io/ebpf-profiler/host"
 	"go.opentelemetry.io/ebpf-profiler/interpreter"
 	"go.opentelemetry.io/ebpf-profiler/interpreter/apmint"
+	"go.opentelemetry.io/ebpf-profiler/interpreter/python"
 	"go.opentelemetry.io/ebpf-profiler/libpf"
 	"go.opentelemetry.io/ebpf-profiler/lpm"
 	"go.opentelemetry.io/ebpf-profiler/metrics"
@@ -64,10 +66,12 @@ var (
 // Three external interfaces are used to access the processes and related resources: ebpf,
 // fileIDMapper and symbolReporter. Specify nil for fileIDMapper to use the default
 // implementation.
-func New(ctx context.Context, includeTracers types.IncludedTracers, monitorInterval time.Duration,
-	ebpf pmebpf.EbpfHandler, fileIDMapper FileIDMapper, symbolReporter reporter.SymbolReporter,
+func New(ctx context.Context, includeTracers types.IncludedTracers,
+	pythonOptions python.Options, monitorInterval time.Duration, ebpf pmebpf.EbpfHandler,
+	fileIDMapper FileIDMapper, symbolReporter reporter.SymbolReporter,
 	sdp nativeunwind.StackDeltaProvider, filterErrorFrames bool,
-	includeEnvVars libpf.Set[string]) (*ProcessManager, error) {
+	includeEnvVars libpf.Set[string], includeCmdline bool, cmdlineRedact []*regexp.Regexp,
+	includeAncestors bool) (*ProcessManager, error) {
 	if fileIDMapper == nil {
 		var err error
 		fileIDMapper, err = newFileIDMapper(lruFileIDCacheSize)
@@ -83,7 +87,7 @@ func New(ctx context.Context, includeTracers types.IncludedTracers, monitorInter
 	}
 	elfInfoCache.SetLifetime(elfInfoCacheTTL)
 
-	em, err := eim.NewExecutableInfoManager(sdp, ebpf, includeTracers)
+	em, err := eim.NewExecutableInfoManager(sdp, ebpf, includeTracers, pythonOptions)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create ExecutableInfoManager: %v", err)
 	}
@@ -103,6 +107,9 @@ func New(ctx context.Context, includeTracers types.IncludedTracers, monitorInter
 		metricsAddSlice:          metrics.AddSlice,
 		filterErrorFrames:        filterErrorFrames,
 		includeEnvVars:           includeEnvVars,
+		includeCmdline:           includeCmdline,
+		cmdlineRedact:            cmdlineRedact,
+		includeAncestors:         includeAncestors,
 	}
 
 	collectInterpreterMetrics(ctx, pm, monitorInterval)
@@ -200,7 +207,14 @@ func (pm *ProcessManager) symbolizeFrame(frame int, trace *host.Trace,
 	}
 
 	for _, instance := range pm.interpreters[trace.PID] {
-		if err := instance.Symbolize(pm.reporter, &trace.Frames[frame], newTrace); err != nil {
+		var err error
+		if s, ok := instance.(interpreter.PrecededSymbolizer); ok && frame > 0 {
+			err = s.SymbolizePreceded(pm.reporter, &trace.Frames[frame-1],
+				&trace.Frames[frame], newTrace)
+		} else {
+			err = instance.Symbolize(pm.reporter, &trace.Frames[frame], newTrace)
+		}
+		if err != nil {
 			if errors.Is(err, interpreter.ErrMismatchInterpreterType) {
 				// The interpreter type of instance did not match the type of frame.
 				// So continue with the next interpreter instance for this PID.
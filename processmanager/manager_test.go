@@ -17,6 +17,7 @@ import (
 
 	"go.opentelemetry.io/ebpf-profiler/host"
 	"go.opentelemetry.io/ebpf-profiler/interpreter"
+	"go.opentelemetry.io/ebpf-profiler/interpreter/python"
 	"go.opentelemetry.io/ebpf-profiler/libpf"
 	"go.opentelemetry.io/ebpf-profiler/libpf/pfelf"
 	"go.opentelemetry.io/ebpf-profiler/lpm"
@@ -317,13 +318,17 @@ func TestInterpreterConvertTrace(t *testing.T) {
 			// To test ConvertTrace we do not require all parts of processmanager.
 			manager, err := New(ctx,
 				noIinterpreters,
+				python.Options{},
 				1*time.Second,
 				nil,
 				nil,
 				&symbolReporterMockup{},
 				nil,
 				true,
-				libpf.Set[string]{})
+				libpf.Set[string]{},
+				false,
+				nil,
+				false)
 			require.NoError(t, err)
 
 			newTrace := manager.ConvertTrace(testcase.trace)
@@ -403,13 +408,17 @@ func TestNewMapping(t *testing.T) {
 
 			manager, err := New(ctx,
 				noInterpreters,
+				python.Options{},
 				1*time.Second,
 				ebpfMockup,
 				NewMapFileIDMapper(),
 				symRepMockup,
 				&dummyProvider,
 				true,
-				libpf.Set[string]{})
+				libpf.Set[string]{},
+				false,
+				nil,
+				false)
 			require.NoError(t, err)
 
 			// Replace the internal hooks for the tests. These hooks catch the
@@ -589,13 +598,17 @@ func TestProcExit(t *testing.T) {
 
 			manager, err := New(ctx,
 				noInterpreters,
+				python.Options{},
 				1*time.Second,
 				ebpfMockup,
 				NewMapFileIDMapper(),
 				repMockup,
 				&dummyProvider,
 				true,
-				libpf.Set[string]{})
+				libpf.Set[string]{},
+				false,
+				nil,
+				false)
 			require.NoError(t, err)
 			defer cancel()
 
@@ -111,11 +111,24 @@ func (pm *ProcessManager) updatePidInformation(pid libpf.PID, m *Mapping) (bool,
 			}
 		}
 
+		meta := ProcessMeta{
+			Name:         processName,
+			Executable:   exePath,
+			EnvVariables: envVarMap,
+			CommandLine:  pm.readCommandLine(pid),
+		}
+		if stat, err := proc.GetProcessStat(pid); err == nil {
+			meta.ParentPID = stat.PPID
+			meta.StartTime = stat.StartTime
+			if pm.includeAncestors {
+				meta.Ancestors = readAncestors(stat.PPID)
+			}
+		} else {
+			log.Debugf("Failed to read stat of PID %d: %v", pid, err)
+		}
+
 		info = &processInfo{
-			meta: ProcessMeta{
-				Name:         processName,
-				Executable:   exePath,
-				EnvVariables: envVarMap},
+			meta:             meta,
 			mappings:         make(map[libpf.Address]*Mapping),
 			mappingsByFileID: make(map[host.FileID]map[libpf.Address]*Mapping),
 			tsdInfo:          nil,
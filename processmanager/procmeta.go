@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package processmanager // import "go.opentelemetry.io/ebpf-profiler/processmanager"
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/proc"
+)
+
+const (
+	// redactedValue replaces the redacted parts of command lines.
+	redactedValue = "[REDACTED]"
+
+	// maxAncestors limits the length of the captured ancestor chain.
+	maxAncestors = 32
+)
+
+// redactCommandLine removes the matches of patterns from cmdline. If a pattern
+// has capture groups, only the text of the groups is removed, e.g. the pattern
+// `--password[= ](\S+)` keeps the option name.
+func redactCommandLine(cmdline string, patterns []*regexp.Regexp) string {
+	for _, pattern := range patterns {
+		matches := pattern.FindAllStringSubmatchIndex(cmdline, -1)
+		if matches == nil {
+			continue
+		}
+
+		var sb strings.Builder
+		last := 0
+		for _, match := range matches {
+			// Without capture groups, match holds the bounds of the full match.
+			// Otherwise the groups follow.
+			groups := match[:2]
+			if len(match) > 2 {
+				groups = match[2:]
+			}
+			for i := 0; i < len(groups); i += 2 {
+				start, end := groups[i], groups[i+1]
+				if start < last {
+					// Unmatched (-1) or nested group.
+					continue
+				}
+				sb.WriteString(cmdline[last:start])
+				sb.WriteString(redactedValue)
+				last = end
+			}
+		}
+		sb.WriteString(cmdline[last:])
+		cmdline = sb.String()
+	}
+	return cmdline
+}
+
+// readCommandLine returns the redacted command line of pid. The arguments are
+// joined by spaces. It returns an empty string if capturing command lines is
+// disabled.
+func (pm *ProcessManager) readCommandLine(pid libpf.PID) string {
+	if !pm.includeCmdline {
+		return ""
+	}
+	args, err := proc.GetCmdline(pid)
+	if err != nil {
+		log.Debugf("Failed to read command line of PID %d: %v", pid, err)
+		return ""
+	}
+	return redactCommandLine(strings.Join(args, " "), pm.cmdlineRedact)
+}
+
+// readAncestors returns the names of the ancestors of the process with the
+// parent ppid, starting with the parent and ending with init.
+func readAncestors(ppid libpf.PID) []string {
+	var ancestors []string
+	for pid := ppid; pid > 0 && len(ancestors) < maxAncestors; {
+		stat, err := proc.GetProcessStat(pid)
+		if err != nil {
+			// The ancestor may have exited in the meantime.
+			log.Debugf("Failed to read ancestor PID %d: %v", pid, err)
+			break
+		}
+		ancestors = append(ancestors, stat.Comm)
+		pid = stat.PPID
+	}
+	return ancestors
+}
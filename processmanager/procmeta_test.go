@@ -0,0 +1,59 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package processmanager
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactCommandLine(t *testing.T) {
+	tests := map[string]struct {
+		cmdline  string
+		patterns []string
+		want     string
+	}{
+		"no patterns": {
+			cmdline: "python3 worker.py --queue jobs",
+			want:    "python3 worker.py --queue jobs",
+		},
+		"full match": {
+			cmdline:  "mysql -uroot -psecret db",
+			patterns: []string{`-p\S+`},
+			want:     "mysql -uroot [REDACTED] db",
+		},
+		"capture group": {
+			cmdline:  "app --password=secret --token abc --verbose",
+			patterns: []string{`--(?:password|token)[= ](\S+)`},
+			want:     "app --password=[REDACTED] --token [REDACTED] --verbose",
+		},
+		"optional group": {
+			cmdline:  "app --key --key=abc",
+			patterns: []string{`--key(?:=(\S+))?`},
+			want:     "app --key --key=[REDACTED]",
+		},
+		"several patterns": {
+			cmdline:  "curl -u user:pass https://host/?token=abc",
+			patterns: []string{`-u (\S+)`, `token=(\w+)`},
+			want:     "curl -u [REDACTED] https://host/?token=[REDACTED]",
+		},
+		"no match": {
+			cmdline:  "sleep 10",
+			patterns: []string{`--password=(\S+)`},
+			want:     "sleep 10",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			patterns := make([]*regexp.Regexp, 0, len(tc.patterns))
+			for _, pattern := range tc.patterns {
+				patterns = append(patterns, regexp.MustCompile(pattern))
+			}
+			assert.Equal(t, tc.want, redactCommandLine(tc.cmdline, patterns))
+		})
+	}
+}
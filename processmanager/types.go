@@ -4,8 +4,10 @@
 package processmanager // import "go.opentelemetry.io/ebpf-profiler/processmanager"
 
 import (
+	"regexp"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	lru "github.com/elastic/go-freelru"
 
@@ -100,6 +102,16 @@ type ProcessManager struct {
 
 	// includeEnvVars holds a list of env vars that should be captured from processes
 	includeEnvVars libpf.Set[string]
+
+	// includeCmdline determines whether the command lines of processes are captured.
+	includeCmdline bool
+
+	// cmdlineRedact holds the patterns whose matches are removed from the
+	// captured process command lines.
+	cmdlineRedact []*regexp.Regexp
+
+	// includeAncestors determines whether the ancestor chain of processes is captured.
+	includeAncestors bool
 }
 
 // Mapping represents an executable memory mapping of a process.
@@ -147,6 +159,14 @@ type ProcessMeta struct {
 	Executable string
 	// process env vars from /proc/PID/environ
 	EnvVariables map[string]string
+	// redacted command line retrieved from /proc/PID/cmdline, if enabled
+	CommandLine string
+	// parent process ID retrieved from /proc/PID/stat
+	ParentPID libpf.PID
+	// process start time retrieved from /proc/PID/stat
+	StartTime time.Time
+	// names of the ancestor processes starting with the parent, if enabled
+	Ancestors []string
 }
 
 // processInfo contains information about the executable mappings
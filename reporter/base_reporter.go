@@ -7,6 +7,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	lru "github.com/elastic/go-freelru"
@@ -37,6 +39,10 @@ type baseReporter struct {
 	// cgroupv2ID caches PID to container ID information for cgroupv2 containers.
 	cgroupv2ID *lru.SyncedLRU[libpf.PID, string]
 
+	// containerMetadata caches the container metadata per cgroupv2 path. It is
+	// nil if no ContainerMetadataProvider is configured.
+	containerMetadata *containerMetadataCache
+
 	// traceEvents stores reported trace events (trace metadata with frames and counts)
 	traceEvents xsync.RWMutex[map[libpf.Origin]samples.KeyToEventMapping]
 
@@ -46,6 +52,94 @@ type baseReporter struct {
 
 var errUnknownOrigin = errors.New("unknown trace origin")
 
+// newBaseReporter sets up the caches and state shared by all reporters.
+func newBaseReporter(cfg *Config) (*baseReporter, error) {
+	cgroupv2ID, err := lru.NewSynced[libpf.PID, string](cfg.CGroupCacheElements,
+		func(pid libpf.PID) uint32 { return uint32(pid) })
+	if err != nil {
+		return nil, err
+	}
+	// Set a lifetime to reduce the risk of invalid data in case of PID reuse.
+	cgroupv2ID.SetLifetime(90 * time.Second)
+
+	var containerMetadata *containerMetadataCache
+	var containerMetadataProvider samples.ContainerMetadataProvider
+	if cfg.ContainerMetadata != nil {
+		containerMetadata, err = newContainerMetadataCache(cfg.ContainerMetadata,
+			cfg.CGroupCacheElements)
+		if err != nil {
+			return nil, err
+		}
+		containerMetadataProvider = containerMetadata
+	}
+
+	// Next step: Dynamically configure the size of this LRU.
+	// Currently, we use the length of the JSON array in
+	// hostmetadata/hostmetadata.json.
+	hostmetadata, err := lru.NewSynced[string, string](115, hashString)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := pdata.New(
+		cfg.SamplesPerSecond,
+		cfg.ExecutablesCacheElements,
+		cfg.FramesCacheElements,
+		cfg.ExtraSampleAttrProd,
+		containerMetadataProvider,
+		cfg.EventOrigins,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	originsMap := make(map[libpf.Origin]samples.KeyToEventMapping, 4+len(cfg.EventOrigins))
+	for _, origin := range []libpf.Origin{support.TraceOriginSampling,
+		support.TraceOriginOffCPU, support.TraceOriginWakeup, support.TraceOriginProbe} {
+		originsMap[origin] = make(samples.KeyToEventMapping)
+	}
+	for _, eventOrigin := range cfg.EventOrigins {
+		originsMap[eventOrigin.Origin] = make(samples.KeyToEventMapping)
+	}
+
+	return &baseReporter{
+		cfg:               cfg,
+		name:              cfg.Name,
+		version:           cfg.Version,
+		pdata:             data,
+		cgroupv2ID:        cgroupv2ID,
+		containerMetadata: containerMetadata,
+		traceEvents:       xsync.NewRWMutex(originsMap),
+		hostmetadata:      hostmetadata,
+		runLoop: &runLoop{
+			stopSignal: make(chan libpf.Void),
+		},
+	}, nil
+}
+
+// takeTraceEvents returns the trace events collected so far and resets the
+// internal state for the next reporting interval.
+func (b *baseReporter) takeTraceEvents() map[libpf.Origin]samples.KeyToEventMapping {
+	traceEvents := b.traceEvents.WLock()
+	defer b.traceEvents.WUnlock(&traceEvents)
+
+	events := make(map[libpf.Origin]samples.KeyToEventMapping, len(*traceEvents))
+	for origin, mapping := range *traceEvents {
+		events[origin] = maps.Clone(mapping)
+		clear(mapping)
+	}
+	return events
+}
+
+// purge allows the GC to purge expired entries to avoid memory leaks.
+func (b *baseReporter) purge() {
+	b.pdata.Purge()
+	b.cgroupv2ID.PurgeExpired()
+	if b.containerMetadata != nil {
+		b.containerMetadata.purge()
+	}
+}
+
 func (b *baseReporter) Stop() {
 	b.runLoop.Stop()
 }
@@ -90,9 +184,21 @@ func (b *baseReporter) ExecutableMetadata(args *ExecutableMetadataArgs) {
 	})
 }
 
+// isKnownOrigin returns true if traces of the given origin are reported.
+func (b *baseReporter) isKnownOrigin(origin libpf.Origin) bool {
+	switch origin {
+	case support.TraceOriginSampling, support.TraceOriginOffCPU, support.TraceOriginWakeup,
+		support.TraceOriginProbe:
+		return true
+	}
+	return slices.ContainsFunc(b.cfg.EventOrigins, func(e samples.EventOrigin) bool {
+		return e.Origin == origin
+	})
+}
+
 func (b *baseReporter) ReportTraceEvent(trace *libpf.Trace, meta *samples.TraceEventMeta) error {
-	if meta.Origin != support.TraceOriginSampling && meta.Origin != support.TraceOriginOffCPU {
-		// At the moment only on-CPU and off-CPU traces are reported.
+	if !b.isKnownOrigin(meta.Origin) {
+		// Only on-CPU, off-CPU, wakeup, probe and the configured event traces are reported.
 		return fmt.Errorf("skip reporting trace for %d origin: %w", meta.Origin,
 			errUnknownOrigin)
 	}
@@ -108,27 +214,51 @@ func (b *baseReporter) ReportTraceEvent(trace *libpf.Trace, meta *samples.TraceE
 			meta.PID, err)
 	}
 
+	var startTime int64
+	if !meta.StartTime.IsZero() {
+		startTime = meta.StartTime.UnixNano()
+	}
+
 	key := samples.TraceAndMetaKey{
-		Hash:           trace.Hash,
-		Comm:           meta.Comm,
-		ProcessName:    meta.ProcessName,
-		ExecutablePath: meta.ExecutablePath,
-		ApmServiceName: meta.APMServiceName,
-		ContainerID:    containerID,
-		Pid:            int64(meta.PID),
-		ExtraMeta:      extraMeta,
+		Hash:             trace.Hash,
+		Comm:             meta.Comm,
+		ProcessName:      meta.ProcessName,
+		ExecutablePath:   meta.ExecutablePath,
+		ApmServiceName:   meta.APMServiceName,
+		ContainerID:      containerID,
+		Pid:              int64(meta.PID),
+		CommandLine:      meta.CommandLine,
+		ParentPid:        int64(meta.ParentPID),
+		StartTime:        startTime,
+		TaskState:        meta.TaskState,
+		BlockingReason:   meta.BlockingReason,
+		ExtraMeta:        extraMeta,
+		SamplesPerSecond: meta.SamplesPerSecond,
 	}
 
+	// The wakeup link differs for most events, so it is kept per event instead
+	// of being part of the key.
+	hasWakeup := meta.Origin == support.TraceOriginOffCPU ||
+		meta.Origin == support.TraceOriginWakeup
+
 	traceEventsMap := b.traceEvents.WLock()
 	defer b.traceEvents.WUnlock(&traceEventsMap)
 
 	if events, exists := (*traceEventsMap)[meta.Origin][key]; exists {
 		events.Timestamps = append(events.Timestamps, uint64(meta.Timestamp))
 		events.OffTimes = append(events.OffTimes, meta.OffTime)
+		if hasWakeup {
+			events.Wakeups = append(events.Wakeups, meta.Wakeup)
+		}
 		(*traceEventsMap)[meta.Origin][key] = events
 		return nil
 	}
 
+	var wakeups []libpf.WakeupLink
+	if hasWakeup {
+		wakeups = []libpf.WakeupLink{meta.Wakeup}
+	}
+
 	(*traceEventsMap)[meta.Origin][key] = &samples.TraceEvents{
 		Files:              trace.Files,
 		Linenos:            trace.Linenos,
@@ -138,7 +268,10 @@ func (b *baseReporter) ReportTraceEvent(trace *libpf.Trace, meta *samples.TraceE
 		MappingFileOffsets: trace.MappingFileOffsets,
 		Timestamps:         []uint64{uint64(meta.Timestamp)},
 		OffTimes:           []int64{meta.OffTime},
+		Wakeups:            wakeups,
 		EnvVars:            meta.EnvVars,
+		Ancestors:          meta.Ancestors,
+		CustomLabels:       trace.CustomLabels,
 	}
 	return nil
 }
@@ -170,6 +303,7 @@ func (b *baseReporter) FrameMetadata(args *FrameMetadataArgs) {
 			FilePath:       sourceFile,
 			FunctionOffset: args.FunctionOffset,
 			FunctionName:   args.FunctionName,
+			ModuleName:     args.ModuleName,
 		}
 		return
 	}
@@ -180,6 +314,7 @@ func (b *baseReporter) FrameMetadata(args *FrameMetadataArgs) {
 		FilePath:       args.SourceFile,
 		FunctionOffset: args.FunctionOffset,
 		FunctionName:   args.FunctionName,
+		ModuleName:     args.ModuleName,
 	}
 	mu := xsync.NewRWMutex(v)
 	b.pdata.Frames.Add(fileID, &mu)
@@ -19,8 +19,22 @@ type Config struct {
 	Version string
 
 	// CollAgentAddr defines the destination of the backend connection.
+	// An http:// or https:// URL selects OTLP/HTTP as transport, unless
+	// Protocol is set.
 	CollAgentAddr string
 
+	// Protocol selects the transport to the backend: ProtocolGRPC,
+	// ProtocolHTTPProtobuf or ProtocolHTTPJSON. If empty, the transport is
+	// derived from CollAgentAddr.
+	Protocol string
+
+	// HTTPHeaders are added to every OTLP/HTTP request.
+	HTTPHeaders map[string]string
+
+	// HTTPCompression selects the compression of OTLP/HTTP request bodies.
+	// Supported values are "" (no compression) and CompressionGzip.
+	HTTPCompression string
+
 	// MaxRPCMsgSize defines the maximum size of a gRPC message.
 	MaxRPCMsgSize int
 
@@ -58,7 +72,46 @@ type Config struct {
 	// attributes to samples.
 	ExtraSampleAttrProd samples.SampleAttrProducer
 
+	// ContainerMetadata optionally resolves attributes describing the
+	// container of samples, e.g. Kubernetes pod metadata.
+	ContainerMetadata samples.ContainerMetadataProvider
+
+	// EventOrigins holds the runtime configured trace origins, e.g. kernel
+	// events, that are reported in addition to the built-in origins.
+	EventOrigins []samples.EventOrigin
+
 	// GRPCDialOptions allows passing additional gRPC dial options when establishing
 	// the connection to the collector. These options are appended after the default options.
 	GRPCDialOptions []grpc.DialOption
+
+	// SpoolDir is the directory where profiles that failed to be exported are
+	// kept until the connection to the backend recovers. Spooling is disabled
+	// if empty.
+	SpoolDir string
+	// SpoolMaxBytes limits the total size of the spooled profiles.
+	// Zero disables the size limit.
+	SpoolMaxBytes int64
+	// SpoolMaxAge limits the age of the spooled profiles.
+	// Zero disables the age limit.
+	SpoolMaxAge time.Duration
+
+	// FoldedOutput is the file the FoldedReporter appends folded stacks to,
+	// or FoldedStdout.
+	FoldedOutput string
+	// FoldedGroupBy optionally groups folded stacks by FoldedGroupByPID,
+	// FoldedGroupByContainer or FoldedGroupByComm.
+	FoldedGroupBy string
+
+	// PprofOutputDir is the directory the PprofReporter writes profiles to.
+	PprofOutputDir string
+	// PprofMaxBytes limits the total size of the profiles kept in PprofOutputDir.
+	// Zero disables the size based retention.
+	PprofMaxBytes int64
+	// PprofMaxAge limits the age of the profiles kept in PprofOutputDir.
+	// Zero disables the age based retention.
+	PprofMaxAge time.Duration
+	// PprofOutputFile is the file the PprofReporter writes a single profile of
+	// all trace events to when it is stopped. It is mutually exclusive with
+	// PprofOutputDir.
+	PprofOutputFile string
 }
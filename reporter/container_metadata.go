@@ -0,0 +1,106 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package reporter // import "go.opentelemetry.io/ebpf-profiler/reporter"
+
+import (
+	"sync"
+	"time"
+
+	lru "github.com/elastic/go-freelru"
+	"go.opentelemetry.io/otel/attribute"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+)
+
+const (
+	// containerMetadataLifetime is the lifetime of cached container metadata.
+	containerMetadataLifetime = 5 * time.Minute
+
+	// unknownContainerLifetime is the lifetime of cached lookups that did not
+	// find any metadata. It is short, as metadata providers may learn about
+	// new containers with a delay.
+	unknownContainerLifetime = 30 * time.Second
+
+	// maxContainerMetadataLookups limits the number of concurrent provider lookups.
+	maxContainerMetadataLookups = 4
+)
+
+// Assert that we implement the ContainerMetadataProvider interface.
+var _ samples.ContainerMetadataProvider = (*containerMetadataCache)(nil)
+
+// containerMetadataCache caches the results of a ContainerMetadataProvider
+// per cgroupv2 path. Cache misses are resolved in the background, so that slow
+// providers do not hold up the generation of profiles.
+type containerMetadataCache struct {
+	provider samples.ContainerMetadataProvider
+	cache    *lru.SyncedLRU[string, []attribute.KeyValue]
+
+	// lookups limits the number of provider lookups in flight.
+	lookups chan libpf.Void
+
+	mu sync.Mutex
+	// pending holds the cgroupv2 paths that are being looked up.
+	pending map[string]libpf.Void
+}
+
+func newContainerMetadataCache(provider samples.ContainerMetadataProvider,
+	size uint32) (*containerMetadataCache, error) {
+	cache, err := lru.NewSynced[string, []attribute.KeyValue](size, hashString)
+	if err != nil {
+		return nil, err
+	}
+	cache.SetLifetime(containerMetadataLifetime)
+
+	return &containerMetadataCache{
+		provider: provider,
+		cache:    cache,
+		lookups:  make(chan libpf.Void, maxContainerMetadataLookups),
+		pending:  make(map[string]libpf.Void),
+	}, nil
+}
+
+// ContainerMetadata returns the cached metadata for cgroupPath. On cache misses
+// it starts a lookup in the background and returns nil.
+func (c *containerMetadataCache) ContainerMetadata(cgroupPath string) []attribute.KeyValue {
+	if attrs, ok := c.cache.Get(cgroupPath); ok {
+		return attrs
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pending[cgroupPath]; ok {
+		return nil
+	}
+	select {
+	case c.lookups <- libpf.Void{}:
+	default:
+		// Too many lookups in flight, try again with a later sample.
+		return nil
+	}
+	c.pending[cgroupPath] = libpf.Void{}
+
+	go c.lookup(cgroupPath)
+	return nil
+}
+
+// lookup queries the provider for cgroupPath and caches the result.
+func (c *containerMetadataCache) lookup(cgroupPath string) {
+	attrs := c.provider.ContainerMetadata(cgroupPath)
+	if len(attrs) == 0 {
+		c.cache.AddWithLifetime(cgroupPath, attrs, unknownContainerLifetime)
+	} else {
+		c.cache.Add(cgroupPath, attrs)
+	}
+
+	c.mu.Lock()
+	delete(c.pending, cgroupPath)
+	c.mu.Unlock()
+	<-c.lookups
+}
+
+// purge allows the GC to purge expired entries.
+func (c *containerMetadataCache) purge() {
+	c.cache.PurgeExpired()
+}
@@ -0,0 +1,56 @@
+package reporter
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// countingProvider returns metadata for a single cgroup and counts the lookups.
+// Lookups block until release is closed.
+type countingProvider struct {
+	known   string
+	release chan struct{}
+	lookups atomic.Int32
+}
+
+func (c *countingProvider) ContainerMetadata(cgroupPath string) []attribute.KeyValue {
+	<-c.release
+	c.lookups.Add(1)
+	if cgroupPath != c.known {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String("container.name", "web")}
+}
+
+func TestContainerMetadataCache(t *testing.T) {
+	provider := &countingProvider{known: "/docker/web", release: make(chan struct{})}
+	c, err := newContainerMetadataCache(provider, 16)
+	require.NoError(t, err)
+
+	// Misses do not wait for the provider, and are looked up only once.
+	for i := 0; i < 3; i++ {
+		assert.Empty(t, c.ContainerMetadata("/docker/web"))
+		assert.Empty(t, c.ContainerMetadata("/docker/unknown"))
+	}
+	close(provider.release)
+
+	require.Eventually(t, func() bool {
+		return len(c.ContainerMetadata("/docker/web")) != 0
+	}, 5*time.Second, time.Millisecond)
+	require.Eventually(t, func() bool {
+		_, ok := c.cache.Get("/docker/unknown")
+		return ok
+	}, 5*time.Second, time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, []attribute.KeyValue{attribute.String("container.name", "web")},
+			c.ContainerMetadata("/docker/web"))
+		assert.Empty(t, c.ContainerMetadata("/docker/unknown"))
+	}
+	assert.Equal(t, int32(2), provider.lookups.Load())
+}
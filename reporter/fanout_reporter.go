@@ -0,0 +1,227 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package reporter // import "go.opentelemetry.io/ebpf-profiler/reporter"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+)
+
+// Assert that we implement the full Reporter interface.
+var _ Reporter = (*FanoutReporter)(nil)
+
+const (
+	// fanoutStartBackoff is the initial delay before a child that failed to
+	// start is started again.
+	fanoutStartBackoff = 5 * time.Second
+	// fanoutMaxStartBackoff limits the delay between start attempts of a child.
+	fanoutMaxStartBackoff = 5 * time.Minute
+)
+
+// FanoutReporter forwards all data to several child reporters, e.g. to export
+// the same profiles to multiple backends.
+//
+// The children operate independently of each other: every child is started in
+// the background and exports from its own background loop. A child that fails
+// to start is retried with backoff, and only receives data once it started.
+type FanoutReporter struct {
+	// children holds all child reporters.
+	children []Reporter
+
+	// startBackoff is the initial delay before retrying to start a child.
+	startBackoff time.Duration
+
+	// stopSignal is closed by Stop to end the start attempts.
+	stopSignal chan libpf.Void
+
+	// mu protects the fields below.
+	mu sync.RWMutex
+
+	// started holds the children that were started successfully.
+	started []Reporter
+
+	// stopped is set once Stop was called.
+	stopped bool
+
+	// hostMetadata is the last host metadata, which is passed on to children
+	// that start late.
+	hostMetadata map[string]string
+}
+
+// NewFanout returns a new instance of FanoutReporter.
+func NewFanout(children ...Reporter) (*FanoutReporter, error) {
+	if len(children) == 0 {
+		return nil, errors.New("no reporters to fan out to")
+	}
+	return &FanoutReporter{
+		children:     children,
+		startBackoff: fanoutStartBackoff,
+		stopSignal:   make(chan libpf.Void),
+	}, nil
+}
+
+// Start starts all children in the background, so that a slow endpoint does
+// not delay the agent or the other children. Children that fail to start are
+// retried until they start or the reporter is stopped.
+func (f *FanoutReporter) Start(ctx context.Context) error {
+	for i, child := range f.children {
+		go f.startChild(ctx, i, child)
+	}
+	return nil
+}
+
+// startChild starts child, retrying with exponential backoff on failure.
+func (f *FanoutReporter) startChild(ctx context.Context, i int, child Reporter) {
+	backoff := f.startBackoff
+	for {
+		err := child.Start(ctx)
+		if err == nil {
+			break
+		}
+		log.Errorf("Failed to start reporter %d (%T), retrying in %v: %v",
+			i, child, backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.stopSignal:
+			return
+		case <-time.After(libpf.AddJitter(backoff, 0.2)):
+		}
+		backoff = min(2*backoff, fanoutMaxStartBackoff)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		child.Stop()
+		return
+	}
+	// Pass on the host metadata that was forwarded before the child started.
+	if f.hostMetadata != nil {
+		child.ReportHostMetadata(f.hostMetadata)
+	}
+	f.started = append(f.started, child)
+}
+
+// startedChildren returns the children that were started successfully.
+func (f *FanoutReporter) startedChildren() []Reporter {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.started
+}
+
+// Stop stops all started children and ends the start attempts of the others.
+func (f *FanoutReporter) Stop() {
+	f.mu.Lock()
+	if f.stopped {
+		f.mu.Unlock()
+		return
+	}
+	f.stopped = true
+	close(f.stopSignal)
+	started := f.started
+	f.mu.Unlock()
+
+	for _, child := range started {
+		child.Stop()
+	}
+}
+
+// ReportTraceEvent forwards the trace event to all started children.
+func (f *FanoutReporter) ReportTraceEvent(trace *libpf.Trace,
+	meta *samples.TraceEventMeta) error {
+	var errs []error
+	for _, child := range f.startedChildren() {
+		if err := child.ReportTraceEvent(trace, meta); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ExecutableKnown returns true only if all started children know the executable.
+func (f *FanoutReporter) ExecutableKnown(fileID libpf.FileID) bool {
+	children := f.startedChildren()
+	if len(children) == 0 {
+		return false
+	}
+	for _, child := range children {
+		if !child.ExecutableKnown(fileID) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExecutableMetadata forwards the executable metadata to all started children.
+func (f *FanoutReporter) ExecutableMetadata(args *ExecutableMetadataArgs) {
+	for _, child := range f.startedChildren() {
+		child.ExecutableMetadata(args)
+	}
+}
+
+// FrameKnown returns true only if all started children know the frame.
+func (f *FanoutReporter) FrameKnown(frameID libpf.FrameID) bool {
+	children := f.startedChildren()
+	if len(children) == 0 {
+		return false
+	}
+	for _, child := range children {
+		if !child.FrameKnown(frameID) {
+			return false
+		}
+	}
+	return true
+}
+
+// FrameMetadata forwards the frame metadata to all started children.
+func (f *FanoutReporter) FrameMetadata(args *FrameMetadataArgs) {
+	for _, child := range f.startedChildren() {
+		child.FrameMetadata(args)
+	}
+}
+
+// ReportHostMetadata forwards the host metadata to all started children.
+// Children that start later get it once started.
+func (f *FanoutReporter) ReportHostMetadata(metadataMap map[string]string) {
+	f.mu.Lock()
+	f.hostMetadata = metadataMap
+	f.mu.Unlock()
+
+	for _, child := range f.startedChildren() {
+		child.ReportHostMetadata(metadataMap)
+	}
+}
+
+// ReportHostMetadataBlocking forwards the host metadata to all started children
+// concurrently and waits for all of them.
+func (f *FanoutReporter) ReportHostMetadataBlocking(ctx context.Context,
+	metadataMap map[string]string, maxRetries int, waitRetry time.Duration) error {
+	f.mu.Lock()
+	f.hostMetadata = metadataMap
+	f.mu.Unlock()
+
+	children := f.startedChildren()
+	errs := make([]error, len(children))
+
+	var wg sync.WaitGroup
+	for i, child := range children {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = child.ReportHostMetadataBlocking(ctx, metadataMap,
+				maxRetries, waitRetry)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,207 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+)
+
+// childReporter is a Reporter that records the calls it receives.
+type childReporter struct {
+	// startErrs are returned by the calls to Start, in order, before it succeeds.
+	startErrs  []error
+	startDelay time.Duration
+	// release, if set, blocks Start until it is closed.
+	release chan libpf.Void
+	startMu sync.Mutex
+	running atomic.Bool
+
+	traces     int
+	knownFiles libpf.Set[libpf.FileID]
+	knownFrame libpf.Set[libpf.FrameID]
+	hostMeta   map[string]string
+	stopped    bool
+}
+
+func newChildReporter() *childReporter {
+	return &childReporter{
+		knownFiles: libpf.Set[libpf.FileID]{},
+		knownFrame: libpf.Set[libpf.FrameID]{},
+		hostMeta:   map[string]string{},
+	}
+}
+
+func (c *childReporter) Start(context.Context) error {
+	time.Sleep(c.startDelay)
+	if c.release != nil {
+		<-c.release
+	}
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	if len(c.startErrs) > 0 {
+		err := c.startErrs[0]
+		c.startErrs = c.startErrs[1:]
+		return err
+	}
+	c.running.Store(true)
+	return nil
+}
+
+func (c *childReporter) Stop() { c.stopped = true }
+
+// startFanout starts f and waits until the given children are started.
+func startFanout(t *testing.T, f *FanoutReporter, children ...*childReporter) {
+	t.Helper()
+	f.startBackoff = time.Millisecond
+	require.NoError(t, f.Start(context.Background()))
+	waitStarted(t, f, children...)
+}
+
+// waitStarted waits until the given children of f are started.
+func waitStarted(t *testing.T, f *FanoutReporter, children ...*childReporter) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		for _, c := range children {
+			if !c.running.Load() || !slices.Contains(f.startedChildren(), Reporter(c)) {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, time.Millisecond)
+}
+
+func (c *childReporter) ReportTraceEvent(*libpf.Trace, *samples.TraceEventMeta) error {
+	c.traces++
+	return nil
+}
+
+func (c *childReporter) ExecutableKnown(fileID libpf.FileID) bool {
+	_, known := c.knownFiles[fileID]
+	return known
+}
+
+func (c *childReporter) ExecutableMetadata(args *ExecutableMetadataArgs) {
+	c.knownFiles[args.FileID] = libpf.Void{}
+}
+
+func (c *childReporter) FrameKnown(frameID libpf.FrameID) bool {
+	_, known := c.knownFrame[frameID]
+	return known
+}
+
+func (c *childReporter) FrameMetadata(args *FrameMetadataArgs) {
+	c.knownFrame[args.FrameID] = libpf.Void{}
+}
+
+func (c *childReporter) ReportHostMetadata(metadataMap map[string]string) {
+	for k, v := range metadataMap {
+		c.hostMeta[k] = v
+	}
+}
+
+func (c *childReporter) ReportHostMetadataBlocking(_ context.Context,
+	metadataMap map[string]string, _ int, _ time.Duration) error {
+	c.ReportHostMetadata(metadataMap)
+	return nil
+}
+
+func TestFanoutReporterForwarding(t *testing.T) {
+	a, b := newChildReporter(), newChildReporter()
+	f, err := NewFanout(a, b)
+	require.NoError(t, err)
+	startFanout(t, f, a, b)
+
+	require.NoError(t, f.ReportTraceEvent(&libpf.Trace{}, &samples.TraceEventMeta{}))
+	assert.Equal(t, 1, a.traces)
+	assert.Equal(t, 1, b.traces)
+
+	fileID := libpf.NewFileID(1, 2)
+	a.knownFiles[fileID] = libpf.Void{}
+	assert.False(t, f.ExecutableKnown(fileID))
+	f.ExecutableMetadata(&ExecutableMetadataArgs{FileID: fileID})
+	assert.True(t, f.ExecutableKnown(fileID))
+
+	frameID := libpf.NewFrameID(fileID, 42)
+	b.knownFrame[frameID] = libpf.Void{}
+	assert.False(t, f.FrameKnown(frameID))
+	f.FrameMetadata(&FrameMetadataArgs{FrameID: frameID})
+	assert.True(t, f.FrameKnown(frameID))
+
+	f.ReportHostMetadata(map[string]string{"k": "v"})
+	require.NoError(t, f.ReportHostMetadataBlocking(context.Background(),
+		map[string]string{"k2": "v2"}, 1, time.Second))
+	for _, c := range []*childReporter{a, b} {
+		assert.Equal(t, map[string]string{"k": "v", "k2": "v2"}, c.hostMeta)
+	}
+
+	f.Stop()
+	assert.True(t, a.stopped)
+	assert.True(t, b.stopped)
+}
+
+func TestFanoutReporterStart(t *testing.T) {
+	errStart := errors.New("endpoint unreachable")
+
+	t.Run("failing child is retried", func(t *testing.T) {
+		ok, failing := newChildReporter(), newChildReporter()
+		failing.startErrs = []error{errStart, errStart}
+		failing.release = make(chan libpf.Void)
+
+		f, err := NewFanout(ok, failing)
+		require.NoError(t, err)
+		startFanout(t, f, ok)
+		f.ReportHostMetadata(map[string]string{"k": "v"})
+
+		// The failing child receives no data until it started.
+		require.NoError(t, f.ReportTraceEvent(&libpf.Trace{}, &samples.TraceEventMeta{}))
+		assert.Equal(t, 1, ok.traces)
+
+		close(failing.release)
+		waitStarted(t, f, failing)
+		assert.Equal(t, map[string]string{"k": "v"}, failing.hostMeta)
+		require.NoError(t, f.ReportTraceEvent(&libpf.Trace{}, &samples.TraceEventMeta{}))
+		assert.Equal(t, 2, ok.traces)
+		assert.Equal(t, 1, failing.traces)
+	})
+
+	t.Run("start does not wait for children", func(t *testing.T) {
+		a, b := newChildReporter(), newChildReporter()
+		a.startDelay = 200 * time.Millisecond
+		b.startDelay = 200 * time.Millisecond
+
+		f, err := NewFanout(a, b)
+		require.NoError(t, err)
+		start := time.Now()
+		require.NoError(t, f.Start(context.Background()))
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+		waitStarted(t, f, a, b)
+	})
+
+	t.Run("stop ends retries", func(t *testing.T) {
+		failing := newChildReporter()
+		failing.startErrs = []error{errStart}
+		failing.startDelay = 50 * time.Millisecond
+
+		f, err := NewFanout(failing)
+		require.NoError(t, err)
+		f.startBackoff = time.Hour
+		require.NoError(t, f.Start(context.Background()))
+		f.Stop()
+		assert.Empty(t, f.startedChildren())
+	})
+}
+
+func TestNewFanoutWithoutChildren(t *testing.T) {
+	_, err := NewFanout()
+	require.Error(t, err)
+}
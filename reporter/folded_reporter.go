@@ -0,0 +1,271 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package reporter // import "go.opentelemetry.io/ebpf-profiler/reporter"
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/internal/pdata"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+// Assert that we implement the full Reporter interface.
+var _ Reporter = (*FoldedReporter)(nil)
+
+const (
+	// FoldedGroupByPID prefixes folded stacks with the PID.
+	FoldedGroupByPID = "pid"
+	// FoldedGroupByContainer prefixes folded stacks with the container ID.
+	FoldedGroupByContainer = "container"
+	// FoldedGroupByComm prefixes folded stacks with the thread name.
+	FoldedGroupByComm = "comm"
+
+	// FoldedStdout selects stdout as output of the FoldedReporter.
+	FoldedStdout = "-"
+
+	// foldedOffCPUSuffix is appended to the output file name for off-CPU stacks.
+	foldedOffCPUSuffix = ".offcpu"
+	// foldedOffCPURoot is the root frame of off-CPU stacks written to stdout.
+	foldedOffCPURoot = "[off-cpu]"
+
+	// foldedWakeupSuffix is appended to the output file name for wakeup stacks.
+	foldedWakeupSuffix = ".wakeup"
+	// foldedWakeupRoot is the root frame of wakeup stacks written to stdout.
+	foldedWakeupRoot = "[wakeup]"
+
+	// foldedProbeSuffix is appended to the output file name for probe stacks.
+	foldedProbeSuffix = ".probe"
+	// foldedProbeRoot is the root frame of probe stacks written to stdout.
+	foldedProbeRoot = "[probe]"
+)
+
+// FoldedReporter writes folded stacks ("frame;frame;frame count"), as consumed
+// by flamegraph tools, to a file or stdout every report interval.
+//
+// On-CPU stacks are counted in samples, off-CPU stacks in nanoseconds, wakeup
+// stacks in wakeups and probe stacks in probe hits. If the output is a file,
+// off-CPU, wakeup and probe stacks are appended to separate files with the
+// ".offcpu", ".wakeup" and ".probe" suffixes. On stdout, they get an
+// "[off-cpu]", "[wakeup]" or "[probe]" root frame. Stacks of event
+// origins are counted in events and are handled the same way, using the name
+// of the event origin as file name suffix and root frame.
+type FoldedReporter struct {
+	*baseReporter
+
+	// output is the path of the output file or FoldedStdout.
+	output string
+
+	// groupBy is the optional per-stack grouping.
+	groupBy string
+}
+
+// NewFolded returns a new instance of FoldedReporter.
+func NewFolded(cfg *Config) (*FoldedReporter, error) {
+	switch cfg.FoldedGroupBy {
+	case "", FoldedGroupByPID, FoldedGroupByContainer, FoldedGroupByComm:
+	default:
+		return nil, fmt.Errorf("unsupported folded stacks grouping: %s", cfg.FoldedGroupBy)
+	}
+
+	base, err := newBaseReporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	output := cfg.FoldedOutput
+	if output == "" {
+		output = FoldedStdout
+	}
+
+	return &FoldedReporter{
+		baseReporter: base,
+		output:       output,
+		groupBy:      cfg.FoldedGroupBy,
+	}, nil
+}
+
+// Start starts writing folded stacks in the background.
+func (r *FoldedReporter) Start(ctx context.Context) error {
+	r.runLoop.Start(ctx, r.cfg.ReportInterval, func() {
+		if err := r.reportFolded(); err != nil {
+			log.Errorf("Writing folded stacks failed: %v", err)
+		}
+	}, r.purge)
+
+	return nil
+}
+
+// Stop stops the reporter and writes out the folded stacks collected since
+// the last report.
+func (r *FoldedReporter) Stop() {
+	r.runLoop.StopAndWait()
+	if err := r.reportFolded(); err != nil {
+		log.Errorf("Writing final folded stacks failed: %v", err)
+	}
+}
+
+// reportFolded writes the folded stacks collected since the last call.
+func (r *FoldedReporter) reportFolded() error {
+	events := r.takeTraceEvents()
+
+	if r.output == FoldedStdout {
+		w := bufio.NewWriter(os.Stdout)
+		r.writeFolded(w, events[support.TraceOriginSampling], "")
+		r.writeFolded(w, events[support.TraceOriginOffCPU], foldedOffCPURoot)
+		r.writeFolded(w, events[support.TraceOriginWakeup], foldedWakeupRoot)
+		r.writeFolded(w, events[support.TraceOriginProbe], foldedProbeRoot)
+		for _, eventOrigin := range r.cfg.EventOrigins {
+			r.writeFolded(w, events[eventOrigin.Origin], "["+eventOrigin.Name+"]")
+		}
+		return w.Flush()
+	}
+
+	errs := []error{
+		r.appendFolded(r.output, events[support.TraceOriginSampling]),
+		r.appendFolded(r.output+foldedOffCPUSuffix, events[support.TraceOriginOffCPU]),
+		r.appendFolded(r.output+foldedWakeupSuffix, events[support.TraceOriginWakeup]),
+		r.appendFolded(r.output+foldedProbeSuffix, events[support.TraceOriginProbe]),
+	}
+	for _, eventOrigin := range r.cfg.EventOrigins {
+		errs = append(errs,
+			r.appendFolded(r.output+"."+eventOrigin.Name, events[eventOrigin.Origin]))
+	}
+	return errors.Join(errs...)
+}
+
+// appendFolded appends the folded stacks for events to the file fileName.
+func (r *FoldedReporter) appendFolded(fileName string, events samples.KeyToEventMapping) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	r.writeFolded(w, events, "")
+	if err = w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeFolded writes the folded stacks for events to w, optionally prefixed by
+// the root frame root. The lines are sorted to get a stable output.
+func (r *FoldedReporter) writeFolded(w io.Writer, events samples.KeyToEventMapping,
+	root string) {
+	counts := make(map[string]int64, len(events))
+	for key, traceInfo := range events {
+		counts[r.foldStack(key, traceInfo, root)] += foldedValue(traceInfo)
+	}
+
+	stacks := make([]string, 0, len(counts))
+	for stack := range counts {
+		stacks = append(stacks, stack)
+	}
+	slices.Sort(stacks)
+
+	for _, stack := range stacks {
+		fmt.Fprintf(w, "%s %d\n", stack, counts[stack])
+	}
+}
+
+// foldedValue returns the value of a folded stack: the number of samples for
+// on-CPU traces and the accumulated off-CPU time for off-CPU traces.
+func foldedValue(traceInfo *samples.TraceEvents) int64 {
+	var offTime int64
+	for _, t := range traceInfo.OffTimes {
+		offTime += t
+	}
+	if offTime > 0 {
+		return offTime
+	}
+	return int64(len(traceInfo.Timestamps))
+}
+
+// foldStack returns the folded representation of a single trace, root first.
+func (r *FoldedReporter) foldStack(key samples.TraceAndMetaKey,
+	traceInfo *samples.TraceEvents, root string) string {
+	frames := make([]string, 0, len(traceInfo.FrameTypes)+2)
+	if root != "" {
+		frames = append(frames, root)
+	}
+
+	switch r.groupBy {
+	case FoldedGroupByPID:
+		frames = append(frames, "pid:"+strconv.FormatInt(key.Pid, 10))
+	case FoldedGroupByContainer:
+		containerID := key.ContainerID
+		if containerID == "" {
+			containerID = "none"
+		}
+		frames = append(frames, "container:"+sanitizeFoldedFrame(containerID))
+	case FoldedGroupByComm:
+		frames = append(frames, "comm:"+sanitizeFoldedFrame(key.Comm))
+	}
+
+	// Traces store the leaf frame first.
+	for i := len(traceInfo.FrameTypes) - 1; i >= 0; i-- {
+		if traceInfo.FrameTypes[i] == libpf.AbortFrame {
+			continue
+		}
+		frames = append(frames, sanitizeFoldedFrame(r.frameName(traceInfo, i)))
+	}
+
+	return strings.Join(frames, ";")
+}
+
+// frameName returns the name of the i-th frame of traceInfo.
+func (r *FoldedReporter) frameName(traceInfo *samples.TraceEvents, i int) string {
+	fileID := traceInfo.Files[i]
+	addressOrLine := traceInfo.Linenos[i]
+	frameType := traceInfo.FrameTypes[i]
+
+	if frameType == libpf.NativeFrame {
+		fileName := "UNKNOWN"
+		if ei, exists := r.pdata.Executables.GetAndRefresh(fileID,
+			pdata.ExecutableCacheLifetime); exists {
+			fileName = ei.FileName
+		}
+		return fmt.Sprintf("%s+0x%x", fileName, uint64(addressOrLine))
+	}
+
+	fileIDInfoLock, exists := r.pdata.Frames.GetAndRefresh(fileID, pdata.FramesCacheLifetime)
+	if !exists {
+		return "UNREPORTED " + frameType.String()
+	}
+	fileIDInfo := fileIDInfoLock.RLock()
+	defer fileIDInfoLock.RUnlock(&fileIDInfo)
+
+	si, exists := (*fileIDInfo)[addressOrLine]
+	if !exists || si.FunctionName == "" {
+		return "UNRESOLVED " + frameType.String()
+	}
+	return si.FunctionName
+}
+
+// foldedFrameReplacer replaces the characters with a special meaning in the
+// folded stacks format.
+var foldedFrameReplacer = strings.NewReplacer(";", ":", "\n", " ")
+
+// sanitizeFoldedFrame replaces the characters with a special meaning in the
+// folded stacks format.
+func sanitizeFoldedFrame(name string) string {
+	return foldedFrameReplacer.Replace(name)
+}
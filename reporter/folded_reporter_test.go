@@ -0,0 +1,153 @@
+package reporter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+func TestFoldedReporter(t *testing.T) {
+	nativeID := libpf.NewFileID(1, 1)
+	pythonID := libpf.NewFileID(2, 2)
+
+	// Leaf frame first, as reported by the tracer.
+	trace := &libpf.Trace{
+		Files:              []libpf.FileID{pythonID, pythonID, nativeID},
+		Linenos:            []libpf.AddressOrLineno{0x20, 0x10, 0x1234},
+		FrameTypes:         []libpf.FrameType{libpf.PythonFrame, libpf.PythonFrame, libpf.NativeFrame},
+		MappingStart:       []libpf.Address{0, 0, 0},
+		MappingEnd:         []libpf.Address{0, 0, 0},
+		MappingFileOffsets: []uint64{0, 0, 0},
+	}
+
+	for _, tt := range []struct {
+		name    string
+		groupBy string
+		want    string
+	}{
+		{
+			name: "no grouping",
+			want: "python3+0x1234;main;handle:req 2\n",
+		},
+		{
+			name:    "by pid",
+			groupBy: FoldedGroupByPID,
+			want: "pid:1;python3+0x1234;main;handle:req 1\n" +
+				"pid:2;python3+0x1234;main;handle:req 1\n",
+		},
+		{
+			name:    "by comm",
+			groupBy: FoldedGroupByComm,
+			want:    "comm:worker;python3+0x1234;main;handle:req 2\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewFolded(&Config{
+				ExecutablesCacheElements: 4,
+				FramesCacheElements:      4,
+				CGroupCacheElements:      4,
+				FoldedGroupBy:            tt.groupBy,
+			})
+			require.NoError(t, err)
+
+			r.ExecutableMetadata(&ExecutableMetadataArgs{
+				FileID:   nativeID,
+				FileName: "python3",
+			})
+			r.FrameMetadata(&FrameMetadataArgs{
+				FrameID:      libpf.NewFrameID(pythonID, 0x10),
+				FunctionName: "main",
+			})
+			// Separators in function names must not break the format.
+			r.FrameMetadata(&FrameMetadataArgs{
+				FrameID:      libpf.NewFrameID(pythonID, 0x20),
+				FunctionName: "handle;req",
+			})
+
+			for _, pid := range []libpf.PID{1, 2} {
+				require.NoError(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+					Comm:   "worker",
+					PID:    pid,
+					Origin: support.TraceOriginSampling,
+				}))
+			}
+
+			var buf bytes.Buffer
+			events := r.takeTraceEvents()
+			r.writeFolded(&buf, events[support.TraceOriginSampling], "")
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestFoldedReporterFile(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "stacks.folded")
+	r, err := NewFolded(&Config{
+		ExecutablesCacheElements: 1,
+		FramesCacheElements:      1,
+		CGroupCacheElements:      1,
+		FoldedOutput:             output,
+		EventOrigins: []samples.EventOrigin{{
+			Origin: support.TraceOriginKernelEvent,
+			Name:   "tcp_retransmit_skb",
+		}},
+	})
+	require.NoError(t, err)
+
+	trace := &libpf.Trace{
+		Files:              []libpf.FileID{libpf.NewFileID(1, 1)},
+		Linenos:            []libpf.AddressOrLineno{0x10},
+		FrameTypes:         []libpf.FrameType{libpf.NativeFrame},
+		MappingStart:       []libpf.Address{0},
+		MappingEnd:         []libpf.Address{0},
+		MappingFileOffsets: []uint64{0},
+	}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+			Origin: support.TraceOriginSampling,
+		}))
+		require.NoError(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+			Origin:  support.TraceOriginOffCPU,
+			OffTime: 500,
+		}))
+		require.NoError(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+			Origin: support.TraceOriginProbe,
+		}))
+		require.NoError(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+			Origin: support.TraceOriginKernelEvent,
+		}))
+		require.ErrorIs(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+			Origin: support.TraceOriginKernelEvent + 1,
+		}), errUnknownOrigin)
+		require.NoError(t, r.reportFolded())
+	}
+
+	onCPU, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "UNKNOWN+0x10 1\nUNKNOWN+0x10 1\n", string(onCPU))
+
+	offCPU, err := os.ReadFile(output + foldedOffCPUSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, "UNKNOWN+0x10 500\nUNKNOWN+0x10 500\n", string(offCPU))
+
+	probe, err := os.ReadFile(output + foldedProbeSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, "UNKNOWN+0x10 1\nUNKNOWN+0x10 1\n", string(probe))
+
+	event, err := os.ReadFile(output + ".tcp_retransmit_skb")
+	require.NoError(t, err)
+	assert.Equal(t, "UNKNOWN+0x10 1\nUNKNOWN+0x10 1\n", string(event))
+}
+
+func TestNewFoldedInvalidGroupBy(t *testing.T) {
+	_, err := NewFolded(&Config{FoldedGroupBy: "host"})
+	require.Error(t, err)
+}
@@ -72,6 +72,8 @@ type FrameMetadataArgs struct {
 	SourceLine libpf.SourceLineno
 	// FunctionOffset is the line offset from function start line for the frame.
 	FunctionOffset uint32
+	// ModuleName is the name of the module the function belongs to, if known.
+	ModuleName string
 }
 
 type SymbolReporter interface {
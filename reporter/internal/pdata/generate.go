@@ -4,7 +4,9 @@
 package pdata // import "go.opentelemetry.io/ebpf-profiler/reporter/internal/pdata"
 
 import (
+	"cmp"
 	"crypto/rand"
+	"maps"
 	"path/filepath"
 	"slices"
 	"time"
@@ -24,6 +26,25 @@ import (
 const (
 	ExecutableCacheLifetime = 1 * time.Hour
 	FramesCacheLifetime     = 1 * time.Hour
+
+	// processStartTimeKey holds the start time of the process in RFC 3339 format.
+	processStartTimeKey = attribute.Key("process.start_time")
+	// processAncestorsKey holds the names of the ancestor processes, starting
+	// with the parent.
+	processAncestorsKey = attribute.Key("process.ancestors")
+
+	// The wakeup keys link off-CPU samples of woken up tasks to the wakeup
+	// samples of the tasks that woke them up.
+	wakerPIDKey = attribute.Key("wakeup.waker.pid")
+	wakerTIDKey = attribute.Key("wakeup.waker.tid")
+	wakeePIDKey = attribute.Key("wakeup.wakee.pid")
+	wakeeTIDKey = attribute.Key("wakeup.wakee.tid")
+
+	// taskStateKey holds the state of an off-CPU task when it was scheduled out.
+	taskStateKey = attribute.Key("offcpu.task_state")
+	// blockingReasonKey holds the reason an off-CPU task blocked, e.g. futex or
+	// block_io.
+	blockingReasonKey = attribute.Key("offcpu.blocking_reason")
 )
 
 // Generate generates a pdata request out of internal profiles data, to be
@@ -32,20 +53,52 @@ func (p *Pdata) Generate(events map[libpf.Origin]samples.KeyToEventMapping) ppro
 	profiles := pprofile.NewProfiles()
 	rp := profiles.ResourceProfiles().AppendEmpty()
 	sp := rp.ScopeProfiles().AppendEmpty()
-	for _, origin := range []libpf.Origin{support.TraceOriginSampling,
-		support.TraceOriginOffCPU} {
+	origins := []libpf.Origin{support.TraceOriginSampling,
+		support.TraceOriginOffCPU, support.TraceOriginWakeup, support.TraceOriginProbe}
+	for _, eventOrigin := range p.eventOrigins {
+		origins = append(origins, eventOrigin.Origin)
+	}
+	for _, origin := range origins {
 		if len(events[origin]) == 0 {
 			// Do not append empty profiles, if there
 			// is not profiling data for this origin.
 			continue
 		}
+		if origin == support.TraceOriginSampling {
+			// Each sampling frequency results in a different profile period.
+			byFrequency := p.splitBySamplingFrequency(events[origin])
+			for _, samplesPerSecond := range slices.Sorted(maps.Keys(byFrequency)) {
+				prof := sp.Profiles().AppendEmpty()
+				prof.SetProfileID(pprofile.ProfileID(mkProfileID()))
+				p.setProfile(origin, samplesPerSecond, byFrequency[samplesPerSecond], prof)
+			}
+			continue
+		}
 		prof := sp.Profiles().AppendEmpty()
 		prof.SetProfileID(pprofile.ProfileID(mkProfileID()))
-		p.setProfile(origin, events[origin], prof)
+		p.setProfile(origin, p.samplesPerSecond, events[origin], prof)
 	}
 	return profiles
 }
 
+// splitBySamplingFrequency groups the on-CPU events by the sampling frequency
+// they were collected with. Events without a frequency use the configured one.
+func (p *Pdata) splitBySamplingFrequency(
+	events samples.KeyToEventMapping) map[int]samples.KeyToEventMapping {
+	byFrequency := make(map[int]samples.KeyToEventMapping, 1)
+	for key, traceEvents := range events {
+		samplesPerSecond := key.SamplesPerSecond
+		if samplesPerSecond <= 0 {
+			samplesPerSecond = p.samplesPerSecond
+		}
+		if _, ok := byFrequency[samplesPerSecond]; !ok {
+			byFrequency[samplesPerSecond] = make(samples.KeyToEventMapping)
+		}
+		byFrequency[samplesPerSecond][key] = traceEvents
+	}
+	return byFrequency
+}
+
 // mkProfileID creates a random profile ID.
 func mkProfileID() []byte {
 	profileID := make([]byte, 16)
@@ -60,6 +113,7 @@ func mkProfileID() []byte {
 // this moment.
 func (p *Pdata) setProfile(
 	origin libpf.Origin,
+	samplesPerSecond int,
 	events map[samples.TraceAndMetaKey]*samples.TraceEvents,
 	profile pprofile.Profile,
 ) {
@@ -73,6 +127,9 @@ func (p *Pdata) setProfile(
 	funcMap := make(map[samples.FuncInfo]int32)
 	funcMap[samples.FuncInfo{Name: "", FileName: ""}] = 0
 
+	// sampleValue is the value of samples with a count based sample type.
+	sampleValue := int64(1)
+
 	st := profile.SampleType().AppendEmpty()
 	switch origin {
 	case support.TraceOriginSampling:
@@ -83,13 +140,37 @@ func (p *Pdata) setProfile(
 		pt.SetTypeStrindex(getStringMapIndex(stringMap, "cpu"))
 		pt.SetUnitStrindex(getStringMapIndex(stringMap, "nanoseconds"))
 
-		profile.SetPeriod(1e9 / int64(p.samplesPerSecond))
+		profile.SetPeriod(1e9 / int64(samplesPerSecond))
 	case support.TraceOriginOffCPU:
 		st.SetTypeStrindex(getStringMapIndex(stringMap, "events"))
 		st.SetUnitStrindex(getStringMapIndex(stringMap, "nanoseconds"))
+	case support.TraceOriginWakeup:
+		st.SetTypeStrindex(getStringMapIndex(stringMap, "wakeups"))
+		st.SetUnitStrindex(getStringMapIndex(stringMap, "count"))
+	case support.TraceOriginProbe:
+		st.SetTypeStrindex(getStringMapIndex(stringMap, "probe"))
+		st.SetUnitStrindex(getStringMapIndex(stringMap, "count"))
 	default:
-		log.Errorf("Generating profile for unsupported origin %d", origin)
-		return
+		idx := slices.IndexFunc(p.eventOrigins, func(e samples.EventOrigin) bool {
+			return e.Origin == origin
+		})
+		if idx < 0 {
+			log.Errorf("Generating profile for unsupported origin %d", origin)
+			return
+		}
+		eventOrigin := p.eventOrigins[idx]
+		st.SetTypeStrindex(getStringMapIndex(stringMap, eventOrigin.SampleType))
+		st.SetUnitStrindex(getStringMapIndex(stringMap, eventOrigin.SampleUnit))
+
+		if eventOrigin.Period > 0 {
+			// Every sample stands for Period events.
+			pt := profile.PeriodType()
+			pt.SetTypeStrindex(getStringMapIndex(stringMap, eventOrigin.SampleType))
+			pt.SetUnitStrindex(getStringMapIndex(stringMap, eventOrigin.SampleUnit))
+
+			profile.SetPeriod(eventOrigin.Period)
+			sampleValue = eventOrigin.Period
+		}
 	}
 
 	// Temporary lookup to reference existing Mappings.
@@ -102,6 +183,9 @@ func (p *Pdata) setProfile(
 		sample := profile.Sample().AppendEmpty()
 		sample.SetLocationsStartIndex(locationIndex)
 
+		// Group the events before the timestamps are sorted.
+		wakeupGroups := groupByWakeup(traceInfo)
+
 		slices.Sort(traceInfo.Timestamps)
 		startTS = pcommon.Timestamp(traceInfo.Timestamps[0])
 		endTS = pcommon.Timestamp(traceInfo.Timestamps[len(traceInfo.Timestamps)-1])
@@ -109,10 +193,10 @@ func (p *Pdata) setProfile(
 		sample.TimestampsUnixNano().FromRaw(traceInfo.Timestamps)
 
 		switch origin {
-		case support.TraceOriginSampling:
-			sample.Value().Append(1)
 		case support.TraceOriginOffCPU:
 			sample.Value().Append(traceInfo.OffTimes...)
+		default:
+			sample.Value().Append(sampleValue)
 		}
 
 		// Walk every frame of the trace.
@@ -177,14 +261,19 @@ func (p *Pdata) setProfile(
 					// At this point, we do not have enough information for the frame.
 					// Therefore, we report a dummy entry and use the interpreter as filename.
 					line.SetFunctionIndex(createFunctionEntry(funcMap,
-						"UNREPORTED", frameKind.String()))
+						"UNREPORTED", "", frameKind.String()))
 				} else {
 					fileIDInfo := fileIDInfoLock.RLock()
 					if si, exists := (*fileIDInfo)[traceInfo.Linenos[i]]; exists {
 						line.SetLine(int64(si.LineNumber))
 
+						// The system name qualifies the function with its module.
+						systemName := ""
+						if si.ModuleName != "" {
+							systemName = si.ModuleName + "." + si.FunctionName
+						}
 						line.SetFunctionIndex(createFunctionEntry(funcMap,
-							si.FunctionName, si.FilePath))
+							si.FunctionName, systemName, si.FilePath))
 					} else {
 						// At this point, we do not have enough information for the frame.
 						// Therefore, we report a dummy entry and use the interpreter as filename.
@@ -192,7 +281,7 @@ func (p *Pdata) setProfile(
 						// the file ID is available at all, we use a different name for reported
 						// function.
 						line.SetFunctionIndex(createFunctionEntry(funcMap,
-							"UNRESOLVED", frameKind.String()))
+							"UNRESOLVED", "", frameKind.String()))
 					}
 					fileIDInfoLock.RUnlock(&fileIDInfo)
 				}
@@ -210,6 +299,11 @@ func (p *Pdata) setProfile(
 
 		attrMgr.AppendOptionalString(sample.AttributeIndices(),
 			semconv.ContainerIDKey, traceKey.ContainerID)
+		if p.ContainerMetadata != nil && traceKey.ContainerID != "" {
+			for _, attr := range p.ContainerMetadata.ContainerMetadata(traceKey.ContainerID) {
+				attrMgr.AppendAttribute(sample.AttributeIndices(), attr)
+			}
+		}
 		attrMgr.AppendOptionalString(sample.AttributeIndices(),
 			semconv.ThreadNameKey, traceKey.Comm)
 
@@ -222,6 +316,28 @@ func (p *Pdata) setProfile(
 			semconv.ServiceNameKey, traceKey.ApmServiceName)
 		attrMgr.AppendInt(sample.AttributeIndices(),
 			semconv.ProcessPIDKey, traceKey.Pid)
+		attrMgr.AppendOptionalString(sample.AttributeIndices(),
+			semconv.ProcessCommandLineKey, traceKey.CommandLine)
+		if traceKey.ParentPid != 0 {
+			attrMgr.AppendInt(sample.AttributeIndices(),
+				semconv.ProcessParentPIDKey, traceKey.ParentPid)
+		}
+		if traceKey.StartTime != 0 {
+			attrMgr.AppendOptionalString(sample.AttributeIndices(),
+				processStartTimeKey,
+				time.Unix(0, traceKey.StartTime).UTC().Format(time.RFC3339Nano))
+		}
+		attrMgr.AppendAttribute(sample.AttributeIndices(),
+			processAncestorsKey.StringSlice(traceInfo.Ancestors))
+		attrMgr.AppendOptionalString(sample.AttributeIndices(),
+			taskStateKey, traceKey.TaskState)
+		attrMgr.AppendOptionalString(sample.AttributeIndices(),
+			blockingReasonKey, traceKey.BlockingReason)
+
+		for key, value := range traceInfo.CustomLabels {
+			attrMgr.AppendOptionalString(sample.AttributeIndices(),
+				attribute.Key(key), value)
+		}
 
 		for key, value := range traceInfo.EnvVars {
 			attrMgr.AppendOptionalString(
@@ -236,6 +352,9 @@ func (p *Pdata) setProfile(
 		}
 
 		sample.SetLocationsLength(int32(len(traceInfo.FrameTypes)))
+		if wakeupGroups != nil {
+			splitByWakeup(profile, sample, origin, wakeupGroups, attrMgr)
+		}
 		locationIndex += sample.LocationsLength()
 	}
 	log.Debugf("Reporting OTLP profile with %d samples", profile.Sample().Len())
@@ -249,6 +368,7 @@ func (p *Pdata) setProfile(
 	for v, idx := range funcMap {
 		f := funcTable.At(int(idx))
 		f.SetNameStrindex(getStringMapIndex(stringMap, v.Name))
+		f.SetSystemNameStrindex(getStringMapIndex(stringMap, v.SystemName))
 		f.SetFilenameStrindex(getStringMapIndex(stringMap, v.FileName))
 	}
 
@@ -274,6 +394,84 @@ func (p *Pdata) setProfile(
 	profile.SetStartTime(startTS)
 }
 
+// wakeupEvent holds the timestamp and off-CPU time of an event with a wakeup link.
+type wakeupEvent struct {
+	timestamp uint64
+	offTime   int64
+}
+
+// wakeupGroup holds the events of a trace that share the same wakeup link.
+type wakeupGroup struct {
+	link   libpf.WakeupLink
+	events []wakeupEvent
+}
+
+// groupByWakeup groups the events of traceInfo by their wakeup link. It returns
+// nil if none of the events has a wakeup link.
+func groupByWakeup(traceInfo *samples.TraceEvents) []wakeupGroup {
+	if len(traceInfo.Wakeups) != len(traceInfo.Timestamps) ||
+		len(traceInfo.OffTimes) != len(traceInfo.Timestamps) {
+		return nil
+	}
+
+	var groups []wakeupGroup
+	groupIndex := make(map[libpf.WakeupLink]int)
+	linked := false
+	for i, link := range traceInfo.Wakeups {
+		idx, exists := groupIndex[link]
+		if !exists {
+			idx = len(groups)
+			groupIndex[link] = idx
+			groups = append(groups, wakeupGroup{link: link})
+		}
+		groups[idx].events = append(groups[idx].events, wakeupEvent{
+			timestamp: traceInfo.Timestamps[i],
+			offTime:   traceInfo.OffTimes[i],
+		})
+		linked = linked || link != (libpf.WakeupLink{})
+	}
+	if !linked {
+		return nil
+	}
+	return groups
+}
+
+// splitByWakeup replaces sample with one sample per group, holding the events
+// of the group and the attributes of its wakeup link.
+func splitByWakeup(profile pprofile.Profile, sample pprofile.Sample, origin libpf.Origin,
+	groups []wakeupGroup, attrMgr *samples.AttrTableManager) {
+	split := make([]pprofile.Sample, len(groups))
+	split[0] = sample
+	for i := 1; i < len(groups); i++ {
+		split[i] = profile.Sample().AppendEmpty()
+		sample.CopyTo(split[i])
+	}
+
+	for i, group := range groups {
+		s := split[i]
+		slices.SortFunc(group.events, func(a, b wakeupEvent) int {
+			return cmp.Compare(a.timestamp, b.timestamp)
+		})
+		timestamps := make([]uint64, 0, len(group.events))
+		offTimes := make([]int64, 0, len(group.events))
+		for _, event := range group.events {
+			timestamps = append(timestamps, event.timestamp)
+			offTimes = append(offTimes, event.offTime)
+		}
+		s.TimestampsUnixNano().FromRaw(timestamps)
+		if origin == support.TraceOriginOffCPU {
+			s.Value().FromRaw(offTimes)
+		}
+
+		if link := group.link; link != (libpf.WakeupLink{}) {
+			attrMgr.AppendInt(s.AttributeIndices(), wakerPIDKey, int64(link.WakerPID))
+			attrMgr.AppendInt(s.AttributeIndices(), wakerTIDKey, int64(link.WakerTID))
+			attrMgr.AppendInt(s.AttributeIndices(), wakeePIDKey, int64(link.WakeePID))
+			attrMgr.AppendInt(s.AttributeIndices(), wakeeTIDKey, int64(link.WakeeTID))
+		}
+	}
+}
+
 // getStringMapIndex inserts or looks up the index for value in stringMap.
 func getStringMapIndex(stringMap map[string]int32, value string) int32 {
 	if idx, exists := stringMap[value]; exists {
@@ -288,10 +486,11 @@ func getStringMapIndex(stringMap map[string]int32, value string) int32 {
 
 // createFunctionEntry adds a new function and returns its reference index.
 func createFunctionEntry(funcMap map[samples.FuncInfo]int32,
-	name string, fileName string) int32 {
+	name, systemName, fileName string) int32 {
 	key := samples.FuncInfo{
-		Name:     name,
-		FileName: fileName,
+		Name:       name,
+		SystemName: systemName,
+		FileName:   fileName,
 	}
 	if idx, exists := funcMap[key]; exists {
 		return idx
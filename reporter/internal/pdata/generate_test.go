@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/pdata/pprofile"
+	"go.opentelemetry.io/otel/attribute"
 
 	"go.opentelemetry.io/ebpf-profiler/libpf"
 	"go.opentelemetry.io/ebpf-profiler/libpf/xsync"
@@ -51,10 +52,11 @@ func TestGetStringMapIndex(t *testing.T) {
 
 func TestCreateFunctionEntry(t *testing.T) {
 	for _, tt := range []struct {
-		name     string
-		funcMap  map[samples.FuncInfo]int32
-		funcName string
-		fileName string
+		name       string
+		funcMap    map[samples.FuncInfo]int32
+		funcName   string
+		systemName string
+		fileName   string
 
 		wantIndex   int32
 		wantFuncMap map[samples.FuncInfo]int32
@@ -83,11 +85,26 @@ func TestCreateFunctionEntry(t *testing.T) {
 				{Name: "my_method", FileName: "/tmp"}: 42,
 			},
 		},
+		{
+			name: "with an entry differing in the system name",
+			funcMap: map[samples.FuncInfo]int32{
+				{Name: "my_method", FileName: "/tmp"}: 0,
+			},
+			funcName:   "my_method",
+			systemName: "my_module.my_method",
+			fileName:   "/tmp",
+
+			wantIndex: 1,
+			wantFuncMap: map[samples.FuncInfo]int32{
+				{Name: "my_method", FileName: "/tmp"}:                                    0,
+				{Name: "my_method", SystemName: "my_module.my_method", FileName: "/tmp"}: 1,
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			funcMap := tt.funcMap
 
-			i := createFunctionEntry(funcMap, tt.funcName, tt.fileName)
+			i := createFunctionEntry(funcMap, tt.funcName, tt.systemName, tt.fileName)
 			assert.Equal(t, tt.wantIndex, i)
 			assert.Equal(t, tt.wantFuncMap, funcMap)
 		})
@@ -247,7 +264,7 @@ func TestFunctionTableOrder(t *testing.T) {
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			d, err := New(100, 100, 100, nil)
+			d, err := New(100, 100, 100, nil, nil, nil)
 			require.NoError(t, err)
 			for k, v := range tt.frames {
 				frames := xsync.NewRWMutex[map[libpf.AddressOrLineno]samples.SourceInfo](v)
@@ -275,3 +292,270 @@ func TestFunctionTableOrder(t *testing.T) {
 		})
 	}
 }
+
+// staticContainerMetadata returns the same attributes for every container.
+type staticContainerMetadata []attribute.KeyValue
+
+func (s staticContainerMetadata) ContainerMetadata(string) []attribute.KeyValue {
+	return s
+}
+
+func TestGenerateContainerMetadata(t *testing.T) {
+	d, err := New(100, 100, 100, nil, staticContainerMetadata{
+		attribute.String("k8s.pod.name", "web-0"),
+		attribute.String("k8s.namespace.name", "shop"),
+	}, nil)
+	require.NoError(t, err)
+
+	events := map[libpf.Origin]samples.KeyToEventMapping{
+		support.TraceOriginSampling: {
+			{Pid: 1, ContainerID: "/kubepods/pod1/abc"}: {Timestamps: []uint64{1}},
+			{Pid: 2}: {Timestamps: []uint64{2}},
+		},
+	}
+	res := d.Generate(events)
+	p := res.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles().At(0)
+	require.Equal(t, 2, p.Sample().Len())
+
+	podNames := 0
+	for i := 0; i < p.Sample().Len(); i++ {
+		attrs := map[string]string{}
+		indices := p.Sample().At(i).AttributeIndices()
+		for j := 0; j < indices.Len(); j++ {
+			attr := p.AttributeTable().At(int(indices.At(j)))
+			attrs[attr.Key()] = attr.Value().AsString()
+		}
+		if attrs["container.id"] == "" {
+			assert.NotContains(t, attrs, "k8s.pod.name")
+			continue
+		}
+		assert.Equal(t, "web-0", attrs["k8s.pod.name"])
+		assert.Equal(t, "shop", attrs["k8s.namespace.name"])
+		podNames++
+	}
+	assert.Equal(t, 1, podNames)
+}
+
+func TestGenerateProcessAttributes(t *testing.T) {
+	d, err := New(100, 100, 100, nil, nil, nil)
+	require.NoError(t, err)
+
+	events := map[libpf.Origin]samples.KeyToEventMapping{
+		support.TraceOriginSampling: {
+			{
+				Pid:         42,
+				CommandLine: "python3 worker.py",
+				ParentPid:   7,
+				StartTime:   1700000000123000000,
+			}: {
+				Timestamps: []uint64{1},
+				Ancestors:  []string{"bash", "sshd", "systemd"},
+			},
+		},
+	}
+	res := d.Generate(events)
+	p := res.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles().At(0)
+	require.Equal(t, 1, p.Sample().Len())
+
+	attrs := map[string]any{}
+	indices := p.Sample().At(0).AttributeIndices()
+	for i := 0; i < indices.Len(); i++ {
+		attr := p.AttributeTable().At(int(indices.At(i)))
+		attrs[attr.Key()] = attr.Value().AsRaw()
+	}
+	assert.Equal(t, "python3 worker.py", attrs["process.command_line"])
+	assert.Equal(t, int64(7), attrs["process.parent_pid"])
+	assert.Equal(t, "2023-11-14T22:13:20.123Z", attrs["process.start_time"])
+	assert.Equal(t, []any{"bash", "sshd", "systemd"}, attrs["process.ancestors"])
+}
+
+func TestGenerateEventOrigins(t *testing.T) {
+	writeOrigin := libpf.Origin(support.TraceOriginKernelEvent)
+	retransmitOrigin := libpf.Origin(support.TraceOriginKernelEvent + 1)
+	d, err := New(100, 100, 100, nil, nil, []samples.EventOrigin{
+		{
+			Origin:     retransmitOrigin,
+			Name:       "tcp_retransmit_skb",
+			SampleType: "tcp_retransmit_skb",
+			SampleUnit: "count",
+		},
+		{
+			Origin:     writeOrigin,
+			Name:       "syscalls_sys_enter_write",
+			SampleType: "syscalls:sys_enter_write",
+			SampleUnit: "count",
+		},
+	})
+	require.NoError(t, err)
+
+	events := map[libpf.Origin]samples.KeyToEventMapping{
+		support.TraceOriginSampling: {
+			{Pid: 1}: {Timestamps: []uint64{1}},
+		},
+		writeOrigin: {
+			{Pid: 1}: {Timestamps: []uint64{1, 2, 3}, OffTimes: []int64{0, 0, 0}},
+		},
+		retransmitOrigin: {
+			{Pid: 2}: {Timestamps: []uint64{4}, OffTimes: []int64{0}},
+		},
+		// Traces of unknown origins are not reported.
+		retransmitOrigin + 1: {
+			{Pid: 3}: {Timestamps: []uint64{5}},
+		},
+	}
+	res := d.Generate(events)
+	profiles := res.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles()
+	require.Equal(t, 3, profiles.Len())
+
+	var sampleTypes []string
+	for i := 0; i < profiles.Len(); i++ {
+		p := profiles.At(i)
+		st := p.SampleType().At(0)
+		sampleTypes = append(sampleTypes,
+			p.StringTable().At(int(st.TypeStrindex()))+"/"+
+				p.StringTable().At(int(st.UnitStrindex())))
+		if i > 0 {
+			assert.Equal(t, []int64{1}, p.Sample().At(0).Value().AsRaw())
+		}
+	}
+	assert.Equal(t, []string{
+		"samples/count",
+		"syscalls:sys_enter_write/count",
+		"tcp_retransmit_skb/count",
+	}, sampleTypes)
+}
+
+func TestGenerateEventOriginPeriod(t *testing.T) {
+	d, err := New(100, 100, 100, nil, nil, []samples.EventOrigin{{
+		Origin:     support.TraceOriginPageFaults,
+		Name:       "page-faults",
+		SampleType: "page-faults",
+		SampleUnit: "count",
+		Period:     1000,
+	}})
+	require.NoError(t, err)
+
+	res := d.Generate(map[libpf.Origin]samples.KeyToEventMapping{
+		support.TraceOriginPageFaults: {
+			{Pid: 1}: {Timestamps: []uint64{1, 2}, OffTimes: []int64{0, 0}},
+		},
+	})
+	p := res.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles().At(0)
+	assert.Equal(t, "page-faults", p.StringTable().At(int(p.PeriodType().TypeStrindex())))
+	assert.Equal(t, "count", p.StringTable().At(int(p.PeriodType().UnitStrindex())))
+	assert.Equal(t, int64(1000), p.Period())
+	assert.Equal(t, []int64{1000}, p.Sample().At(0).Value().AsRaw())
+}
+
+func TestGenerateWakeupAttributes(t *testing.T) {
+	d, err := New(100, 100, 100, nil, nil, nil)
+	require.NoError(t, err)
+
+	wakeup := libpf.WakeupLink{WakerPID: 10, WakerTID: 11, WakeePID: 20, WakeeTID: 21}
+	other := libpf.WakeupLink{WakerPID: 10, WakerTID: 11, WakeePID: 30, WakeeTID: 31}
+	res := d.Generate(map[libpf.Origin]samples.KeyToEventMapping{
+		support.TraceOriginWakeup: {
+			{Pid: 10}: {
+				Timestamps: []uint64{3, 1, 2},
+				OffTimes:   []int64{0, 0, 0},
+				Wakeups:    []libpf.WakeupLink{wakeup, other, wakeup},
+			},
+		},
+	})
+	p := res.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles().At(0)
+	st := p.SampleType().At(0)
+	assert.Equal(t, "wakeups", p.StringTable().At(int(st.TypeStrindex())))
+
+	// The events are split into one sample per wakeup link.
+	require.Equal(t, 2, p.Sample().Len())
+	for i, expected := range []struct {
+		timestamps []uint64
+		link       libpf.WakeupLink
+	}{
+		{[]uint64{2, 3}, wakeup},
+		{[]uint64{1}, other},
+	} {
+		sample := p.Sample().At(i)
+		assert.Equal(t, expected.timestamps, sample.TimestampsUnixNano().AsRaw())
+
+		attrs := map[string]any{}
+		indices := sample.AttributeIndices()
+		for j := 0; j < indices.Len(); j++ {
+			attr := p.AttributeTable().At(int(indices.At(j)))
+			attrs[attr.Key()] = attr.Value().AsRaw()
+		}
+		assert.Equal(t, int64(expected.link.WakerPID), attrs["wakeup.waker.pid"])
+		assert.Equal(t, int64(expected.link.WakerTID), attrs["wakeup.waker.tid"])
+		assert.Equal(t, int64(expected.link.WakeePID), attrs["wakeup.wakee.pid"])
+		assert.Equal(t, int64(expected.link.WakeeTID), attrs["wakeup.wakee.tid"])
+		assert.Equal(t, int64(10), attrs["process.pid"])
+	}
+}
+
+func TestGenerateOffCPUReasonAttributes(t *testing.T) {
+	d, err := New(100, 100, 100, nil, nil, nil)
+	require.NoError(t, err)
+
+	res := d.Generate(map[libpf.Origin]samples.KeyToEventMapping{
+		support.TraceOriginOffCPU: {
+			{Pid: 10, TaskState: "uninterruptible", BlockingReason: "block_io"}: {
+				Timestamps: []uint64{1},
+				OffTimes:   []int64{10},
+			},
+		},
+	})
+	p := res.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles().At(0)
+
+	attrs := map[string]any{}
+	indices := p.Sample().At(0).AttributeIndices()
+	for i := 0; i < indices.Len(); i++ {
+		attr := p.AttributeTable().At(int(indices.At(i)))
+		attrs[attr.Key()] = attr.Value().AsRaw()
+	}
+	assert.Equal(t, "uninterruptible", attrs["offcpu.task_state"])
+	assert.Equal(t, "block_io", attrs["offcpu.blocking_reason"])
+}
+
+func TestGenerateCustomLabels(t *testing.T) {
+	d, err := New(100, 100, 100, nil, nil, nil)
+	require.NoError(t, err)
+
+	res := d.Generate(map[libpf.Origin]samples.KeyToEventMapping{
+		support.TraceOriginSampling: {
+			{Pid: 10}: {
+				Timestamps:   []uint64{1},
+				CustomLabels: map[string]string{"python.asyncio.task.name": "Task-1"},
+			},
+		},
+	})
+	p := res.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles().At(0)
+
+	attrs := map[string]any{}
+	indices := p.Sample().At(0).AttributeIndices()
+	for i := 0; i < indices.Len(); i++ {
+		attr := p.AttributeTable().At(int(indices.At(i)))
+		attrs[attr.Key()] = attr.Value().AsRaw()
+	}
+	assert.Equal(t, "Task-1", attrs["python.asyncio.task.name"])
+}
+
+func TestGenerateSamplingFrequencies(t *testing.T) {
+	d, err := New(20, 100, 100, nil, nil, nil)
+	require.NoError(t, err)
+
+	res := d.Generate(map[libpf.Origin]samples.KeyToEventMapping{
+		support.TraceOriginSampling: {
+			{Pid: 1}:                        {Timestamps: []uint64{1}},
+			{Pid: 2, SamplesPerSecond: 20}:  {Timestamps: []uint64{2}},
+			{Pid: 3, SamplesPerSecond: 100}: {Timestamps: []uint64{3}},
+		},
+	})
+	profiles := res.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles()
+	require.Equal(t, 2, profiles.Len())
+
+	// Samples without a sampling frequency use the configured one.
+	assert.Equal(t, int64(1e9/20), profiles.At(0).Period())
+	assert.Equal(t, 2, profiles.At(0).Sample().Len())
+	assert.Equal(t, int64(1e9/100), profiles.At(1).Period())
+	assert.Equal(t, 1, profiles.At(1).Sample().Len())
+}
@@ -4,6 +4,9 @@
 package pdata // import "go.opentelemetry.io/ebpf-profiler/reporter/internal/pdata"
 
 import (
+	"cmp"
+	"slices"
+
 	lru "github.com/elastic/go-freelru"
 
 	"go.opentelemetry.io/ebpf-profiler/libpf"
@@ -29,10 +32,19 @@ type Pdata struct {
 	// ExtraSampleAttrProd is an optional hook point for adding custom
 	// attributes to samples.
 	ExtraSampleAttrProd samples.SampleAttrProducer
+
+	// ContainerMetadata optionally resolves attributes describing the
+	// container of samples.
+	ContainerMetadata samples.ContainerMetadataProvider
+
+	// eventOrigins holds the runtime configured event origins, sorted by origin.
+	eventOrigins []samples.EventOrigin
 }
 
 func New(samplesPerSecond int, executablesCacheElements, framesCacheElements uint32,
-	extra samples.SampleAttrProducer) (*Pdata, error) {
+	extra samples.SampleAttrProducer,
+	containerMetadata samples.ContainerMetadataProvider,
+	eventOrigins []samples.EventOrigin) (*Pdata, error) {
 	executables, err :=
 		lru.NewSynced[libpf.FileID, samples.ExecInfo](executablesCacheElements, libpf.FileID.Hash32)
 	if err != nil {
@@ -53,6 +65,9 @@ func New(samplesPerSecond int, executablesCacheElements, framesCacheElements uin
 		Executables:         executables,
 		Frames:              frames,
 		ExtraSampleAttrProd: extra,
+		ContainerMetadata:   containerMetadata,
+		eventOrigins: slices.SortedFunc(slices.Values(eventOrigins),
+			func(a, b samples.EventOrigin) int { return cmp.Compare(a.Origin, b.Origin) }),
 	}, nil
 }
 
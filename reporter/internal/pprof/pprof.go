@@ -0,0 +1,315 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package pprof converts OTLP profiles into the pprof profile.proto format.
+//
+// The encoding is done directly on the protobuf wire format to avoid pulling
+// in the pprof Go module and its dependencies. Field numbers follow
+// https://github.com/google/pprof/blob/main/proto/profile.proto.
+package pprof // import "go.opentelemetry.io/ebpf-profiler/reporter/internal/pprof"
+
+import (
+	"compress/gzip"
+	"io"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pprofile"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the pprof Profile message.
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileMapping           = 3
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+)
+
+// Field numbers of the pprof ValueType message.
+const (
+	valueTypeType = 1
+	valueTypeUnit = 2
+)
+
+// Field numbers of the pprof Sample message.
+const (
+	sampleLocationID = 1
+	sampleValue      = 2
+	sampleLabel      = 3
+)
+
+// Field numbers of the pprof Label message.
+const (
+	labelKey = 1
+	labelStr = 2
+	labelNum = 3
+)
+
+// Field numbers of the pprof Mapping message.
+const (
+	mappingID          = 1
+	mappingMemoryStart = 2
+	mappingMemoryLimit = 3
+	mappingFileOffset  = 4
+	mappingFilename    = 5
+	mappingBuildID     = 6
+)
+
+// Field numbers of the pprof Location message.
+const (
+	locationID        = 1
+	locationMappingID = 2
+	locationAddress   = 3
+	locationLine      = 4
+)
+
+// Field numbers of the pprof Line message.
+const (
+	lineFunctionID = 1
+	lineLine       = 2
+)
+
+// Field numbers of the pprof Function message.
+const (
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// stringTable extends the string table of an OTLP profile with the additional
+// strings that are required by the pprof format (e.g. label keys).
+type stringTable struct {
+	strings []string
+	indices map[string]int64
+}
+
+func newStringTable(src pcommon.StringSlice) *stringTable {
+	st := &stringTable{
+		strings: make([]string, 0, src.Len()),
+		indices: make(map[string]int64, src.Len()),
+	}
+	for i := 0; i < src.Len(); i++ {
+		s := src.At(i)
+		st.strings = append(st.strings, s)
+		if _, exists := st.indices[s]; !exists {
+			st.indices[s] = int64(i)
+		}
+	}
+	if len(st.strings) == 0 {
+		// By specification, the first element has to be the empty string.
+		st.index("")
+	}
+	return st
+}
+
+// index returns the string table index of s, adding s if required.
+func (st *stringTable) index(s string) int64 {
+	if idx, exists := st.indices[s]; exists {
+		return idx
+	}
+	idx := int64(len(st.strings))
+	st.strings = append(st.strings, s)
+	st.indices[s] = idx
+	return idx
+}
+
+// Marshal encodes profile in the (uncompressed) pprof protobuf format.
+func Marshal(profile pprofile.Profile) []byte {
+	st := newStringTable(profile.StringTable())
+	attrs := profile.AttributeTable()
+
+	var b []byte
+	for i := 0; i < profile.SampleType().Len(); i++ {
+		vt := profile.SampleType().At(i)
+		b = appendValueType(b, profileSampleType,
+			int64(vt.TypeStrindex()), int64(vt.UnitStrindex()))
+	}
+
+	locIndices := profile.LocationIndices()
+	for i := 0; i < profile.Sample().Len(); i++ {
+		sample := profile.Sample().At(i)
+
+		var msg []byte
+		locIDs := make([]byte, 0, sample.LocationsLength())
+		for j := sample.LocationsStartIndex(); j < sample.LocationsStartIndex()+
+			sample.LocationsLength(); j++ {
+			locIDs = protowire.AppendVarint(locIDs, uint64(locIndices.At(int(j)))+1)
+		}
+		msg = protowire.AppendTag(msg, sampleLocationID, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, locIDs)
+
+		msg = protowire.AppendTag(msg, sampleValue, protowire.BytesType)
+		msg = protowire.AppendBytes(msg,
+			protowire.AppendVarint(nil, uint64(sampleValueOf(sample))))
+
+		for j := 0; j < sample.AttributeIndices().Len(); j++ {
+			attr := attrs.At(int(sample.AttributeIndices().At(j)))
+			var label []byte
+			label = appendVarintField(label, labelKey, st.index(attr.Key()))
+			switch attr.Value().Type() {
+			case pcommon.ValueTypeInt:
+				label = appendVarintField(label, labelNum, attr.Value().Int())
+			default:
+				label = appendVarintField(label, labelStr, st.index(attr.Value().AsString()))
+			}
+			msg = protowire.AppendTag(msg, sampleLabel, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, label)
+		}
+
+		b = protowire.AppendTag(b, profileSample, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+
+	for i := 0; i < profile.MappingTable().Len(); i++ {
+		mapping := profile.MappingTable().At(i)
+
+		var msg []byte
+		msg = appendVarintField(msg, mappingID, int64(i)+1)
+		msg = appendVarintField(msg, mappingMemoryStart, int64(mapping.MemoryStart()))
+		msg = appendVarintField(msg, mappingMemoryLimit, int64(mapping.MemoryLimit()))
+		msg = appendVarintField(msg, mappingFileOffset, int64(mapping.FileOffset()))
+		msg = appendVarintField(msg, mappingFilename, int64(mapping.FilenameStrindex()))
+		if buildID := mappingBuildIDOf(attrs, mapping); buildID != "" {
+			msg = appendVarintField(msg, mappingBuildID, st.index(buildID))
+		}
+
+		b = protowire.AppendTag(b, profileMapping, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+
+	for i := 0; i < profile.LocationTable().Len(); i++ {
+		loc := profile.LocationTable().At(i)
+
+		var msg []byte
+		msg = appendVarintField(msg, locationID, int64(i)+1)
+		if loc.HasMappingIndex() {
+			msg = appendVarintField(msg, locationMappingID, int64(loc.MappingIndex())+1)
+		}
+		msg = appendVarintField(msg, locationAddress, int64(loc.Address()))
+		for j := 0; j < loc.Line().Len(); j++ {
+			line := loc.Line().At(j)
+
+			var lineMsg []byte
+			lineMsg = appendVarintField(lineMsg, lineFunctionID, int64(line.FunctionIndex())+1)
+			lineMsg = appendVarintField(lineMsg, lineLine, line.Line())
+			msg = protowire.AppendTag(msg, locationLine, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, lineMsg)
+		}
+
+		b = protowire.AppendTag(b, profileLocation, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+
+	for i := 0; i < profile.FunctionTable().Len(); i++ {
+		fn := profile.FunctionTable().At(i)
+
+		var msg []byte
+		msg = appendVarintField(msg, functionID, int64(i)+1)
+		msg = appendVarintField(msg, functionName, int64(fn.NameStrindex()))
+		systemName := fn.SystemNameStrindex()
+		if systemName == 0 {
+			systemName = fn.NameStrindex()
+		}
+		msg = appendVarintField(msg, functionSystemName, int64(systemName))
+		msg = appendVarintField(msg, functionFilename, int64(fn.FilenameStrindex()))
+		msg = appendVarintField(msg, functionStartLine, fn.StartLine())
+
+		b = protowire.AppendTag(b, profileFunction, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+
+	b = appendVarintField(b, profileTimeNanos, int64(profile.StartTime()))
+	b = appendVarintField(b, profileDurationNanos, int64(profile.Duration()))
+	pt := profile.PeriodType()
+	if pt.TypeStrindex() != 0 || pt.UnitStrindex() != 0 {
+		b = appendValueType(b, profilePeriodType,
+			int64(pt.TypeStrindex()), int64(pt.UnitStrindex()))
+	}
+	b = appendVarintField(b, profilePeriod, profile.Period())
+	b = appendVarintField(b, profileDefaultSampleType,
+		int64(profile.DefaultSampleTypeStrindex()))
+
+	// The string table is written last, as the encoding of the other
+	// messages might have added new entries to it.
+	for _, s := range st.strings {
+		b = protowire.AppendTag(b, profileStringTable, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+
+	return b
+}
+
+// Write encodes profile in the gzip compressed pprof format to w.
+func Write(w io.Writer, profile pprofile.Profile) error {
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(Marshal(profile)); err != nil {
+		_ = zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// sampleValueOf returns the aggregated value of an OTLP sample.
+//
+// OTLP samples may carry one value per timestamp (e.g. off-CPU samples) or
+// a single value that applies to each of the timestamps (e.g. on-CPU samples),
+// while pprof expects exactly one value per sample type.
+func sampleValueOf(sample pprofile.Sample) int64 {
+	values := sample.Value()
+	numTimestamps := sample.TimestampsUnixNano().Len()
+
+	if values.Len() == 1 && numTimestamps > 1 {
+		return values.At(0) * int64(numTimestamps)
+	}
+
+	var sum int64
+	for i := 0; i < values.Len(); i++ {
+		sum += values.At(i)
+	}
+	return sum
+}
+
+// mappingBuildIDOf returns the GNU build ID of mapping, falling back to the
+// file hash if the GNU build ID is not known.
+func mappingBuildIDOf(attrs pprofile.AttributeTableSlice, mapping pprofile.Mapping) string {
+	var fileHash string
+	for i := 0; i < mapping.AttributeIndices().Len(); i++ {
+		attr := attrs.At(int(mapping.AttributeIndices().At(i)))
+		switch attr.Key() {
+		case string(semconv.ProcessExecutableBuildIDGnuKey):
+			return attr.Value().AsString()
+		case string(semconv.ProcessExecutableBuildIDHtlhashKey):
+			fileHash = attr.Value().AsString()
+		}
+	}
+	return fileHash
+}
+
+// appendVarintField appends a varint encoded field, omitting zero values
+// as it is done by the protobuf encoder for proto3 messages.
+func appendVarintField(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendValueType appends a ValueType message as field num.
+func appendValueType(b []byte, num protowire.Number, typ, unit int64) []byte {
+	var msg []byte
+	msg = appendVarintField(msg, valueTypeType, typ)
+	msg = appendVarintField(msg, valueTypeUnit, unit)
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
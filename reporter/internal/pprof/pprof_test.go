@@ -0,0 +1,148 @@
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pprofile"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fields decodes the top-level fields of a protobuf message.
+func fields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	res := make(map[protowire.Number][][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(b)
+			require.GreaterOrEqual(t, n, 0)
+			res[num] = append(res[num], b[:n])
+		case protowire.BytesType:
+			v, m := protowire.ConsumeBytes(b)
+			require.GreaterOrEqual(t, m, 0)
+			res[num] = append(res[num], v)
+			n = m
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		b = b[n:]
+	}
+	return res
+}
+
+func varint(t *testing.T, b []byte) uint64 {
+	t.Helper()
+	v, n := protowire.ConsumeVarint(b)
+	require.GreaterOrEqual(t, n, 0)
+	return v
+}
+
+func TestWrite(t *testing.T) {
+	profile := pprofile.NewProfile()
+	profile.StringTable().Append("", "samples", "count", "main", "/bin/app")
+
+	st := profile.SampleType().AppendEmpty()
+	st.SetTypeStrindex(1)
+	st.SetUnitStrindex(2)
+
+	fn := profile.FunctionTable().AppendEmpty()
+	fn.SetNameStrindex(3)
+
+	mapping := profile.MappingTable().AppendEmpty()
+	mapping.SetFilenameStrindex(4)
+	mapping.SetMemoryStart(0x1000)
+	mapping.SetMemoryLimit(0x2000)
+
+	loc := profile.LocationTable().AppendEmpty()
+	loc.SetMappingIndex(0)
+	loc.SetAddress(0x1234)
+	loc.Line().AppendEmpty().SetFunctionIndex(0)
+	profile.LocationIndices().Append(0)
+
+	attr := profile.AttributeTable().AppendEmpty()
+	attr.SetKey("thread.name")
+	attr.Value().SetStr("worker")
+
+	sample := profile.Sample().AppendEmpty()
+	sample.SetLocationsStartIndex(0)
+	sample.SetLocationsLength(1)
+	sample.Value().Append(1)
+	sample.TimestampsUnixNano().Append(1, 2, 3)
+	sample.AttributeIndices().Append(0)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, profile))
+
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	top := fields(t, raw)
+	require.Len(t, top[profileSample], 1)
+	require.Len(t, top[profileMapping], 1)
+	require.Len(t, top[profileLocation], 1)
+	require.Len(t, top[profileFunction], 1)
+
+	var strs []string
+	for _, s := range top[profileStringTable] {
+		strs = append(strs, string(s))
+	}
+	assert.Equal(t, []string{"", "samples", "count", "main", "/bin/app",
+		"thread.name", "worker"}, strs)
+
+	s := fields(t, top[profileSample][0])
+	// One value applied to each of the three timestamps.
+	assert.Equal(t, uint64(3), varint(t, s[sampleValue][0]))
+	assert.Equal(t, uint64(1), varint(t, s[sampleLocationID][0]))
+
+	label := fields(t, s[sampleLabel][0])
+	assert.Equal(t, uint64(5), varint(t, label[labelKey][0]))
+	assert.Equal(t, uint64(6), varint(t, label[labelStr][0]))
+
+	l := fields(t, top[profileLocation][0])
+	assert.Equal(t, uint64(1), varint(t, l[locationMappingID][0]))
+	assert.Equal(t, uint64(0x1234), varint(t, l[locationAddress][0]))
+}
+
+func TestSampleValueOf(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		values     []int64
+		timestamps []uint64
+		want       int64
+	}{
+		{
+			name:       "one value per timestamp",
+			values:     []int64{10, 20, 30},
+			timestamps: []uint64{1, 2, 3},
+			want:       60,
+		},
+		{
+			name:       "single value for all timestamps",
+			values:     []int64{1},
+			timestamps: []uint64{1, 2},
+			want:       2,
+		},
+		{
+			name:   "no timestamps",
+			values: []int64{7},
+			want:   7,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sample := pprofile.NewSample()
+			sample.Value().FromRaw(tt.values)
+			sample.TimestampsUnixNano().FromRaw(tt.timestamps)
+			assert.Equal(t, tt.want, sampleValueOf(sample))
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package spool implements a bounded on-disk FIFO queue for serialized
+// payloads that could not be delivered to the backend.
+package spool // import "go.opentelemetry.io/ebpf-profiler/reporter/internal/spool"
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileSuffix is the file name suffix of spooled entries.
+const fileSuffix = ".spool"
+
+// entry describes a spooled payload on disk.
+type entry struct {
+	seq     uint64
+	size    int64
+	modTime time.Time
+}
+
+// Spool stores payloads as files in a directory and hands them back in the
+// order they were added. The oldest entries are dropped if the configured
+// size or age limits are exceeded.
+type Spool struct {
+	// dir is the directory the entries are stored in.
+	dir string
+
+	// maxBytes is the maximum total size of all entries. Zero disables the limit.
+	maxBytes int64
+
+	// maxAge is the maximum age of an entry. Zero disables the limit.
+	maxAge time.Duration
+
+	// replayMu serializes calls to Replay, so that an entry is not sent twice.
+	replayMu sync.Mutex
+
+	// mu serializes access to the fields below and the spool directory.
+	mu sync.Mutex
+
+	// entries holds the spooled entries, ordered from oldest to newest.
+	entries []entry
+
+	// totalSize is the sum of the sizes of all entries.
+	totalSize int64
+
+	// nextSeq is the sequence number of the next entry.
+	nextSeq uint64
+
+	// dropped counts the entries that were removed because of the limits.
+	dropped uint64
+}
+
+// New creates a Spool in dir. Entries left in dir by a previous instance are
+// picked up again.
+func New(dir string, maxBytes int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %v", err)
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+	}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if !dirEntry.Type().IsRegular() || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, fileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		s.entries = append(s.entries, entry{
+			seq:     seq,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		s.totalSize += info.Size()
+		s.nextSeq = max(s.nextSeq, seq+1)
+	}
+	slices.SortFunc(s.entries, func(a, b entry) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+
+	s.mu.Lock()
+	s.enforceLimits(time.Now())
+	s.mu.Unlock()
+
+	return s, nil
+}
+
+// path returns the file path of the entry with sequence number seq.
+func (s *Spool) path(seq uint64) string {
+	// Zero padding keeps the directory listing in spool order.
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, fileSuffix))
+}
+
+// Put appends data to the spool.
+func (s *Spool) Put(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := s.nextSeq
+	fileName := s.path(seq)
+
+	// Write to a temporary file first, so that a crash never leaves a
+	// truncated entry behind.
+	tmpName := fileName + ".tmp"
+	if err := os.WriteFile(tmpName, data, 0o600); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, fileName); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	s.nextSeq++
+	s.entries = append(s.entries, entry{
+		seq:     seq,
+		size:    int64(len(data)),
+		modTime: time.Now(),
+	})
+	s.totalSize += int64(len(data))
+	s.enforceLimits(time.Now())
+	return nil
+}
+
+// Replay calls send for the spooled entries from oldest to newest. Entries
+// are removed once send returns successfully, so send returns nil to drop an
+// entry that can never be sent. Replay stops at the first error returned by
+// send and returns it. The spool is not locked while send
+// runs, so that Put does not wait for the backend.
+func (s *Spool) Replay(send func(data []byte) error) error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		s.enforceLimits(time.Now())
+		if len(s.entries) == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		e := s.entries[0]
+		data, err := os.ReadFile(s.path(e.seq))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// The entry vanished from disk, there is nothing left to replay.
+				s.removeOldest()
+				err = nil
+			}
+			s.mu.Unlock()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		s.mu.Unlock()
+
+		if err = send(data); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		err = s.remove(e.seq)
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Len returns the number of spooled entries.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Size returns the total size of the spooled entries in bytes.
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.totalSize
+}
+
+// Dropped returns the number of entries dropped because of the spool limits
+// since the last call to Dropped.
+func (s *Spool) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped
+}
+
+// remove deletes the entry with sequence number seq, unless it was dropped
+// in the meantime. The caller is responsible for holding s.mu.
+func (s *Spool) remove(seq uint64) error {
+	if len(s.entries) == 0 || s.entries[0].seq != seq {
+		// Replay only removes the oldest entry, so it was dropped already.
+		return nil
+	}
+	if err := os.Remove(s.path(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.removeOldest()
+	return nil
+}
+
+// removeOldest removes the oldest entry from the bookkeeping.
+func (s *Spool) removeOldest() {
+	s.totalSize -= s.entries[0].size
+	s.entries = s.entries[1:]
+}
+
+// enforceLimits drops the oldest entries as long as the spool exceeds its
+// limits. The caller is responsible for holding s.mu.
+func (s *Spool) enforceLimits(now time.Time) {
+	for len(s.entries) > 0 {
+		oldest := s.entries[0]
+		expired := s.maxAge > 0 && now.Sub(oldest.modTime) > s.maxAge
+		oversized := s.maxBytes > 0 && s.totalSize > s.maxBytes
+		if !expired && !oversized {
+			return
+		}
+		_ = os.Remove(s.path(oldest.seq))
+		s.removeOldest()
+		s.dropped++
+	}
+}
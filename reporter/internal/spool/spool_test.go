@@ -0,0 +1,110 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func replayAll(t *testing.T, s *Spool) []string {
+	t.Helper()
+	var got []string
+	require.NoError(t, s.Replay(func(data []byte) error {
+		got = append(got, string(data))
+		return nil
+	}))
+	return got
+}
+
+func TestSpoolOrder(t *testing.T) {
+	s, err := New(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Put([]byte(v)))
+	}
+	assert.Equal(t, 3, s.Len())
+	assert.Equal(t, int64(3), s.Size())
+
+	// A failing send stops the replay and keeps the remaining entries.
+	errSend := errors.New("unreachable")
+	var got []string
+	err = s.Replay(func(data []byte) error {
+		if string(data) == "b" {
+			return errSend
+		}
+		got = append(got, string(data))
+		return nil
+	})
+	require.ErrorIs(t, err, errSend)
+	assert.Equal(t, []string{"a"}, got)
+	assert.Equal(t, 2, s.Len())
+
+	assert.Equal(t, []string{"b", "c"}, replayAll(t, s))
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, int64(0), s.Size())
+}
+
+func TestSpoolReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, 0, 0)
+	require.NoError(t, err)
+	for _, v := range []string{"a", "b"} {
+		require.NoError(t, s.Put([]byte(v)))
+	}
+
+	s, err = New(dir, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Put([]byte("c")))
+	assert.Equal(t, []string{"a", "b", "c"}, replayAll(t, s))
+}
+
+func TestSpoolMaxBytes(t *testing.T) {
+	s, err := New(t.TempDir(), 4, 0)
+	require.NoError(t, err)
+
+	for _, v := range []string{"aa", "bb", "cc"} {
+		require.NoError(t, s.Put([]byte(v)))
+	}
+	assert.Equal(t, uint64(1), s.Dropped())
+	assert.Equal(t, uint64(0), s.Dropped())
+	assert.Equal(t, []string{"bb", "cc"}, replayAll(t, s))
+}
+
+func TestSpoolMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, 0, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, s.Put([]byte("old")))
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(s.path(0), old, old))
+
+	s, err = New(dir, 0, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, s.Put([]byte("new")))
+	assert.Equal(t, uint64(1), s.Dropped())
+	assert.Equal(t, []string{"new"}, replayAll(t, s))
+}
+
+func TestSpoolPutDuringReplay(t *testing.T) {
+	s, err := New(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Put([]byte("a")))
+
+	// Put must not wait for the send of a replayed entry.
+	var got []string
+	require.NoError(t, s.Replay(func(data []byte) error {
+		if string(data) == "a" {
+			require.NoError(t, s.Put([]byte("b")))
+		}
+		got = append(got, string(data))
+		return nil
+	}))
+	assert.Equal(t, []string{"a", "b"}, got)
+	assert.Equal(t, 0, s.Len())
+}
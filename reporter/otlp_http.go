@@ -0,0 +1,311 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package reporter // import "go.opentelemetry.io/ebpf-profiler/reporter"
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/collector/pdata/pprofile/pprofileotlp"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+)
+
+const (
+	// ProtocolGRPC selects OTLP over gRPC as transport to the collection agent.
+	ProtocolGRPC = "grpc"
+	// ProtocolHTTPProtobuf selects OTLP over HTTP with protobuf encoded bodies.
+	ProtocolHTTPProtobuf = "http/protobuf"
+	// ProtocolHTTPJSON selects OTLP over HTTP with JSON encoded bodies.
+	ProtocolHTTPJSON = "http/json"
+
+	// CompressionGzip enables gzip compression of OTLP/HTTP request bodies.
+	CompressionGzip = "gzip"
+
+	// defaultHTTPProfilesPath is the default URL path of the OTLP/HTTP profiles endpoint.
+	defaultHTTPProfilesPath = "/v1development/profiles"
+
+	// maxHTTPResponseSize limits how much of a response body is read.
+	maxHTTPResponseSize = 1 << 20
+)
+
+// profilesExporter sends OTLP profiles to the collection agent.
+type profilesExporter interface {
+	// Export sends req to the collection agent.
+	Export(ctx context.Context, req pprofileotlp.ExportRequest) error
+}
+
+// grpcExporter implements profilesExporter for OTLP/gRPC.
+type grpcExporter struct {
+	client pprofileotlp.GRPCClient
+
+	// operationTimeout is the time limit for a single request.
+	operationTimeout time.Duration
+}
+
+func (e *grpcExporter) Export(ctx context.Context, req pprofileotlp.ExportRequest) error {
+	reqCtx, ctxCancel := context.WithTimeout(ctx, e.operationTimeout)
+	defer ctxCancel()
+	_, err := e.client.Export(reqCtx, req)
+	return err
+}
+
+// httpExporter implements profilesExporter for OTLP/HTTP.
+type httpExporter struct {
+	client *http.Client
+
+	// url is the full URL of the profiles endpoint.
+	url string
+
+	// json selects JSON instead of protobuf encoded request bodies.
+	json bool
+
+	// gzip enables the compression of request bodies.
+	gzip bool
+
+	// headers are added to every request.
+	headers map[string]string
+
+	// maxRetries is the number of retries for failed requests.
+	maxRetries uint32
+
+	// backoff is the time to wait between retries.
+	backoff time.Duration
+
+	// operationTimeout is the time limit for a single request.
+	operationTimeout time.Duration
+}
+
+// errHTTPStatus is returned for requests that were answered with an unexpected
+// HTTP status code.
+type errHTTPStatus struct {
+	code int
+	msg  string
+}
+
+func (e *errHTTPStatus) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %d: %s", e.code, e.msg)
+}
+
+// retryable returns true if the request may succeed if it is sent again, as
+// defined by the OTLP/HTTP specification.
+func (e *errHTTPStatus) retryable() bool {
+	switch e.code {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// usesHTTP returns true if cfg selects OTLP/HTTP as transport.
+func usesHTTP(cfg *Config) bool {
+	switch cfg.Protocol {
+	case ProtocolHTTPProtobuf, ProtocolHTTPJSON:
+		return true
+	case "":
+		return strings.HasPrefix(cfg.CollAgentAddr, "http://") ||
+			strings.HasPrefix(cfg.CollAgentAddr, "https://")
+	default:
+		return false
+	}
+}
+
+// httpEndpointURL returns the URL of the OTLP/HTTP profiles endpoint for cfg.
+func httpEndpointURL(cfg *Config) (string, error) {
+	addr := cfg.CollAgentAddr
+	if !strings.Contains(addr, "://") {
+		if cfg.DisableTLS {
+			addr = "http://" + addr
+		} else {
+			addr = "https://" + addr
+		}
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid collection agent address %s: %v",
+			cfg.CollAgentAddr, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported URL scheme %s for OTLP/HTTP", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultHTTPProfilesPath
+	}
+	return u.String(), nil
+}
+
+// newHTTPExporter returns a profilesExporter for OTLP/HTTP.
+func newHTTPExporter(cfg *Config) (*httpExporter, error) {
+	endpoint, err := httpEndpointURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.HTTPCompression {
+	case "", CompressionGzip:
+	default:
+		return nil, fmt.Errorf("unsupported OTLP/HTTP compression: %s", cfg.HTTPCompression)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		// Support only TLS1.3+ with valid CA certificates
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: false,
+	}
+
+	return &httpExporter{
+		client:           &http.Client{Transport: transport},
+		url:              endpoint,
+		json:             cfg.Protocol == ProtocolHTTPJSON,
+		gzip:             cfg.HTTPCompression == CompressionGzip,
+		headers:          cfg.HTTPHeaders,
+		maxRetries:       cfg.MaxGRPCRetries,
+		backoff:          cfg.GRPCStartupBackoffTime,
+		operationTimeout: cfg.GRPCOperationTimeout,
+	}, nil
+}
+
+// Export sends req to the OTLP/HTTP endpoint, retrying retryable failures.
+func (e *httpExporter) Export(ctx context.Context, req pprofileotlp.ExportRequest) error {
+	body, contentType, err := e.encode(req)
+	if err != nil {
+		return err
+	}
+
+	var retries uint32
+	for {
+		err = e.send(ctx, body, contentType)
+		if err == nil {
+			return nil
+		}
+
+		var statusErr *errHTTPStatus
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return err
+		}
+		if retries >= e.maxRetries {
+			return err
+		}
+		retries++
+
+		log.Warnf("Failed to export OTLP/HTTP profiles (try %d of %d): %v",
+			retries, e.maxRetries, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(libpf.AddJitter(e.backoff, 0.2)):
+		}
+	}
+}
+
+// encode returns the encoded and optionally compressed request body.
+func (e *httpExporter) encode(req pprofileotlp.ExportRequest) (
+	body []byte, contentType string, err error) {
+	if e.json {
+		body, err = req.MarshalJSON()
+		contentType = "application/json"
+	} else {
+		body, err = req.MarshalProto()
+		contentType = "application/x-protobuf"
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode OTLP request: %v", err)
+	}
+
+	if !e.gzip {
+		return body, contentType, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(body); err != nil {
+		return nil, "", err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// send performs a single OTLP/HTTP request.
+func (e *httpExporter) send(ctx context.Context, body []byte, contentType string) error {
+	reqCtx, cancel := context.WithTimeout(ctx, e.operationTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(reqCtx, http.MethodPost, e.url,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		httpReq.Header.Set(k, v)
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	if e.gzip {
+		httpReq.Header.Set("Content-Encoding", CompressionGzip)
+	}
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		e.logPartialSuccess(resp.Header.Get("Content-Type"), respBody)
+		return nil
+	}
+	return &errHTTPStatus{
+		code: resp.StatusCode,
+		msg:  strings.TrimSpace(string(respBody)),
+	}
+}
+
+// logPartialSuccess logs rejected profiles reported in a successful response.
+func (e *httpExporter) logPartialSuccess(contentType string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+
+	resp := pprofileotlp.NewExportResponse()
+	var err error
+	if strings.HasPrefix(contentType, "application/json") {
+		err = resp.UnmarshalJSON(body)
+	} else {
+		err = resp.UnmarshalProto(body)
+	}
+	if err != nil {
+		log.Debugf("Failed to decode OTLP/HTTP response: %v", err)
+		return
+	}
+
+	if ps := resp.PartialSuccess(); ps.RejectedProfiles() != 0 {
+		log.Warnf("Collection agent rejected %d profiles: %s",
+			ps.RejectedProfiles(), ps.ErrorMessage())
+	}
+}
+
+// Close releases idle connections of the exporter.
+func (e *httpExporter) Close() {
+	e.client.CloseIdleConnections()
+}
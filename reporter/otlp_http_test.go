@@ -0,0 +1,241 @@
+package reporter
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pprofile/pprofileotlp"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+func TestHTTPEndpointURL(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		addr       string
+		disableTLS bool
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "host and port",
+			addr: "localhost:4318",
+			want: "https://localhost:4318/v1development/profiles",
+		},
+		{
+			name:       "host and port without TLS",
+			addr:       "localhost:4318",
+			disableTLS: true,
+			want:       "http://localhost:4318/v1development/profiles",
+		},
+		{
+			name: "URL with custom path",
+			addr: "http://gateway/otlp/profiles",
+			want: "http://gateway/otlp/profiles",
+		},
+		{
+			name:    "unsupported scheme",
+			addr:    "ftp://gateway",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := httpEndpointURL(&Config{
+				CollAgentAddr: tt.addr,
+				DisableTLS:    tt.disableTLS,
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUsesHTTP(t *testing.T) {
+	assert.True(t, usesHTTP(&Config{CollAgentAddr: "http://localhost:4318"}))
+	assert.True(t, usesHTTP(&Config{CollAgentAddr: "https://localhost:4318"}))
+	assert.True(t, usesHTTP(&Config{CollAgentAddr: "localhost:4318",
+		Protocol: ProtocolHTTPJSON}))
+	assert.False(t, usesHTTP(&Config{CollAgentAddr: "localhost:4317"}))
+	assert.False(t, usesHTTP(&Config{CollAgentAddr: "http://localhost:4317",
+		Protocol: ProtocolGRPC}))
+}
+
+// decodeRequest decodes an OTLP/HTTP request received by a test server.
+func decodeRequest(t *testing.T, r *http.Request) pprofileotlp.ExportRequest {
+	t.Helper()
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == CompressionGzip {
+		zr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body = zr
+	}
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	req := pprofileotlp.NewExportRequest()
+	if r.Header.Get("Content-Type") == "application/json" {
+		require.NoError(t, req.UnmarshalJSON(data))
+	} else {
+		require.NoError(t, req.UnmarshalProto(data))
+	}
+	return req
+}
+
+func TestOTLPReporterHTTP(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		protocol    string
+		compression string
+	}{
+		{
+			name:     "protobuf",
+			protocol: ProtocolHTTPProtobuf,
+		},
+		{
+			name:        "protobuf with gzip",
+			protocol:    ProtocolHTTPProtobuf,
+			compression: CompressionGzip,
+		},
+		{
+			name:        "json with gzip",
+			protocol:    ProtocolHTTPJSON,
+			compression: CompressionGzip,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan pprofileotlp.ExportRequest, 1)
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, defaultHTTPProfilesPath, r.URL.Path)
+					assert.Equal(t, "secret", r.Header.Get("Authorization"))
+					received <- decodeRequest(t, r)
+				}))
+			defer srv.Close()
+
+			r, err := NewOTLP(&Config{
+				CollAgentAddr:            srv.URL,
+				Protocol:                 tt.protocol,
+				HTTPCompression:          tt.compression,
+				HTTPHeaders:              map[string]string{"Authorization": "secret"},
+				GRPCOperationTimeout:     time.Second,
+				ReportInterval:           time.Hour,
+				ExecutablesCacheElements: 1,
+				FramesCacheElements:      1,
+				CGroupCacheElements:      1,
+				SamplesPerSecond:         20,
+			})
+			require.NoError(t, err)
+			require.NoError(t, r.Start(context.Background()))
+			defer r.Stop()
+
+			require.NoError(t, r.ReportTraceEvent(&libpf.Trace{}, &samples.TraceEventMeta{
+				Timestamp: libpf.UnixTime64(time.Now().UnixNano()),
+				Origin:    support.TraceOriginSampling,
+			}))
+			require.NoError(t, r.reportOTLPProfile(context.Background()))
+
+			req := <-received
+			assert.Equal(t, 1, req.Profiles().SampleCount())
+		})
+	}
+}
+
+func TestOTLPReporterStopFlushes(t *testing.T) {
+	received := make(chan pprofileotlp.ExportRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(_ http.ResponseWriter, r *http.Request) {
+			received <- decodeRequest(t, r)
+		}))
+	defer srv.Close()
+
+	r, err := NewOTLP(&Config{
+		CollAgentAddr:            srv.URL,
+		GRPCOperationTimeout:     time.Second,
+		ReportInterval:           time.Hour,
+		ExecutablesCacheElements: 1,
+		FramesCacheElements:      1,
+		CGroupCacheElements:      1,
+		SamplesPerSecond:         20,
+	})
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background()))
+
+	require.NoError(t, r.ReportTraceEvent(&libpf.Trace{}, &samples.TraceEventMeta{
+		Timestamp: libpf.UnixTime64(time.Now().UnixNano()),
+		Origin:    support.TraceOriginSampling,
+	}))
+	r.Stop()
+
+	req := <-received
+	assert.Equal(t, 1, req.Profiles().SampleCount())
+}
+
+func TestHTTPExporterRetries(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		statusCodes  []int
+		wantErr      bool
+		wantRequests int32
+	}{
+		{
+			name:         "retryable status recovers",
+			statusCodes:  []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantRequests: 2,
+		},
+		{
+			name:         "non-retryable status",
+			statusCodes:  []int{http.StatusBadRequest},
+			wantErr:      true,
+			wantRequests: 1,
+		},
+		{
+			name: "retries exhausted",
+			statusCodes: []int{http.StatusTooManyRequests, http.StatusTooManyRequests,
+				http.StatusTooManyRequests},
+			wantErr:      true,
+			wantRequests: 3,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, _ *http.Request) {
+					n := requests.Add(1)
+					w.WriteHeader(tt.statusCodes[n-1])
+				}))
+			defer srv.Close()
+
+			e, err := newHTTPExporter(&Config{
+				CollAgentAddr:          srv.URL,
+				MaxGRPCRetries:         2,
+				GRPCStartupBackoffTime: time.Millisecond,
+				GRPCOperationTimeout:   time.Second,
+			})
+			require.NoError(t, err)
+			defer e.Close()
+
+			err = e.Export(context.Background(), pprofileotlp.NewExportRequest())
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantRequests, requests.Load())
+		})
+	}
+}
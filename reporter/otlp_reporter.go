@@ -6,11 +6,9 @@ package reporter // import "go.opentelemetry.io/ebpf-profiler/reporter"
 import (
 	"context"
 	"crypto/tls"
-	"maps"
 	"strconv"
 	"time"
 
-	lru "github.com/elastic/go-freelru"
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/collector/pdata/pprofile"
 	"go.opentelemetry.io/collector/pdata/pprofile/pprofileotlp"
@@ -20,10 +18,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 
 	"go.opentelemetry.io/ebpf-profiler/libpf"
-	"go.opentelemetry.io/ebpf-profiler/libpf/xsync"
-	"go.opentelemetry.io/ebpf-profiler/reporter/internal/pdata"
-	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
-	"go.opentelemetry.io/ebpf-profiler/support"
+	"go.opentelemetry.io/ebpf-profiler/reporter/internal/spool"
 )
 
 // Assert that we implement the full Reporter interface.
@@ -45,8 +40,15 @@ type OTLPReporter struct {
 	// ipAddress is the IP address of the host.
 	ipAddress string
 
-	// client for the connection to the receiver.
-	client pprofileotlp.GRPCClient
+	// exporter sends the profiles to the receiver.
+	exporter profilesExporter
+
+	// spool keeps profiles that failed to be exported, if configured.
+	spool *spool.Spool
+
+	// stopReporting cancels the reporting functions and releases the connection
+	// to the receiver. It is set once the reporter started.
+	stopReporting func()
 
 	// To fill in the OTLP/profiles signal with the relevant information,
 	// this structure holds in long-term storage information that might
@@ -58,57 +60,28 @@ type OTLPReporter struct {
 
 // NewOTLP returns a new instance of OTLPReporter
 func NewOTLP(cfg *Config) (*OTLPReporter, error) {
-	cgroupv2ID, err := lru.NewSynced[libpf.PID, string](cfg.CGroupCacheElements,
-		func(pid libpf.PID) uint32 { return uint32(pid) })
-	if err != nil {
-		return nil, err
-	}
-	// Set a lifetime to reduce risk of invalid data in case of PID reuse.
-	cgroupv2ID.SetLifetime(90 * time.Second)
-
-	// Next step: Dynamically configure the size of this LRU.
-	// Currently, we use the length of the JSON array in
-	// hostmetadata/hostmetadata.json.
-	hostmetadata, err := lru.NewSynced[string, string](115, hashString)
-	if err != nil {
-		return nil, err
-	}
-
-	data, err := pdata.New(
-		cfg.SamplesPerSecond,
-		cfg.ExecutablesCacheElements,
-		cfg.FramesCacheElements,
-		cfg.ExtraSampleAttrProd,
-	)
+	base, err := newBaseReporter(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	originsMap := make(map[libpf.Origin]samples.KeyToEventMapping, 2)
-	for _, origin := range []libpf.Origin{support.TraceOriginSampling,
-		support.TraceOriginOffCPU} {
-		originsMap[origin] = make(samples.KeyToEventMapping)
+	var profilesSpool *spool.Spool
+	if cfg.SpoolDir != "" {
+		profilesSpool, err = spool.New(cfg.SpoolDir, cfg.SpoolMaxBytes, cfg.SpoolMaxAge)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &OTLPReporter{
-		baseReporter: &baseReporter{
-			cfg:          cfg,
-			name:         cfg.Name,
-			version:      cfg.Version,
-			pdata:        data,
-			cgroupv2ID:   cgroupv2ID,
-			traceEvents:  xsync.NewRWMutex(originsMap),
-			hostmetadata: hostmetadata,
-			runLoop: &runLoop{
-				stopSignal: make(chan libpf.Void),
-			},
-		},
+		baseReporter:            base,
 		kernelVersion:           cfg.KernelVersion,
 		hostName:                cfg.HostName,
 		ipAddress:               cfg.IPAddress,
 		hostID:                  strconv.FormatUint(cfg.HostID, 10),
 		pkgGRPCOperationTimeout: cfg.GRPCOperationTimeout,
-		client:                  nil,
+		exporter:                nil,
+		spool:                   profilesSpool,
 	}, nil
 }
 
@@ -117,67 +90,88 @@ func (r *OTLPReporter) Start(ctx context.Context) error {
 	// Create a child context for reporting features
 	ctx, cancelReporting := context.WithCancel(ctx)
 
+	if usesHTTP(r.cfg) {
+		exporter, err := newHTTPExporter(r.cfg)
+		if err != nil {
+			cancelReporting()
+			return err
+		}
+		r.exporter = exporter
+		r.stopReporting = func() {
+			cancelReporting()
+			exporter.Close()
+		}
+		r.startRunLoop(ctx)
+		return nil
+	}
+
 	// Establish the gRPC connection before going on, waiting for a response
 	// from the collectionAgent endpoint.
 	// Use grpc.WithBlock() in setupGrpcConnection() for this to work.
 	otlpGrpcConn, err := waitGrpcEndpoint(ctx, r.cfg)
 	if err != nil {
+		// The run loop was not started, so Start can be called again.
 		cancelReporting()
-		r.runLoop.Stop()
 		return err
 	}
-	r.client = pprofileotlp.NewGRPCClient(otlpGrpcConn)
-
-	r.runLoop.Start(ctx, r.cfg.ReportInterval, func() {
-		if err := r.reportOTLPProfile(ctx); err != nil {
-			log.Errorf("Request failed: %v", err)
-		}
-	}, func() {
-		// Allow the GC to purge expired entries to avoid memory leaks.
-		r.pdata.Purge()
-		r.cgroupv2ID.PurgeExpired()
-	})
-
-	// When Stop() is called and a signal to 'stop' is received, then:
-	// - cancel the reporting functions currently running (using context)
-	// - close the gRPC connection with collection-agent
-	go func() {
-		<-r.runLoop.stopSignal
+	r.exporter = &grpcExporter{
+		client:           pprofileotlp.NewGRPCClient(otlpGrpcConn),
+		operationTimeout: r.pkgGRPCOperationTimeout,
+	}
+	r.stopReporting = func() {
 		cancelReporting()
 		if err := otlpGrpcConn.Close(); err != nil {
 			log.Fatalf("Stopping connection of OTLP client client failed: %v", err)
 		}
-	}()
-
+	}
+	r.startRunLoop(ctx)
 	return nil
 }
 
-// reportOTLPProfile creates and sends out an OTLP profile.
-func (r *OTLPReporter) reportOTLPProfile(ctx context.Context) error {
-	traceEvents := r.traceEvents.WLock()
-	events := make(map[libpf.Origin]samples.KeyToEventMapping, 2)
-	for _, origin := range []libpf.Origin{support.TraceOriginSampling,
-		support.TraceOriginOffCPU} {
-		events[origin] = maps.Clone((*traceEvents)[origin])
-		clear((*traceEvents)[origin])
+// Stop sends out the trace events collected since the last report, then
+// cancels the reporting functions and releases the connection to the receiver.
+func (r *OTLPReporter) Stop() {
+	r.runLoop.StopAndWait()
+	if r.stopReporting == nil {
+		// The reporter was not started.
+		return
 	}
-	r.traceEvents.WUnlock(&traceEvents)
+	defer r.stopReporting()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.pkgGRPCOperationTimeout)
+	defer cancel()
+	if err := r.reportOTLPProfile(ctx); err != nil {
+		log.Errorf("Sending final profile failed: %v", err)
+	}
+}
 
-	profiles := r.pdata.Generate(events)
+// startRunLoop starts the periodic reporting of profiles.
+func (r *OTLPReporter) startRunLoop(ctx context.Context) {
+	r.runLoop.Start(ctx, r.cfg.ReportInterval, func() {
+		if err := r.reportOTLPProfile(ctx); err != nil {
+			log.Errorf("Request failed: %v", err)
+		}
+	}, r.purge)
+}
+
+// reportOTLPProfile creates and sends out an OTLP profile.
+func (r *OTLPReporter) reportOTLPProfile(ctx context.Context) error {
+	profiles := r.pdata.Generate(r.takeTraceEvents())
 	for i := 0; i < profiles.ResourceProfiles().Len(); i++ {
 		r.setResource(profiles.ResourceProfiles().At(i))
 	}
 
+	if r.spool != nil {
+		return r.exportSpooled(ctx, profiles)
+	}
+
 	if profiles.SampleCount() == 0 {
 		log.Debugf("Skip sending of OTLP profile with no samples")
 		return nil
 	}
 	req := pprofileotlp.NewExportRequestFromProfiles(profiles)
 
-	reqCtx, ctxCancel := context.WithTimeout(ctx, r.pkgGRPCOperationTimeout)
-	defer ctxCancel()
-	_, err := r.client.Export(reqCtx, req)
-	return err
+	return r.exporter.Export(ctx, req)
 }
 
 // setResource sets the resource information of the origin of the profiles.
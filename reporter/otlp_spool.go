@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package reporter // import "go.opentelemetry.io/ebpf-profiler/reporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/collector/pdata/pprofile"
+	"go.opentelemetry.io/collector/pdata/pprofile/pprofileotlp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.opentelemetry.io/ebpf-profiler/metrics"
+)
+
+// exportSpooled replays the spooled profiles in order and exports profiles
+// afterwards. If the export fails with a retryable error, e.g. because the
+// backend is not reachable or the reporter shuts down, profiles are added to
+// the spool.
+func (r *OTLPReporter) exportSpooled(ctx context.Context, profiles pprofile.Profiles) error {
+	defer r.reportSpoolMetrics()
+
+	unmarshaler := pprofile.ProtoUnmarshaler{}
+	err := r.spool.Replay(func(data []byte) error {
+		spooled, err := unmarshaler.UnmarshalProfiles(data)
+		if err != nil {
+			// Retrying a corrupted entry would block the spool forever.
+			log.Errorf("Dropping corrupted spooled profile: %v", err)
+			metrics.Add(metrics.IDReporterSpoolCorrupted, 1)
+			return nil
+		}
+		err = r.exporter.Export(ctx, pprofileotlp.NewExportRequestFromProfiles(spooled))
+		if err != nil && !isRetryableExportError(err) {
+			// A rejected entry would block the spool until it expires.
+			log.Errorf("Dropping spooled profile rejected by the backend: %v", err)
+			metrics.Add(metrics.IDReporterSpoolRejected, 1)
+			return nil
+		}
+		return err
+	})
+	if err == nil {
+		if profiles.SampleCount() == 0 {
+			log.Debugf("Skip sending of OTLP profile with no samples")
+			return nil
+		}
+		err = r.exporter.Export(ctx, pprofileotlp.NewExportRequestFromProfiles(profiles))
+		if err == nil {
+			return nil
+		}
+	}
+
+	if profiles.SampleCount() == 0 || !isRetryableExportError(err) {
+		return err
+	}
+
+	// Keep the profile until the next attempt. If the spool already holds older
+	// profiles, this is appended after them, so that the order is preserved.
+	marshaler := pprofile.ProtoMarshaler{}
+	data, marshalErr := marshaler.MarshalProfiles(profiles)
+	if marshalErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to spool profile: %v", marshalErr))
+	}
+	if spoolErr := r.spool.Put(data); spoolErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to spool profile: %v", spoolErr))
+	}
+	log.Debugf("Spooled OTLP profile after failed export: %v", err)
+	return err
+}
+
+// isRetryableExportError returns true if an export that failed with err may
+// succeed if it is sent again, as defined by the OTLP specification. Rejections
+// of the request itself are permanent, transport failures are not.
+func isRetryableExportError(err error) bool {
+	var statusErr *errHTTPStatus
+	if errors.As(err, &statusErr) {
+		return statusErr.retryable()
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		// Not an answer of the backend, e.g. a connection failure or the
+		// cancellation of the export.
+		return true
+	}
+	switch s.Code() {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+// reportSpoolMetrics reports the state of the spool.
+func (r *OTLPReporter) reportSpoolMetrics() {
+	metrics.AddSlice([]metrics.Metric{
+		{
+			ID:    metrics.IDReporterSpoolBacklog,
+			Value: metrics.MetricValue(r.spool.Len()),
+		},
+		{
+			ID:    metrics.IDReporterSpoolBacklogBytes,
+			Value: metrics.MetricValue(r.spool.Size()),
+		},
+		{
+			ID:    metrics.IDReporterSpoolDropped,
+			Value: metrics.MetricValue(r.spool.Dropped()),
+		},
+	})
+}
@@ -0,0 +1,159 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pprofile/pprofileotlp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+// fakeExporter records the exported requests and fails while unreachable is set.
+// Requests with the PIDs in rejected fail with the given error.
+type fakeExporter struct {
+	unreachable bool
+	rejected    map[int64]error
+	exported    []pprofileotlp.ExportRequest
+}
+
+func (e *fakeExporter) Export(_ context.Context, req pprofileotlp.ExportRequest) error {
+	if e.unreachable {
+		return errors.New("collector unreachable")
+	}
+	if err, ok := e.rejected[exportedPID(req)]; ok {
+		return err
+	}
+	e.exported = append(e.exported, req)
+	return nil
+}
+
+// exportedPID returns the process.pid attribute of the first sample of req.
+func exportedPID(req pprofileotlp.ExportRequest) int64 {
+	profile := req.Profiles().ResourceProfiles().At(0).ScopeProfiles().At(0).
+		Profiles().At(0)
+	attrs := profile.AttributeTable()
+	sample := profile.Sample().At(0)
+	for j := 0; j < sample.AttributeIndices().Len(); j++ {
+		attr := attrs.At(int(sample.AttributeIndices().At(j)))
+		if attr.Key() == "process.pid" {
+			return attr.Value().Int()
+		}
+	}
+	return 0
+}
+
+func newTestSpoolReporter(t *testing.T) (*OTLPReporter, *fakeExporter) {
+	t.Helper()
+	r, err := NewOTLP(&Config{
+		ExecutablesCacheElements: 1,
+		FramesCacheElements:      1,
+		CGroupCacheElements:      1,
+		SamplesPerSecond:         20,
+		SpoolDir:                 t.TempDir(),
+	})
+	require.NoError(t, err)
+	exporter := &fakeExporter{unreachable: true}
+	r.exporter = exporter
+	return r, exporter
+}
+
+// reportSpoolTestProfile exports a profile with a sample of the given PID.
+func reportSpoolTestProfile(ctx context.Context, t *testing.T, r *OTLPReporter,
+	pid libpf.PID) error {
+	t.Helper()
+	require.NoError(t, r.ReportTraceEvent(&libpf.Trace{}, &samples.TraceEventMeta{
+		Timestamp: libpf.UnixTime64(time.Now().UnixNano()),
+		Origin:    support.TraceOriginSampling,
+		PID:       pid,
+	}))
+	return r.reportOTLPProfile(ctx)
+}
+
+func TestOTLPReporterSpool(t *testing.T) {
+	r, exporter := newTestSpoolReporter(t)
+	report := func(pid libpf.PID) error {
+		return reportSpoolTestProfile(context.Background(), t, r, pid)
+	}
+
+	require.Error(t, report(1))
+	require.Error(t, report(2))
+	assert.Equal(t, 2, r.spool.Len())
+
+	// Without new samples nothing is spooled.
+	require.Error(t, r.reportOTLPProfile(context.Background()))
+	assert.Equal(t, 2, r.spool.Len())
+
+	exporter.unreachable = false
+	require.NoError(t, report(3))
+	assert.Equal(t, 0, r.spool.Len())
+
+	require.Len(t, exporter.exported, 3)
+	for i, req := range exporter.exported {
+		assert.Equal(t, int64(i+1), exportedPID(req))
+	}
+}
+
+func TestOTLPReporterSpoolRejected(t *testing.T) {
+	r, exporter := newTestSpoolReporter(t)
+	report := func(pid libpf.PID) error {
+		return reportSpoolTestProfile(context.Background(), t, r, pid)
+	}
+
+	require.Error(t, report(1))
+	require.Error(t, report(2))
+	assert.Equal(t, 2, r.spool.Len())
+
+	// A spooled profile rejected by the backend does not block the ones after it.
+	exporter.unreachable = false
+	exporter.rejected = map[int64]error{
+		1: status.Error(codes.InvalidArgument, "bad profile"),
+		4: &errHTTPStatus{code: http.StatusRequestEntityTooLarge},
+	}
+	require.NoError(t, report(3))
+	assert.Equal(t, 0, r.spool.Len())
+
+	// A rejected profile is not spooled.
+	require.Error(t, report(4))
+	assert.Equal(t, 0, r.spool.Len())
+
+	require.Len(t, exporter.exported, 2)
+	assert.Equal(t, int64(2), exportedPID(exporter.exported[0]))
+	assert.Equal(t, int64(3), exportedPID(exporter.exported[1]))
+}
+
+func TestOTLPReporterSpoolCanceled(t *testing.T) {
+	r, exporter := newTestSpoolReporter(t)
+	exporter.rejected = map[int64]error{1: status.Error(codes.Canceled, "shutdown")}
+	exporter.unreachable = false
+
+	// The profile in flight when the reporter shuts down is kept.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, reportSpoolTestProfile(ctx, t, r, 1))
+	assert.Equal(t, 1, r.spool.Len())
+}
+
+func TestIsRetryableExportError(t *testing.T) {
+	for err, want := range map[error]bool{
+		errors.New("connection refused"):                    true,
+		context.Canceled:                                    true,
+		status.Error(codes.Unavailable, ""):                 true,
+		status.Error(codes.ResourceExhausted, ""):           true,
+		status.Error(codes.InvalidArgument, ""):             false,
+		status.Error(codes.PermissionDenied, ""):            false,
+		&errHTTPStatus{code: http.StatusServiceUnavailable}: true,
+		&errHTTPStatus{code: http.StatusBadRequest}:         false,
+	} {
+		assert.Equal(t, want, isRetryableExportError(err), err.Error())
+	}
+}
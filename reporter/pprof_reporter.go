@@ -0,0 +1,333 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package reporter // import "go.opentelemetry.io/ebpf-profiler/reporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/internal/pprof"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+// Assert that we implement the full Reporter interface.
+var _ Reporter = (*PprofReporter)(nil)
+
+const (
+	// pprofFileSuffix is the file name suffix of the written pprof files.
+	pprofFileSuffix = ".pb.gz"
+	// pprofTimeFormat is the format of the timestamp in the written file names.
+	// It sorts lexicographically in chronological order.
+	pprofTimeFormat = "20060102T150405.000000000Z"
+	// pprofTempPrefix is the file name prefix of the temporary files the pprof
+	// files are written to before they are renamed.
+	pprofTempPrefix = ".tmp-"
+)
+
+// pprofOriginNames maps the built-in trace origins to the prefix of the files
+// they are written to. Event origins use their name as prefix.
+var pprofOriginNames = map[libpf.Origin]string{
+	support.TraceOriginSampling: "oncpu",
+	support.TraceOriginOffCPU:   "offcpu",
+	support.TraceOriginWakeup:   "wakeup",
+	support.TraceOriginProbe:    "probe",
+}
+
+// pprofFileNameReplacer replaces the characters of event origin names that are
+// not safe in file names.
+var pprofFileNameReplacer = strings.NewReplacer("/", "_", "\\", "_", ":", "_", " ", "_")
+
+// PprofReporter writes gzip compressed pprof files into a local directory, or
+// records a single profile to a file until it is stopped.
+type PprofReporter struct {
+	*baseReporter
+
+	// outputDir is the directory the pprof files are written to.
+	outputDir string
+
+	// outputFile is the file a single profile is written to on Stop. If set,
+	// no profiles are written to outputDir.
+	outputFile string
+
+	// maxBytes is the maximum total size of the pprof files kept in outputDir.
+	maxBytes int64
+
+	// maxAge is the maximum age of the pprof files kept in outputDir.
+	maxAge time.Duration
+
+	// originNames maps the reported trace origins to the prefix of the files
+	// they are written to.
+	originNames map[libpf.Origin]string
+}
+
+// NewPprof returns a new instance of PprofReporter.
+func NewPprof(cfg *Config) (*PprofReporter, error) {
+	if cfg.PprofOutputDir == "" && cfg.PprofOutputFile == "" {
+		return nil, errors.New("no pprof output directory or file configured")
+	}
+	if cfg.PprofOutputDir != "" && cfg.PprofOutputFile != "" {
+		return nil, errors.New("pprof output directory and file are mutually exclusive")
+	}
+
+	base, err := newBaseReporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	originNames := maps.Clone(pprofOriginNames)
+	for _, eventOrigin := range cfg.EventOrigins {
+		originNames[eventOrigin.Origin] = pprofFileNameReplacer.Replace(eventOrigin.Name)
+	}
+
+	return &PprofReporter{
+		baseReporter: base,
+		outputDir:    cfg.PprofOutputDir,
+		outputFile:   cfg.PprofOutputFile,
+		maxBytes:     cfg.PprofMaxBytes,
+		maxAge:       cfg.PprofMaxAge,
+		originNames:  originNames,
+	}, nil
+}
+
+// Start creates the output directory and starts writing profiles to it. When
+// recording to a file, the trace events are only collected until Stop.
+func (r *PprofReporter) Start(ctx context.Context) error {
+	if r.outputFile != "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(r.outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create pprof output directory: %v", err)
+	}
+	if err := removeTempFiles(r.outputDir); err != nil {
+		log.Warnf("Failed to remove stale temporary pprof files: %v", err)
+	}
+
+	r.runLoop.Start(ctx, r.cfg.ReportInterval, func() {
+		if err := r.reportProfile(time.Now()); err != nil {
+			log.Errorf("Writing pprof profile failed: %v", err)
+		}
+	}, r.purge)
+
+	return nil
+}
+
+// Stop stops the reporter and writes out the profiles of the trace events
+// collected since the last report.
+func (r *PprofReporter) Stop() {
+	r.runLoop.StopAndWait()
+	if r.outputFile != "" {
+		if err := r.recordProfile(); err != nil {
+			log.Errorf("Writing recorded pprof profile failed: %v", err)
+		}
+		return
+	}
+	if err := r.reportProfile(time.Now()); err != nil {
+		log.Errorf("Writing final pprof profile failed: %v", err)
+	}
+}
+
+// reportProfile writes one pprof file per origin for the collected trace events
+// and applies the retention policy afterwards.
+func (r *PprofReporter) reportProfile(now time.Time) error {
+	written, err := r.writeProfiles(func(origin libpf.Origin, i int) string {
+		baseName := r.originNames[origin] + "-" + now.UTC().Format(pprofTimeFormat)
+		if i > 0 {
+			baseName += "-" + strconv.Itoa(i)
+		}
+		return filepath.Join(r.outputDir, baseName+pprofFileSuffix)
+	})
+	for fileName, sampleCount := range written {
+		log.Debugf("Wrote pprof profile with %d samples to %s", sampleCount, fileName)
+	}
+
+	return errors.Join(err, r.applyRetention(now))
+}
+
+// recordProfile writes the trace events collected since the reporter started
+// to outputFile. Origins other than on-CPU sampling are written to files next
+// to it, e.g. out.offcpu.pb.gz for out.pb.gz.
+func (r *PprofReporter) recordProfile() error {
+	base := strings.TrimSuffix(r.outputFile, pprofFileSuffix)
+	written, err := r.writeProfiles(func(origin libpf.Origin, i int) string {
+		if origin == support.TraceOriginSampling && i == 0 {
+			return r.outputFile
+		}
+		fileName := base + "." + r.originNames[origin]
+		if i > 0 {
+			fileName += "-" + strconv.Itoa(i)
+		}
+		return fileName + pprofFileSuffix
+	})
+
+	fileNames := slices.Sorted(maps.Keys(written))
+	for _, fileName := range fileNames {
+		log.Infof("Recorded %d samples to %s", written[fileName], fileName)
+	}
+	if len(fileNames) == 0 && err == nil {
+		log.Info("Recorded no samples")
+	}
+	return err
+}
+
+// writeProfiles writes one pprof file per origin for the collected trace events.
+// An origin results in several profiles if the sampling frequency was changed
+// in the meantime, fileName returns the name of the i-th profile of an origin.
+// It returns the number of samples written per file.
+func (r *PprofReporter) writeProfiles(
+	fileName func(origin libpf.Origin, i int) string) (map[string]int, error) {
+	events := r.takeTraceEvents()
+	written := make(map[string]int)
+
+	var errs []error
+	for origin := range r.originNames {
+		if len(events[origin]) == 0 {
+			continue
+		}
+
+		profiles := r.pdata.Generate(map[libpf.Origin]samples.KeyToEventMapping{
+			origin: events[origin],
+		})
+		if profiles.SampleCount() == 0 {
+			continue
+		}
+		originProfiles := profiles.ResourceProfiles().At(0).ScopeProfiles().At(0).Profiles()
+		for i := 0; i < originProfiles.Len(); i++ {
+			profile := originProfiles.At(i)
+			name := fileName(origin, i)
+			if err := writePprofFile(name, func(f *os.File) error {
+				return pprof.Write(f, profile)
+			}); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			written[name] = profile.Sample().Len()
+		}
+	}
+	return written, errors.Join(errs...)
+}
+
+// writePprofFile writes a file via a temporary file, so that readers of the
+// output directory never observe partially written files.
+func writePprofFile(fileName string, write func(f *os.File) error) error {
+	f, err := os.CreateTemp(filepath.Dir(fileName), pprofTempPrefix+filepath.Base(fileName))
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+
+	if err = write(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to write %s: %v", fileName, err)
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, fileName)
+}
+
+// removeTempFiles removes the temporary files left behind in dir by a previous
+// run that was killed while writing a pprof file.
+func removeTempFiles(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), pprofTempPrefix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil &&
+			!errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyRetention removes the pprof files from the output directory that are
+// older than maxAge, and the oldest files until their total size is below maxBytes.
+func (r *PprofReporter) applyRetention(now time.Time) error {
+	if r.maxAge <= 0 && r.maxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(r.outputDir)
+	if err != nil {
+		return err
+	}
+
+	type pprofFile struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	files := make([]pprofFile, 0, len(entries))
+	var totalSize int64
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !r.isPprofFileName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// The file might have been removed concurrently.
+			continue
+		}
+		files = append(files, pprofFile{
+			name:    entry.Name(),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		totalSize += info.Size()
+	}
+
+	// Oldest files first.
+	slices.SortFunc(files, func(a, b pprofFile) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	var errs []error
+	for _, f := range files {
+		expired := r.maxAge > 0 && now.Sub(f.modTime) > r.maxAge
+		oversized := r.maxBytes > 0 && totalSize > r.maxBytes
+		if !expired && !oversized {
+			break
+		}
+		if err := os.Remove(filepath.Join(r.outputDir, f.name)); err != nil &&
+			!errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		totalSize -= f.size
+	}
+	return errors.Join(errs...)
+}
+
+// isPprofFileName returns true if name matches the files written by PprofReporter.
+func (r *PprofReporter) isPprofFileName(name string) bool {
+	if !strings.HasSuffix(name, pprofFileSuffix) {
+		return false
+	}
+	for _, prefix := range r.originNames {
+		if strings.HasPrefix(name, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package reporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+func newTestPprofReporter(t *testing.T, maxBytes int64, maxAge time.Duration) *PprofReporter {
+	t.Helper()
+	r, err := NewPprof(&Config{
+		ExecutablesCacheElements: 1,
+		FramesCacheElements:      1,
+		CGroupCacheElements:      1,
+		SamplesPerSecond:         20,
+		PprofOutputDir:           t.TempDir(),
+		PprofMaxBytes:            maxBytes,
+		PprofMaxAge:              maxAge,
+	})
+	require.NoError(t, err)
+	return r
+}
+
+func TestPprofReporterReportProfile(t *testing.T) {
+	r := newTestPprofReporter(t, 0, 0)
+
+	trace := &libpf.Trace{
+		Files:              []libpf.FileID{libpf.NewFileID(1, 2)},
+		Linenos:            []libpf.AddressOrLineno{0x42},
+		FrameTypes:         []libpf.FrameType{libpf.NativeFrame},
+		MappingStart:       []libpf.Address{0x1000},
+		MappingEnd:         []libpf.Address{0x2000},
+		MappingFileOffsets: []uint64{0},
+	}
+	for _, origin := range []libpf.Origin{support.TraceOriginSampling,
+		support.TraceOriginOffCPU} {
+		require.NoError(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+			Timestamp: libpf.UnixTime64(time.Now().UnixNano()),
+			Comm:      "app",
+			Origin:    origin,
+			OffTime:   1000,
+		}))
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, r.reportProfile(now))
+
+	entries, err := os.ReadDir(r.outputDir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{
+		"oncpu-20240102T030405.000000000Z.pb.gz",
+		"offcpu-20240102T030405.000000000Z.pb.gz",
+	}, names)
+
+	// All trace events were consumed, so nothing is written for the next interval.
+	require.NoError(t, r.reportProfile(now.Add(time.Second)))
+	entries, err = os.ReadDir(r.outputDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestPprofReporterStopWritesFinalProfile(t *testing.T) {
+	r := newTestPprofReporter(t, 0, 0)
+	r.cfg.ReportInterval = time.Hour
+	require.NoError(t, r.Start(context.Background()))
+
+	trace := &libpf.Trace{
+		Files:              []libpf.FileID{libpf.NewFileID(1, 2)},
+		Linenos:            []libpf.AddressOrLineno{0x42},
+		FrameTypes:         []libpf.FrameType{libpf.NativeFrame},
+		MappingStart:       []libpf.Address{0x1000},
+		MappingEnd:         []libpf.Address{0x2000},
+		MappingFileOffsets: []uint64{0},
+	}
+	require.NoError(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+		Timestamp: libpf.UnixTime64(time.Now().UnixNano()),
+		Comm:      "app",
+		Origin:    support.TraceOriginSampling,
+	}))
+	r.Stop()
+
+	entries, err := os.ReadDir(r.outputDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestPprofReporterStartRemovesTempFiles(t *testing.T) {
+	r := newTestPprofReporter(t, 0, 0)
+	r.cfg.ReportInterval = time.Hour
+	for _, name := range []string{".tmp-oncpu-1.pb.gz123", "oncpu-2.pb.gz", "unrelated"} {
+		require.NoError(t, os.WriteFile(filepath.Join(r.outputDir, name), nil, 0o644))
+	}
+	require.NoError(t, r.Start(context.Background()))
+	r.Stop()
+
+	entries, err := os.ReadDir(r.outputDir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"oncpu-2.pb.gz", "unrelated"}, names)
+}
+
+func TestPprofReporterEventOriginNames(t *testing.T) {
+	r, err := NewPprof(&Config{
+		ExecutablesCacheElements: 1,
+		FramesCacheElements:      1,
+		CGroupCacheElements:      1,
+		SamplesPerSecond:         20,
+		PprofOutputDir:           t.TempDir(),
+		EventOrigins: []samples.EventOrigin{
+			{Origin: support.TraceOriginKernelEvent, Name: "syscalls/sys_enter:write"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "syscalls_sys_enter_write", r.originNames[support.TraceOriginKernelEvent])
+}
+
+func TestPprofReporterRecord(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "out.pb.gz")
+	r, err := NewPprof(&Config{
+		ExecutablesCacheElements: 1,
+		FramesCacheElements:      1,
+		CGroupCacheElements:      1,
+		SamplesPerSecond:         20,
+		PprofOutputFile:          outputFile,
+	})
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background()))
+
+	trace := &libpf.Trace{
+		Files:              []libpf.FileID{libpf.NewFileID(1, 2)},
+		Linenos:            []libpf.AddressOrLineno{0x42},
+		FrameTypes:         []libpf.FrameType{libpf.NativeFrame},
+		MappingStart:       []libpf.Address{0x1000},
+		MappingEnd:         []libpf.Address{0x2000},
+		MappingFileOffsets: []uint64{0},
+	}
+	for _, origin := range []libpf.Origin{support.TraceOriginSampling,
+		support.TraceOriginSampling, support.TraceOriginOffCPU} {
+		require.NoError(t, r.ReportTraceEvent(trace, &samples.TraceEventMeta{
+			Timestamp: libpf.UnixTime64(time.Now().UnixNano()),
+			Comm:      "app",
+			Origin:    origin,
+			OffTime:   1000,
+		}))
+	}
+	r.Stop()
+
+	entries, err := os.ReadDir(filepath.Dir(outputFile))
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"out.pb.gz", "out.offcpu.pb.gz"}, names)
+}
+
+func TestPprofReporterRetention(t *testing.T) {
+	now := time.Now()
+
+	for _, tt := range []struct {
+		name     string
+		maxBytes int64
+		maxAge   time.Duration
+		want     []string
+	}{
+		{
+			name: "no retention",
+			want: []string{"oncpu-1.pb.gz", "oncpu-2.pb.gz", "offcpu-3.pb.gz", "unrelated"},
+		},
+		{
+			name:   "by age",
+			maxAge: 90 * time.Minute,
+			want:   []string{"offcpu-3.pb.gz", "unrelated"},
+		},
+		{
+			name:     "by size",
+			maxBytes: 25,
+			want:     []string{"oncpu-2.pb.gz", "offcpu-3.pb.gz", "unrelated"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestPprofReporter(t, tt.maxBytes, tt.maxAge)
+
+			for i, name := range []string{"oncpu-1.pb.gz", "oncpu-2.pb.gz",
+				"offcpu-3.pb.gz", "unrelated"} {
+				path := filepath.Join(r.outputDir, name)
+				require.NoError(t, os.WriteFile(path, make([]byte, 10), 0o644))
+				modTime := now.Add(time.Duration(i-3) * time.Hour)
+				require.NoError(t, os.Chtimes(path, modTime, modTime))
+			}
+
+			require.NoError(t, r.applyRetention(now))
+
+			entries, err := os.ReadDir(r.outputDir)
+			require.NoError(t, err)
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			assert.ElementsMatch(t, tt.want, names)
+		})
+	}
+}
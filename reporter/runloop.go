@@ -14,10 +14,14 @@ import (
 type runLoop struct {
 	// stopSignal is the stop signal for shutting down all background tasks.
 	stopSignal chan libpf.Void
+	// done is closed once the run loop started by Start returned.
+	done chan libpf.Void
 }
 
 func (rl *runLoop) Start(ctx context.Context, reportInterval time.Duration, run, purge func()) {
+	rl.done = make(chan libpf.Void)
 	go func() {
+		defer close(rl.done)
 		tick := time.NewTicker(reportInterval)
 		defer tick.Stop()
 		purgeTick := time.NewTicker(5 * time.Minute)
@@ -42,3 +46,12 @@ func (rl *runLoop) Start(ctx context.Context, reportInterval time.Duration, run,
 func (rl *runLoop) Stop() {
 	close(rl.stopSignal)
 }
+
+// StopAndWait stops the run loop and waits until a report that is currently
+// in progress finished.
+func (rl *runLoop) StopAndWait() {
+	rl.Stop()
+	if rl.done != nil {
+		<-rl.done
+	}
+}
@@ -30,6 +30,14 @@ type SampleAttrProducer interface {
 	ExtraSampleAttrs(attrMgr *AttrTableManager, meta any) []int32
 }
 
+// ContainerMetadataProvider resolves metadata about the container a sample was
+// recorded in.
+type ContainerMetadataProvider interface {
+	// ContainerMetadata returns the attributes describing the container with
+	// the given cgroupv2 path. It returns nil if nothing is known about it.
+	ContainerMetadata(cgroupPath string) []attribute.KeyValue
+}
+
 // AttrTableManager maintains index allocation and deduplication for attribute tables.
 type AttrTableManager struct {
 	// indices maps compound keys to the indices in the attribute table.
@@ -65,6 +73,24 @@ func (m *AttrTableManager) AppendOptionalString(
 	m.appendAny(attrs, key, compound, value)
 }
 
+// AppendAttribute adds the index for the given attribute to an attribute index
+// slice. Like with AppendOptionalString, empty strings and slices are skipped.
+func (m *AttrTableManager) AppendAttribute(attrs pcommon.Int32Slice, kv attribute.KeyValue) {
+	switch kv.Value.Type() {
+	case attribute.INT64:
+		m.AppendInt(attrs, kv.Key, kv.Value.AsInt64())
+	case attribute.STRINGSLICE:
+		values := kv.Value.AsStringSlice()
+		if len(values) == 0 {
+			return
+		}
+		compound := fmt.Sprintf("%v_%q", kv.Key, values)
+		m.appendAny(attrs, kv.Key, compound, values)
+	default:
+		m.AppendOptionalString(attrs, kv.Key, kv.Value.Emit())
+	}
+}
+
 func (m *AttrTableManager) appendAny(
 	attrs pcommon.Int32Slice,
 	key attribute.Key,
@@ -86,6 +112,12 @@ func (m *AttrTableManager) appendAny(
 		a.Value().SetInt(v)
 	case string:
 		a.Value().SetStr(v)
+	case []string:
+		s := a.Value().SetEmptySlice()
+		s.EnsureCapacity(len(v))
+		for _, str := range v {
+			s.AppendEmpty().SetStr(str)
+		}
 	}
 	m.indices[compoundKey] = newIndex
 	attrs.Append(newIndex)
@@ -12,6 +12,7 @@ import (
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pprofile"
 	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
 )
 
@@ -122,3 +123,26 @@ func TestAttrTableManager(t *testing.T) {
 		})
 	}
 }
+
+func TestAttrTableManagerAppendAttribute(t *testing.T) {
+	attrTable := pprofile.NewAttributeTableSlice()
+	mgr := NewAttrTableManager(attrTable)
+
+	inner := pcommon.NewInt32Slice()
+	for _, kv := range []attribute.KeyValue{
+		semconv.ContainerName("web"),
+		semconv.ContainerImageTags("1.25", "latest"),
+		semconv.ContainerImageTags("1.25", "latest"),
+		semconv.ContainerImageName(""),
+		semconv.ContainerImageTags(),
+		attribute.Int64("container.restarts", 2),
+	} {
+		mgr.AppendAttribute(inner, kv)
+	}
+
+	require.Equal(t, []int32{0, 1, 1, 2}, inner.AsRaw())
+	require.Equal(t, 3, attrTable.Len())
+	assert.Equal(t, "web", attrTable.At(0).Value().AsRaw())
+	assert.Equal(t, []any{"1.25", "latest"}, attrTable.At(1).Value().AsRaw())
+	assert.Equal(t, int64(2), attrTable.At(2).Value().AsRaw())
+}
@@ -3,19 +3,31 @@
 
 package samples // import "go.opentelemetry.io/ebpf-profiler/reporter/samples"
 
-import "go.opentelemetry.io/ebpf-profiler/libpf"
+import (
+	"time"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+)
 
 type TraceEventMeta struct {
-	Timestamp      libpf.UnixTime64
-	Comm           string
-	ProcessName    string
-	ExecutablePath string
-	APMServiceName string
-	PID, TID       libpf.PID
-	CPU            int
-	Origin         libpf.Origin
-	OffTime        int64
-	EnvVars        map[string]string
+	Timestamp        libpf.UnixTime64
+	Comm             string
+	ProcessName      string
+	ExecutablePath   string
+	APMServiceName   string
+	PID, TID         libpf.PID
+	CPU              int
+	Origin           libpf.Origin
+	OffTime          int64
+	EnvVars          map[string]string
+	CommandLine      string
+	ParentPID        libpf.PID
+	StartTime        time.Time
+	Ancestors        []string
+	Wakeup           libpf.WakeupLink
+	TaskState        string
+	BlockingReason   string
+	SamplesPerSecond int
 }
 
 // TraceEvents holds known information about a trace.
@@ -26,9 +38,12 @@ type TraceEvents struct {
 	MappingStarts      []libpf.Address
 	MappingEnds        []libpf.Address
 	MappingFileOffsets []uint64
-	Timestamps         []uint64 // in nanoseconds
-	OffTimes           []int64  // in nanoseconds
+	Timestamps         []uint64           // in nanoseconds
+	OffTimes           []int64            // in nanoseconds
+	Wakeups            []libpf.WakeupLink // off-CPU and wakeup origins only
 	EnvVars            map[string]string
+	Ancestors          []string
+	CustomLabels       map[string]string
 }
 
 // TraceAndMetaKey is the deduplication key for samples. This **must always**
@@ -46,12 +61,40 @@ type TraceAndMetaKey struct {
 	ProcessName string
 	// Executable path is retrieved from /proc/PID/exe
 	ExecutablePath string
+	// Command line is retrieved from /proc/PID/cmdline
+	CommandLine string
+	// Parent PID and start time are retrieved from /proc/PID/stat
+	ParentPid int64
+	StartTime int64
+	// SamplesPerSecond is the sampling frequency on-CPU samples were collected
+	// with. It is zero for other origins.
+	SamplesPerSecond int
+	// TaskState and BlockingReason describe why an off-CPU task was scheduled out.
+	TaskState      string
+	BlockingReason string
 
 	// ExtraMeta stores extra meta info that may have been produced by a
 	// `SampleAttrProducer` instance. May be nil.
 	ExtraMeta any
 }
 
+// EventOrigin describes a trace origin that is configured at runtime, e.g. a
+// kernel event that triggers the collection of stack traces. Each event origin
+// is reported as a separate profile.
+type EventOrigin struct {
+	// Origin is the trace origin of the event.
+	Origin libpf.Origin
+	// Name identifies the event in file names and root frames.
+	Name string
+	// SampleType is the sample type of the profile, e.g. "syscalls:sys_enter_write".
+	SampleType string
+	// SampleUnit is the unit of the sample values, e.g. "count".
+	SampleUnit string
+	// Period is the number of events a sample stands for, or 0 if the samples
+	// are not taken periodically.
+	Period int64
+}
+
 // KeyToEventMapping supports temporary mapping traces to additional information.
 type KeyToEventMapping map[TraceAndMetaKey]*TraceEvents
 
@@ -75,10 +118,12 @@ type SourceInfo struct {
 	FunctionOffset uint32
 	FunctionName   string
 	FilePath       string
+	ModuleName     string
 }
 
 // FuncInfo is a helper to construct profile.Function messages.
 type FuncInfo struct {
-	Name     string
-	FileName string
+	Name       string
+	SystemName string
+	FileName   string
 }
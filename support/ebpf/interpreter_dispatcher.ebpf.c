@@ -91,6 +91,26 @@ bpf_map_def SEC("maps") pid_page_to_mapping_info = {
   .map_flags   = BPF_F_NO_PREALLOC,
 };
 
+// cgroup_filter contains the IDs of the cgroupv2 cgroups that are profiled, if
+// SystemConfig.filter_cgroups is set. The Go code keeps it in sync with the
+// configured cgroups and their descendants.
+bpf_map_def SEC("maps") cgroup_filter = {
+  .type        = BPF_MAP_TYPE_HASH,
+  .key_size    = sizeof(u64),
+  .value_size  = sizeof(bool),
+  .max_entries = 4096,
+};
+
+// pid_filter contains the PIDs of the processes that are profiled, if
+// SystemConfig.filter_pids is set. Children of these processes are added by
+// tracepoint__task_newtask if SystemConfig.follow_children is set.
+bpf_map_def SEC("maps") pid_filter = {
+  .type        = BPF_MAP_TYPE_HASH,
+  .key_size    = sizeof(u32),
+  .value_size  = sizeof(bool),
+  .max_entries = 16384,
+};
+
 // inhibit_events map is used to inhibit sending events to user space.
 //
 // Only one event needs to be sent as it's a manual trigger to start processing
@@ -0,0 +1,75 @@
+// This file contains the code for collecting stack traces on kernel
+// tracepoints and kprobes.
+
+#include "bpfdefs.h"
+#include "tracemgmt.h"
+#include "types.h"
+
+// tracepoint_progs maps from a program ID to a tracepoint eBPF program
+bpf_map_def SEC("maps") tracepoint_progs = {
+  .type        = BPF_MAP_TYPE_PROG_ARRAY,
+  .key_size    = sizeof(u32),
+  .value_size  = sizeof(u32),
+  .max_entries = NUM_TRACER_PROGS,
+};
+
+// collect_kernel_event collects the stack trace for a kernel event trigger.
+// The attach cookie holds the trace origin of the trigger in the lower 32 bits
+// and the sampling ratio in the upper 32 bits: one of ratio events is sampled.
+static inline __attribute__((__always_inline__)) int
+collect_kernel_event(void *ctx, struct pt_regs *regs)
+{
+  u64 pid_tgid = bpf_get_current_pid_tgid();
+  u32 pid      = pid_tgid >> 32;
+  u32 tid      = pid_tgid & 0xFFFFFFFF;
+
+  if (pid == 0 || tid == 0) {
+    return 0;
+  }
+
+  u64 cookie = bpf_get_attach_cookie(ctx);
+  u32 origin = cookie & 0xFFFFFFFF;
+  u32 ratio  = cookie >> 32;
+  if (origin < TRACE_KERNEL_EVENT) {
+    // Not attached by the tracer.
+    return 0;
+  }
+
+  if (ratio > 1 && bpf_get_prandom_u32() % ratio != 0) {
+    return 0;
+  }
+
+  if (!pid_is_profiled(pid) || !cgroup_is_profiled()) {
+    return 0;
+  }
+
+  u64 ts = bpf_ktime_get_ns();
+  DEBUG_PRINT("==== kernel event %u ====", origin);
+
+  return collect_trace(ctx, regs, (TraceOrigin)origin, pid, tid, ts, NULL);
+}
+
+// kprobe__generic serves as entry point for kprobe triggered stack collection.
+SEC("kprobe/generic")
+int kprobe__generic(struct pt_regs *ctx)
+{
+  return collect_kernel_event(ctx, ctx);
+}
+
+// tracepoint__generic serves as entry point for tracepoint triggered stack
+// collection. Tracepoints do not provide the registers, so the user mode
+// registers are taken from the task.
+SEC("tracepoint/generic")
+int tracepoint__generic(void *ctx)
+{
+  return collect_kernel_event(ctx, NULL);
+}
+
+// tracepoint_dummy is never loaded or called. It just makes sure tracepoint_progs
+// is referenced and make the compiler and linker happy.
+SEC("tracepoint/dummy")
+int tracepoint_dummy(void *ctx)
+{
+  bpf_tail_call(ctx, &tracepoint_progs, 0);
+  return 0;
+}
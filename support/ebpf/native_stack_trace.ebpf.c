@@ -138,6 +138,13 @@ static ErrorCode get_stack_delta(UnwindState *state, int *addrDiff, u32 *unwindI
 {
   u64 exe_id = state->text_section_id;
 
+  if (exe_id == FRAME_POINTER_FILE_ID) {
+    // JIT code without stack deltas: follow the frame pointer chain.
+    *addrDiff   = 0;
+    *unwindInfo = STACK_DELTA_COMMAND_FLAG | UNWIND_COMMAND_FRAME_POINTER;
+    return ERR_OK;
+  }
+
   // Look up the stack delta page information for this address.
   StackDeltaPageKey key = {};
   key.fileID            = state->text_section_id;
@@ -402,6 +409,17 @@ static ErrorCode unwind_one_frame(u64 pid, u32 frame_idx, UnwindState *state, bo
       state->return_address = false;
       DEBUG_PRINT("signal frame");
       goto frame_ok;
+    case UNWIND_COMMAND_FRAME_POINTER:
+      // The frame record at FP holds the caller's FP followed by the return address.
+      if (!state->fp) {
+        goto err_native_pc_read;
+      }
+      cfa = state->fp + 16;
+      if (bpf_probe_read_user(&state->fp, sizeof(state->fp), (void *)state->fp)) {
+        goto err_native_pc_read;
+      }
+      DEBUG_PRINT("frame pointer, cfa=0x%lx", (unsigned long)cfa);
+      break;
     case UNWIND_COMMAND_STOP: *stop = true; return ERR_OK;
     default: return ERR_UNREACHABLE;
     }
@@ -484,6 +502,17 @@ static ErrorCode unwind_one_frame(u64 pid, u32 frame_idx, struct UnwindState *st
       state->lr_invalid     = false;
       DEBUG_PRINT("signal frame");
       goto frame_ok;
+    case UNWIND_COMMAND_FRAME_POINTER:
+      // The frame record at FP holds the caller's FP followed by the return address.
+      if (!state->fp || bpf_probe_read_user(&rt_regs, 16, (void *)state->fp)) {
+        goto err_native_pc_read;
+      }
+      state->sp = state->fp + 16;
+      state->fp = rt_regs[0];
+      state->pc = normalize_pac_ptr(rt_regs[1]);
+      DEBUG_PRINT("frame pointer");
+      unwinder_mark_nonleaf_frame(state);
+      goto frame_ok;
     case UNWIND_COMMAND_STOP: *stop = true; return ERR_OK;
     default: return ERR_UNREACHABLE;
     }
@@ -628,8 +657,10 @@ static inline __attribute__((__always_inline__)) int unwind_native(struct pt_reg
   return -1;
 }
 
-SEC("perf_event/native_tracer_entry")
-int native_tracer_entry(struct bpf_perf_event_data *ctx)
+// perf_event_entry collects the trace of the task that triggered a perf event
+// and reports it with the given origin.
+static inline __attribute__((__always_inline__)) int
+perf_event_entry(struct bpf_perf_event_data *ctx, TraceOrigin origin)
 {
   // Get the PID and TGID register.
   u64 id  = bpf_get_current_pid_tgid();
@@ -640,7 +671,36 @@ int native_tracer_entry(struct bpf_perf_event_data *ctx)
     return 0;
   }
 
+  // Bail out early for tasks outside of the profiled processes and cgroups to not
+  // pay for unwinding.
+  if (!pid_is_profiled(pid) || !cgroup_is_profiled()) {
+    return 0;
+  }
+
   u64 ts = bpf_ktime_get_ns();
-  return collect_trace((struct pt_regs *)&ctx->regs, TRACE_SAMPLING, pid, tid, ts, 0);
+  struct pt_regs *regs = (struct pt_regs *)&ctx->regs;
+  return collect_trace(regs, regs, origin, pid, tid, ts, NULL);
+}
+
+SEC("perf_event/native_tracer_entry")
+int native_tracer_entry(struct bpf_perf_event_data *ctx)
+{
+  return perf_event_entry(ctx, TRACE_SAMPLING);
 }
+
+// SOFTWARE_EVENT_ENTRY generates the entry program for a software perf event
+// whose traces are reported with the given origin.
+#define SOFTWARE_EVENT_ENTRY(event_name, origin)                                                   \
+  SEC("perf_event/native_tracer_entry_" #event_name)                                               \
+  int native_tracer_entry_##event_name(struct bpf_perf_event_data *ctx)                            \
+  {                                                                                                \
+    return perf_event_entry(ctx, origin);                                                          \
+  }
+
+SOFTWARE_EVENT_ENTRY(page_faults, TRACE_PAGE_FAULTS)
+SOFTWARE_EVENT_ENTRY(major_faults, TRACE_MAJOR_FAULTS)
+SOFTWARE_EVENT_ENTRY(minor_faults, TRACE_MINOR_FAULTS)
+SOFTWARE_EVENT_ENTRY(context_switches, TRACE_CONTEXT_SWITCHES)
+SOFTWARE_EVENT_ENTRY(cpu_migrations, TRACE_CPU_MIGRATIONS)
+
 MULTI_USE_FUNC(unwind_native)
@@ -10,17 +10,76 @@ bpf_map_def SEC("maps") kprobe_progs = {
   .max_entries = NUM_TRACER_PROGS,
 };
 
-// sched_times keeps track of sched_switch call times.
+// SchedTime records when and in which state a task was scheduled out.
+typedef struct SchedTime {
+  // ts is the time of the sched_switch call in ns.
+  u64 ts;
+  // task_state is the prev_state reported by the sched/sched_switch tracepoint.
+  u64 task_state;
+} SchedTime;
+
+// sched_times keeps track of sched_switch call times. It is shared by all CPUs, as
+// a task may be scheduled in on another CPU than it was scheduled out on.
 bpf_map_def SEC("maps") sched_times = {
-  .type        = BPF_MAP_TYPE_LRU_PERCPU_HASH,
+  .type        = BPF_MAP_TYPE_LRU_HASH,
   .key_size    = sizeof(u64), // pid_tgid
-  .value_size  = sizeof(u64), // time in ns
+  .value_size  = sizeof(SchedTime),
+  .max_entries = 256, // value is adjusted at load time in loadAllMaps.
+};
+
+// offcpu_tasks holds the PID of tasks whose off-CPU time is sampled by their TID,
+// until they are woken up.
+bpf_map_def SEC("maps") offcpu_tasks = {
+  .type        = BPF_MAP_TYPE_LRU_HASH,
+  .key_size    = sizeof(u32), // tid
+  .value_size  = sizeof(u32), // pid
   .max_entries = 256,         // value is adjusted at load time in loadAllMaps.
 };
 
+// wakeups holds the wakeup of off-CPU sampled tasks by their TID, until they are
+// scheduled again.
+bpf_map_def SEC("maps") wakeups = {
+  .type        = BPF_MAP_TYPE_LRU_HASH,
+  .key_size    = sizeof(u32), // tid
+  .value_size  = sizeof(WakeupLink),
+  .max_entries = 256, // value is adjusted at load time in loadAllMaps.
+};
+
+// offcpu_pids contains the PIDs whose off-CPU time is profiled, if
+// SystemConfig.off_cpu_filter_pids is set.
+bpf_map_def SEC("maps") offcpu_pids = {
+  .type        = BPF_MAP_TYPE_HASH,
+  .key_size    = sizeof(u32), // pid
+  .value_size  = sizeof(bool),
+  .max_entries = 1024,
+};
+
+// SchedSwitchArgs is the layout of the sched/sched_switch tracepoint arguments,
+// see /sys/kernel/tracing/events/sched/sched_switch/format.
+typedef struct SchedSwitchArgs {
+  u64 common;
+  char prev_comm[COMM_LEN];
+  s32 prev_pid;
+  s32 prev_prio;
+  s64 prev_state;
+  char next_comm[COMM_LEN];
+  s32 next_pid;
+  s32 next_prio;
+} SchedSwitchArgs;
+
+// SchedWakingArgs is the layout of the sched/sched_waking tracepoint arguments,
+// see /sys/kernel/tracing/events/sched/sched_waking/format.
+typedef struct SchedWakingArgs {
+  u64 common;
+  char comm[COMM_LEN];
+  s32 pid;
+  s32 prio;
+  s32 target_cpu;
+} SchedWakingArgs;
+
 // tracepoint__sched_switch serves as entry point for off cpu profiling.
 SEC("tracepoint/sched/sched_switch")
-int tracepoint__sched_switch(void *ctx)
+int tracepoint__sched_switch(SchedSwitchArgs *ctx)
 {
   u64 pid_tgid = bpf_get_current_pid_tgid();
   u32 pid      = pid_tgid >> 32;
@@ -41,16 +100,109 @@ int tracepoint__sched_switch(void *ctx)
     return 0;
   }
 
-  u64 ts = bpf_ktime_get_ns();
+  if (syscfg->off_cpu_filter_pids && !bpf_map_lookup_elem(&offcpu_pids, &pid)) {
+    return 0;
+  }
 
-  if (bpf_map_update_elem(&sched_times, &pid_tgid, &ts, BPF_ANY) < 0) {
+  if (!pid_is_profiled(pid) || !cgroup_is_profiled()) {
+    return 0;
+  }
+
+  SchedTime sched_time = {
+    .ts         = bpf_ktime_get_ns(),
+    .task_state = ctx->prev_state,
+  };
+
+  if (bpf_map_update_elem(&sched_times, &pid_tgid, &sched_time, BPF_ANY) < 0) {
     DEBUG_PRINT("Failed to record sched_switch event entry");
     return 0;
   }
 
+  if (syscfg->off_cpu_wakeups) {
+    // Remember the task to collect the stack of the task waking it up.
+    bpf_map_delete_elem(&wakeups, &tid);
+    if (bpf_map_update_elem(&offcpu_tasks, &tid, &pid, BPF_ANY) < 0) {
+      DEBUG_PRINT("Failed to record off-CPU task");
+    }
+  }
+
   return 0;
 }
 
+// on_task_stack returns true if the program runs on the kernel stack of the
+// current task. Interrupts, including softirqs, are handled on a separate per-CPU
+// stack, and the current task is unrelated to what they do.
+static inline __attribute__((__always_inline__)) bool on_task_stack(SystemConfig *syscfg)
+{
+  struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+  u64 stack_base;
+  if (bpf_probe_read_kernel(
+        &stack_base, sizeof(stack_base), (void *)((u64)task + syscfg->task_stack_offset))) {
+    return false;
+  }
+  // The eBPF stack is part of the kernel stack. The pt_regs of the task are at
+  // the top of the task stack.
+  u64 sp = (u64)&stack_base;
+  return sp >= stack_base && sp < stack_base + syscfg->stack_ptregs_offset;
+}
+
+// tracepoint__sched_waking collects the stack of tasks that wake up off-CPU sampled
+// tasks. It runs in the context of the waker.
+SEC("tracepoint/sched/sched_waking")
+int tracepoint__sched_waking(SchedWakingArgs *ctx)
+{
+  u64 pid_tgid = bpf_get_current_pid_tgid();
+  u32 pid      = pid_tgid >> 32;
+  u32 tid      = pid_tgid & 0xFFFFFFFF;
+
+  if (pid == 0 || tid == 0) {
+    return 0;
+  }
+
+  u32 key              = 0;
+  SystemConfig *syscfg = bpf_map_lookup_elem(&system_config, &key);
+  if (!syscfg) {
+    // Unreachable: array maps are always fully initialized.
+    return ERR_UNREACHABLE;
+  }
+
+  if (!on_task_stack(syscfg)) {
+    // Wakeups from interrupt handlers, e.g. timer or I/O completion, are not
+    // caused by the interrupted task.
+    return 0;
+  }
+
+  u32 wakee_tid  = ctx->pid;
+  u32 *wakee_pid = bpf_map_lookup_elem(&offcpu_tasks, &wakee_tid);
+  if (!wakee_pid) {
+    // The off-CPU time of the woken up task is not sampled.
+    return 0;
+  }
+
+  if (!pid_is_profiled(pid)) {
+    // Wakers outside of the profiled processes are not unwound.
+    return 0;
+  }
+
+  WakeupLink wakeup = {
+    .waker_pid = pid,
+    .waker_tid = tid,
+    .wakee_pid = *wakee_pid,
+    .wakee_tid = wakee_tid,
+  };
+  bpf_map_delete_elem(&offcpu_tasks, &wakee_tid);
+  if (bpf_map_update_elem(&wakeups, &wakee_tid, &wakeup, BPF_ANY) < 0) {
+    DEBUG_PRINT("Failed to record wakeup");
+    return 0;
+  }
+
+  u64 ts = bpf_ktime_get_ns();
+  DEBUG_PRINT("==== sched_waking ====");
+
+  OffCPUInfo info = {.wakeup = wakeup};
+  return collect_trace(ctx, NULL, TRACE_WAKEUP, pid, tid, ts, &info);
+}
+
 // dummy is never loaded or called. It just makes sure kprobe_progs is
 // referenced and make the compiler and linker happy.
 SEC("kprobe/dummy")
@@ -76,14 +228,50 @@ int finish_task_switch(struct pt_regs *ctx)
 
   u64 ts = bpf_ktime_get_ns();
 
-  u64 *start_ts = bpf_map_lookup_elem(&sched_times, &pid_tgid);
-  if (!start_ts || *start_ts == 0) {
+  SchedTime *sched_time_ptr = bpf_map_lookup_elem(&sched_times, &pid_tgid);
+  if (!sched_time_ptr) {
     // There is no information from the sched/sched_switch entry hook.
     return 0;
   }
+  // The entry belongs to this interval only. Left in place, the next interval
+  // of the task that was not sampled on switch-out would be measured from it.
+  SchedTime sched_time = *sched_time_ptr;
+  bpf_map_delete_elem(&sched_times, &pid_tgid);
+  if (sched_time.ts == 0) {
+    return 0;
+  }
+
+  OffCPUInfo info = {
+    .time       = ts - sched_time.ts,
+    .task_state = sched_time.task_state,
+  };
+
+  u32 key              = 0;
+  SystemConfig *syscfg = bpf_map_lookup_elem(&system_config, &key);
+  if (!syscfg) {
+    // Unreachable: array maps are always fully initialized.
+    return ERR_UNREACHABLE;
+  }
+
+  if (info.time < syscfg->off_cpu_min_duration) {
+    // The interval is too short to be of interest in the latency mode.
+    bpf_map_delete_elem(&offcpu_tasks, &tid);
+    bpf_map_delete_elem(&wakeups, &tid);
+    increment_metric(metricID_OffCPUDroppedShortIntervals);
+    return 0;
+  }
 
-  u64 diff = ts - *start_ts;
   DEBUG_PRINT("==== finish_task_switch ====");
 
-  return collect_trace(ctx, TRACE_OFF_CPU, pid, tid, ts, diff);
+  // The task is running again, so a later wakeup does not belong to this trace.
+  bpf_map_delete_elem(&offcpu_tasks, &tid);
+
+  // Link the trace to the wakeup trace of the waker, if it was collected.
+  WakeupLink *recorded_wakeup = bpf_map_lookup_elem(&wakeups, &tid);
+  if (recorded_wakeup) {
+    info.wakeup = *recorded_wakeup;
+    bpf_map_delete_elem(&wakeups, &tid);
+  }
+
+  return collect_trace(ctx, ctx, TRACE_OFF_CPU, pid, tid, ts, &info);
 }
@@ -0,0 +1,54 @@
+#include "bpfdefs.h"
+#include "tracemgmt.h"
+#include "types.h"
+
+// CLONE_THREAD is the clone flag for creating a thread in the same thread group.
+#define CLONE_THREAD 0x00010000
+
+// TaskNewtaskArgs is the layout of the task/task_newtask tracepoint arguments,
+// see /sys/kernel/tracing/events/task/task_newtask/format.
+typedef struct TaskNewtaskArgs {
+  u64 common;
+  s32 pid;
+  char comm[COMM_LEN];
+  u64 clone_flags;
+  s16 oom_score_adj;
+} TaskNewtaskArgs;
+
+// tracepoint__task_newtask adds the children of profiled processes to the
+// pid_filter map. It runs in the context of the parent.
+SEC("tracepoint/task/task_newtask")
+int tracepoint__task_newtask(TaskNewtaskArgs *ctx)
+{
+  u32 key              = 0;
+  SystemConfig *syscfg = bpf_map_lookup_elem(&system_config, &key);
+  if (!syscfg) {
+    // Unreachable: array maps are always fully initialized.
+    return ERR_UNREACHABLE;
+  }
+
+  if (!syscfg->filter_pids || !syscfg->follow_children) {
+    return 0;
+  }
+
+  u32 pid = bpf_get_current_pid_tgid() >> 32;
+  if (!bpf_map_lookup_elem(&pid_filter, &pid)) {
+    return 0;
+  }
+
+  if (ctx->clone_flags & CLONE_THREAD) {
+    // New threads belong to the thread group of the parent, which is in the
+    // map already.
+    return 0;
+  }
+
+  u32 child_pid = ctx->pid;
+  bool value    = true;
+  if (bpf_map_update_elem(&pid_filter, &child_pid, &value, BPF_ANY) < 0) {
+    DEBUG_PRINT("Failed to add child %d of PID %d to pid_filter", child_pid, pid);
+    return 0;
+  }
+
+  DEBUG_PRINT("Following child %d of PID %d", child_pid, pid);
+  return 0;
+}
@@ -11,6 +11,25 @@
 // option is to adjust this number downwards.
 #define FRAMES_PER_WALK_PYTHON_STACK 12
 
+// The co_flags bit of code objects of coroutines (async def functions).
+#define CO_COROUTINE 0x80
+
+// The offset of ob_type in PyObject, which is fixed before Python 3.13.
+#define PYOBJECT_OB_TYPE 8
+// The tp_flags bits of classes defined in Python code, and of metaclasses.
+#define PY_TPFLAGS_HEAPTYPE      (1UL << 9)
+#define PY_TPFLAGS_TYPE_SUBCLASS (1UL << 31)
+
+// The offset of the dynamic thread vector (DTV) pointer in the glibc thread
+// control block at the thread pointer.
+#if defined(__x86_64__)
+  #define TCB_DTV_OFFSET 8
+#elif defined(__aarch64__)
+  #define TCB_DTV_OFFSET 0
+#endif
+// The size of a DTV entry, which holds the TLS block of a module.
+#define DTV_ENTRY_SIZE 16
+
 // Forward declaration to avoid warnings like
 // "declaration of 'struct pt_regs' will not be visible outside of this function [-Wvisibility]".
 struct pt_regs;
@@ -36,6 +55,128 @@ static inline __attribute__((__always_inline__)) u64 py_encode_lineno(u32 object
   return (object_id | (((u64)f_lasti) << 32));
 }
 
+// Record the asyncio Task that steps the coroutines unwound so far. Coroutines
+// run by an asyncio Task are entered from the Handle._run frame of the event
+// loop, which holds the TaskStepMethWrapper of the Task as Handle._callback.
+// The Task is recorded as a pseudo frame in place of the tasks awaiting it,
+// which the user-land component reconstructs.
+static inline __attribute__((__always_inline__)) ErrorCode process_python_asyncio(
+  PerCPURecord *record,
+  const PyProcInfo *pyinfo,
+  const void *py_codeobject,
+  int py_flags,
+  bool entry)
+{
+  PythonUnwindState *state      = &record->pythonUnwindState;
+  PythonUnwindScratchSpace *pss = &record->pythonUnwindScratch;
+
+  if (py_flags & CO_COROUTINE) {
+    state->asyncio_pending = true;
+    return ERR_OK;
+  }
+  // Python 3.12+ enters the coroutine through a shim frame owned by the C stack.
+  if (!state->asyncio_pending || entry) {
+    return ERR_OK;
+  }
+  state->asyncio_pending = false;
+
+  if ((u64)py_codeobject != pyinfo->Handle_run_code) {
+    // The coroutine is not run by an asyncio Task, e.g. it is driven with
+    // coro.send() from an ordinary function, or Handle._run is not known yet.
+    return ERR_OK;
+  }
+
+  // The first local variable of Handle._run is 'self'.
+  void *handle = *(void **)(&pss->frame[pyinfo->PyFrameObject_localsplus]);
+  void *callback, *task;
+  if (
+    bpf_probe_read_user(&callback, sizeof(callback), handle + pyinfo->Handle_callback) ||
+    bpf_probe_read_user(&task, sizeof(task), callback + pyinfo->TaskStepMethWrapper_task)) {
+    DEBUG_PRINT("Failed to read asyncio task of handle 0x%lx", (unsigned long)handle);
+    return ERR_OK;
+  }
+
+  DEBUG_PRINT("Pushing Python asyncio task %lx", (unsigned long)task);
+  return push_python(&record->trace, (u64)task, PY_ASYNCIO_TASK_MARKER);
+}
+
+// Record the class of the first argument of a Python < 3.11 frame, whose code
+// object lacks the qualified name. For methods this is the class of 'self', or
+// 'cls' itself. It is recorded as a pseudo frame preceding the frame. The
+// user-land component decides whether the frame belongs to a method, and looks
+// up the class defining it in the method resolution order of this class.
+static inline __attribute__((__always_inline__)) ErrorCode
+process_python_class(Trace *trace, const PyProcInfo *pyinfo, const void *py_frameobject)
+{
+  void *arg, *class;
+  u64 flags;
+
+  // Leave room for the frame the class belongs to.
+  if (trace->stack_len >= MAX_NON_ERROR_FRAME_UNWINDS - 1) {
+    return ERR_OK;
+  }
+  if (
+    bpf_probe_read_user(&arg, sizeof(arg), py_frameobject + pyinfo->PyFrameObject_f_localsplus) ||
+    bpf_probe_read_user(&class, sizeof(class), arg + PYOBJECT_OB_TYPE) ||
+    bpf_probe_read_user(&flags, sizeof(flags), class + pyinfo->PyTypeObject_tp_flags)) {
+    return ERR_OK;
+  }
+  if (flags & PY_TPFLAGS_TYPE_SUBCLASS) {
+    // The argument is a class.
+    class = arg;
+    if (bpf_probe_read_user(&flags, sizeof(flags), class + pyinfo->PyTypeObject_tp_flags)) {
+      return ERR_OK;
+    }
+  }
+  if (!(flags & PY_TPFLAGS_HEAPTYPE)) {
+    // Arguments of builtin types do not belong to methods defined in Python.
+    return ERR_OK;
+  }
+
+  DEBUG_PRINT("Pushing Python class %lx", (unsigned long)class);
+  return push_python(trace, (u64)class, PY_CLASS_MARKER);
+}
+
+// Record the running greenlet. Greenlet keeps the running greenlet of a thread in
+// its thread state, whose pointer is a thread local variable of the greenlet
+// extension module. The variable is found in the TLS block of the module, which
+// glibc allocates on demand and tracks in the dynamic thread vector (DTV).
+static inline __attribute__((__always_inline__)) ErrorCode
+process_python_greenlet(Trace *trace, const PyProcInfo *pyinfo)
+{
+  void *tsd_base, *dtv, *block, *state, *greenlet;
+  u64 dtv_len;
+
+  if (tsd_get_base(&tsd_base)) {
+    return ERR_OK;
+  }
+  if (
+    bpf_probe_read_user(&dtv, sizeof(dtv), tsd_base + TCB_DTV_OFFSET) ||
+    bpf_probe_read_user(&dtv_len, sizeof(dtv_len), dtv - DTV_ENTRY_SIZE)) {
+    return ERR_OK;
+  }
+  if (pyinfo->greenlet_tls_module > dtv_len) {
+    // The thread was created before the module was loaded.
+    return ERR_OK;
+  }
+  void *entry = dtv + pyinfo->greenlet_tls_module * DTV_ENTRY_SIZE;
+  if (
+    bpf_probe_read_user(&block, sizeof(block), entry) || block == (void *)-1 ||
+    bpf_probe_read_user(&state, sizeof(state), block + pyinfo->greenlet_tls_offset)) {
+    return ERR_OK;
+  }
+  // The thread state is 1 until the thread used greenlets, and 0 once it exited.
+  if (
+    (u64)state <= 1 ||
+    bpf_probe_read_user(&greenlet, sizeof(greenlet), state + pyinfo->greenlet_current) ||
+    !greenlet) {
+    return ERR_OK;
+  }
+
+  DEBUG_PRINT("Pushing Python greenlet %lx", (unsigned long)greenlet);
+  return push_python(trace, (u64)greenlet, PY_GREENLET_MARKER);
+}
+
 static inline __attribute__((__always_inline__)) ErrorCode process_python_frame(
   PerCPURecord *record,
   const PyProcInfo *pyinfo,
@@ -57,7 +198,8 @@ static inline __attribute__((__always_inline__)) ErrorCode process_python_frame(
     pyinfo->PyFrameObject_f_code > sizeof(pss->frame) - sizeof(void *) ||
     pyinfo->PyFrameObject_f_back > sizeof(pss->frame) - sizeof(void *) ||
     pyinfo->PyFrameObject_f_lasti > sizeof(pss->frame) - sizeof(u64) ||
-    pyinfo->PyFrameObject_entry_member > sizeof(pss->frame) - sizeof(u8)) {
+    pyinfo->PyFrameObject_entry_member > sizeof(pss->frame) - sizeof(u8) ||
+    pyinfo->PyFrameObject_localsplus > sizeof(pss->frame) - sizeof(void *)) {
     return ERR_UNREACHABLE;
   }
 
@@ -142,6 +284,20 @@ static inline __attribute__((__always_inline__)) ErrorCode process_python_frame(
   codeobject_id =
     (py_argcount << 25) + (py_kwonlyargcount << 18) + (py_flags << 10) + py_firstlineno;
 
+  if (pyinfo->PyFrameObject_localsplus) {
+    ErrorCode error =
+      process_python_asyncio(record, pyinfo, py_codeobject, py_flags, *continue_with_next);
+    if (error) {
+      return error;
+    }
+  }
+  if (pyinfo->PyFrameObject_f_localsplus && py_argcount > 0) {
+    ErrorCode error = process_python_class(trace, pyinfo, py_frameobject);
+    if (error) {
+      return error;
+    }
+  }
+
   file_id = (u64)py_codeobject;
   lineno  = py_encode_lineno(codeobject_id, (u32)py_f_lasti);
 
@@ -167,6 +323,10 @@ walk_python_stack(PerCPURecord *record, const PyProcInfo *pyinfo, int *unwinder)
   for (u32 i = 0; i < FRAMES_PER_WALK_PYTHON_STACK; ++i) {
     bool continue_with_next;
     error = process_python_frame(record, pyinfo, &py_frame, &continue_with_next);
+    if (!error && !py_frame && pyinfo->greenlet_tls_module) {
+      // The outermost frame of the chain belongs to the running greenlet.
+      error = process_python_greenlet(&record->trace, pyinfo);
+    }
     if (error) {
       goto stop;
     }
@@ -24,6 +24,8 @@
 #define UNWIND_COMMAND_PLT     2
 // Unwind a signal frame
 #define UNWIND_COMMAND_SIGNAL  3
+// Unwind a frame using the frame pointer chain
+#define UNWIND_COMMAND_FRAME_POINTER 4
 
 // If opcode has UNWIND_OPCODEF_DEREF set, the lowest bits of 'param' are used
 // as second adder as post-deref operation. This contains the mask for that.
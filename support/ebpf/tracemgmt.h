@@ -9,7 +9,7 @@
 #include "frametypes.h"
 #include "types.h"
 
-// MULTI_USE_FUNC generates perf event and kprobe eBPF programs
+// MULTI_USE_FUNC generates perf event, kprobe and tracepoint eBPF programs
 // for a given function.
 #define MULTI_USE_FUNC(func_name)                                                                  \
   SEC("perf_event/" #func_name)                                                                    \
@@ -20,6 +20,12 @@
                                                                                                    \
   SEC("kprobe/" #func_name)                                                                        \
   int kprobe_##func_name(struct pt_regs *ctx)                                                      \
+  {                                                                                                \
+    return func_name(ctx);                                                                         \
+  }                                                                                                \
+                                                                                                   \
+  SEC("tracepoint/" #func_name)                                                                    \
+  int tracepoint_##func_name(void *ctx)                                                            \
   {                                                                                                \
     return func_name(ctx);                                                                         \
   }
@@ -84,6 +90,32 @@ static inline __attribute__((__always_inline__)) bool pid_information_exists(voi
   return bpf_map_lookup_elem(&pid_page_to_mapping_info, &key) != NULL;
 }
 
+// cgroup_is_profiled checks if the current task belongs to a cgroup that is to be profiled.
+static inline __attribute__((__always_inline__)) bool cgroup_is_profiled(void)
+{
+  u32 key              = 0;
+  SystemConfig *syscfg = bpf_map_lookup_elem(&system_config, &key);
+  if (!syscfg || !syscfg->filter_cgroups) {
+    return true;
+  }
+
+  u64 cgroup_id = bpf_get_current_cgroup_id();
+  return bpf_map_lookup_elem(&cgroup_filter, &cgroup_id) != NULL;
+}
+
+// pid_is_profiled checks if the process pid is one of the processes profiling is
+// restricted to.
+static inline __attribute__((__always_inline__)) bool pid_is_profiled(u32 pid)
+{
+  u32 key              = 0;
+  SystemConfig *syscfg = bpf_map_lookup_elem(&system_config, &key);
+  if (!syscfg || !syscfg->filter_pids) {
+    return true;
+  }
+
+  return bpf_map_lookup_elem(&pid_filter, &pid) != NULL;
+}
+
 // Reset the ratelimit cache
 #define RATELIMIT_ACTION_RESET   0
 // Use default timer
@@ -217,18 +249,19 @@ static inline PerCPURecord *get_pristine_per_cpu_record()
   record->state.r22        = 0;
   record->state.lr_invalid = false;
 #endif
-  record->state.return_address             = false;
-  record->state.error_metric               = -1;
-  record->state.unwind_error               = ERR_OK;
-  record->perlUnwindState.stackinfo        = 0;
-  record->perlUnwindState.cop              = 0;
-  record->pythonUnwindState.py_frame       = 0;
-  record->phpUnwindState.zend_execute_data = 0;
-  record->rubyUnwindState.stack_ptr        = 0;
-  record->rubyUnwindState.last_stack_frame = 0;
-  record->unwindersDone                    = 0;
-  record->tailCalls                        = 0;
-  record->ratelimitAction                  = RATELIMIT_ACTION_DEFAULT;
+  record->state.return_address              = false;
+  record->state.error_metric                = -1;
+  record->state.unwind_error                = ERR_OK;
+  record->perlUnwindState.stackinfo         = 0;
+  record->perlUnwindState.cop               = 0;
+  record->pythonUnwindState.py_frame        = 0;
+  record->pythonUnwindState.asyncio_pending = false;
+  record->phpUnwindState.zend_execute_data  = 0;
+  record->rubyUnwindState.stack_ptr         = 0;
+  record->rubyUnwindState.last_stack_frame  = 0;
+  record->unwindersDone                     = 0;
+  record->tailCalls                         = 0;
+  record->ratelimitAction                   = RATELIMIT_ACTION_DEFAULT;
 
   Trace *trace           = &record->trace;
   trace->kernel_stack_id = -1;
@@ -346,6 +379,13 @@ static inline __attribute__((__always_inline__)) void send_trace(void *ctx, Trac
     return; // unreachable
   }
 
+  // Move the tail behind the last used frame, as the unused frames are not sent.
+  u32 stack_len = trace->stack_len;
+  if (stack_len < MAX_FRAME_UNWINDS) {
+    TraceTail tail                          = trace->tail;
+    *(TraceTail *)&trace->frames[stack_len] = tail;
+  }
+
   bpf_perf_event_output(ctx, &trace_events, BPF_F_CURRENT_CPU, trace, send_size);
 }
 
@@ -620,7 +660,8 @@ static inline bool ptregs_is_usermode(struct pt_regs *regs)
 }
 
 // Extract the usermode pt_regs for current task. Use context given pt_regs
-// if it is usermode regs, or resolve it via struct task_struct.
+// if it is usermode regs, or resolve it via struct task_struct. ctx is NULL
+// for contexts that do not provide registers, e.g. tracepoints.
 //
 // State registers are not touched (get_pristine_per_cpu_record already reset it)
 // if something fails. has_usermode_regs is set to true if a user-mode register
@@ -631,7 +672,7 @@ get_usermode_regs(struct pt_regs *ctx, UnwindState *state, bool *has_usermode_re
 {
   ErrorCode error;
 
-  if (!ptregs_is_usermode(ctx)) {
+  if (!ctx || !ptregs_is_usermode(ctx)) {
     u32 key              = 0;
     SystemConfig *syscfg = bpf_map_lookup_elem(&system_config, &key);
     if (!syscfg) {
@@ -680,8 +721,18 @@ get_usermode_regs(struct pt_regs *ctx, UnwindState *state, bool *has_usermode_re
 
 #endif // TESTING_COREDUMP
 
-static inline int collect_trace(
-  struct pt_regs *ctx, TraceOrigin origin, u32 pid, u32 tid, u64 trace_timestamp, u64 off_cpu_time)
+// collect_trace starts unwinding the stack of the current task. regs holds the
+// registers at the event, or NULL if the eBPF context does not provide them.
+// off_cpu holds the details of off-CPU and wakeup traces and is NULL otherwise.
+// It takes more arguments than eBPF functions can, so it must be inlined.
+static inline __attribute__((__always_inline__)) int collect_trace(
+  void *ctx,
+  struct pt_regs *regs,
+  TraceOrigin origin,
+  u32 pid,
+  u32 tid,
+  u64 trace_timestamp,
+  const OffCPUInfo *off_cpu)
 {
   // The trace is reused on each call to this function so we have to reset the
   // variables used to maintain state.
@@ -696,7 +747,14 @@ static inline int collect_trace(
   trace->pid     = pid;
   trace->tid     = tid;
   trace->ktime   = trace_timestamp;
-  trace->offtime = off_cpu_time;
+  if (off_cpu) {
+    trace->offtime         = off_cpu->time;
+    trace->tail.task_state = off_cpu->task_state;
+    trace->tail.wakeup     = off_cpu->wakeup;
+  } else {
+    trace->offtime = 0;
+    trace->tail    = (TraceTail){0};
+  }
   if (bpf_get_current_comm(&(trace->comm), sizeof(trace->comm)) < 0) {
     increment_metric(metricID_ErrBPFCurrentComm);
   }
@@ -708,7 +766,7 @@ static inline int collect_trace(
   // Recursive unwind frames
   int unwinder           = PROG_UNWIND_STOP;
   bool has_usermode_regs = false;
-  ErrorCode error        = get_usermode_regs(ctx, &record->state, &has_usermode_regs);
+  ErrorCode error        = get_usermode_regs(regs, &record->state, &has_usermode_regs);
   if (error || !has_usermode_regs) {
     goto exit;
   }
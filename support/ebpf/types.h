@@ -301,6 +301,9 @@ enum {
   // number of failures to unwind code object due to its large size
   metricID_UnwindDotnetErrCodeTooLarge,
 
+  // number of off-CPU intervals dropped for being shorter than the minimum duration
+  metricID_OffCPUDroppedShortIntervals,
+
   //
   // Metric IDs above are for counters (cumulative values)
   //
@@ -337,6 +340,21 @@ typedef enum TraceOrigin {
   TRACE_UNKNOWN,
   TRACE_SAMPLING,
   TRACE_OFF_CPU,
+  TRACE_PROBE,
+  // Software perf events.
+  TRACE_PAGE_FAULTS,
+  TRACE_MAJOR_FAULTS,
+  TRACE_MINOR_FAULTS,
+  TRACE_CONTEXT_SWITCHES,
+  TRACE_CPU_MIGRATIONS,
+  // TRACE_WAKEUP is the origin of traces of tasks waking up off-CPU sampled tasks.
+  TRACE_WAKEUP,
+  // New fixed origins are added above this line.
+
+  // TRACE_KERNEL_EVENT is the origin of the first kernel event trigger. The
+  // following triggers use consecutive origins. It starts a separate range so
+  // that adding fixed origins does not renumber the kernel event origins.
+  TRACE_KERNEL_EVENT = 0x100,
 } TraceOrigin;
 
 // OFF_CPU_THRESHOLD_MAX defines the maximum threshold.
@@ -417,8 +435,35 @@ typedef struct PyProcInfo {
   u8 PyCodeObject_co_argcount, PyCodeObject_co_kwonlyargcount;
   u8 PyCodeObject_co_flags, PyCodeObject_co_firstlineno;
   u8 PyCodeObject_sizeof;
+  // Offsets to find the running asyncio Task from the Handle._run frame of the
+  // event loop. PyFrameObject_localsplus is zero if asyncio support is disabled.
+  u8 PyFrameObject_localsplus, Handle_callback, TaskStepMethWrapper_task;
+  // The address of the code object of Handle._run, or zero until user space
+  // found it.
+  u64 Handle_run_code;
+  // Offsets to find the class of the first argument of a Python < 3.11 frame.
+  // PyFrameObject_f_localsplus is zero if this is disabled.
+  u16 PyFrameObject_f_localsplus;
+  u8 PyTypeObject_tp_flags;
+  // The glibc TLS module ID and the offset in its TLS block of greenlet's thread
+  // state pointer, and the offset of the running greenlet in the thread state.
+  // greenlet_tls_module is zero if greenlet support is disabled.
+  u64 greenlet_tls_module, greenlet_tls_offset;
+  u8 greenlet_current;
 } PyProcInfo;
 
+// PY_ASYNCIO_TASK_MARKER is the line number of the pseudo Python frame that
+// carries the address of the running asyncio Task as its file.
+#define PY_ASYNCIO_TASK_MARKER 0x8000000000000000ULL
+
+// PY_CLASS_MARKER is the line number of the pseudo Python frame that carries
+// the class of the first argument of the following Python frame as its file.
+#define PY_CLASS_MARKER 0x4000000000000000ULL
+
+// PY_GREENLET_MARKER is the line number of the pseudo Python frame that carries
+// the running greenlet object as its file.
+#define PY_GREENLET_MARKER 0x2000000000000000ULL
+
 // PHPProcInfo is a container for the data needed to build a stack trace for a PHP process.
 typedef struct PHPProcInfo {
   u64 current_execute_data;
@@ -524,6 +569,36 @@ typedef struct __attribute__((packed)) ApmCorrelationBuf {
   ApmSpanID transaction_id;
 } ApmCorrelationBuf;
 
+// WakeupLink identifies the task that woke up another task, and the woken up
+// task. For off-CPU traces, the wakee is the traced task. For wakeup traces,
+// the waker is the traced task. All fields are zero if the waker is unknown.
+typedef struct WakeupLink {
+  u32 waker_pid;
+  u32 waker_tid;
+  u32 wakee_pid;
+  u32 wakee_tid;
+} WakeupLink;
+
+// OffCPUInfo holds the details of off-CPU and wakeup traces.
+typedef struct OffCPUInfo {
+  // time is the number of nanoseconds the task was off-CPU.
+  u64 time;
+  // task_state is the state of the task when it was scheduled out.
+  u64 task_state;
+  // wakeup links the trace to the wakeup of the task.
+  WakeupLink wakeup;
+} OffCPUInfo;
+
+// TraceTail holds the members of Trace that follow its frames.
+typedef struct TraceTail {
+  // task_state stores the state of the task when it was scheduled out, as
+  // reported by the sched/sched_switch tracepoint, for off-CPU traces.
+  u64 task_state;
+
+  // wakeup links off-CPU traces to the wakeup trace of the task that woke them up.
+  WakeupLink wakeup;
+} TraceTail;
+
 // Container for a stack trace
 typedef struct Trace {
   // The process ID
@@ -554,9 +629,11 @@ typedef struct Trace {
   // The frames of the stack trace.
   Frame frames[MAX_FRAME_UNWINDS];
 
-  // NOTE: both send_trace in BPF and loadBpfTrace in UM code require `frames`
-  // to be the last item in the struct. Do not add new members here without also
-  // adjusting the UM code.
+  // tail holds the members added after the frames.
+  // NOTE: send_trace in BPF does not send the unused frames and moves the tail
+  // right behind the last used frame, where loadBpfTrace in UM code reads it.
+  // New members are added to TraceTail.
+  TraceTail tail;
 } Trace;
 
 // Container for unwinding state
@@ -616,12 +693,13 @@ typedef struct PerlUnwindState {
   const void *cxbase, *cxcur;
 } PerlUnwindState;
 
-// Container for unwinding state needed by the Python unwinder. At the moment
-// the only thing we need to pass between invocations of the unwinding programs
-// is the pointer to the next PyFrameObject to unwind.
+// Container for unwinding state needed by the Python unwinder.
 typedef struct PythonUnwindState {
   // Pointer to the next PyFrameObject to unwind
   void *py_frame;
+  // Set if the last unwound frame was a coroutine whose asyncio Task is not
+  // reported yet.
+  bool asyncio_pending;
 } PythonUnwindState;
 
 // Container for unwinding state needed by the PHP unwinder. At the moment
@@ -808,6 +886,10 @@ typedef struct PIDPage {
 // BIT_WIDTH_PAGE defines the number of bits used in the value page of the PIDPage struct.
 #define BIT_WIDTH_PAGE 64
 
+// FRAME_POINTER_FILE_ID is the file ID of pid_page_to_mapping_info entries for JIT code
+// without stack deltas. The native unwinder unwinds these frames with frame pointers.
+#define FRAME_POINTER_FILE_ID 0x4a49545f46505f00ULL
+
 // Constants for accessing bitfields within HotSpot text_section_offset/file_id.
 #define HS_TSID_IS_STUB_BIT       63
 #define HS_TSID_HAS_FRAME_BIT     62
@@ -875,6 +957,24 @@ typedef struct SystemConfig {
 
   // Enables the temporary hack that drops pure errors frames in unwind_stop.
   bool drop_error_only_traces;
+
+  // Restricts profiling to the cgroups in the `cgroup_filter` map.
+  bool filter_cgroups;
+
+  // Enables collecting the stacks of tasks that wake up off-CPU sampled tasks.
+  bool off_cpu_wakeups;
+
+  // Restricts off-CPU profiling to the PIDs in the `offcpu_pids` map.
+  bool off_cpu_filter_pids;
+
+  // Restricts profiling to the PIDs in the `pid_filter` map.
+  bool filter_pids;
+
+  // Adds the children of processes in the `pid_filter` map to it when they are forked.
+  bool follow_children;
+
+  // Minimum duration in ns of off-CPU intervals to be recorded, 0 to record all.
+  u64 off_cpu_min_duration;
 } SystemConfig;
 
 // Avoid including all of arch/arm64/include/uapi/asm/ptrace.h by copying the
@@ -0,0 +1,29 @@
+// This file contains the code for collecting stack traces when user space
+// probes (uprobes and USDT probes) are hit.
+
+#include "bpfdefs.h"
+#include "tracemgmt.h"
+#include "types.h"
+
+// uprobe__generic serves as entry point for probe triggered stack collection.
+// It is attached to all configured uprobe and USDT locations.
+SEC("uprobe/generic")
+int uprobe__generic(struct pt_regs *ctx)
+{
+  u64 pid_tgid = bpf_get_current_pid_tgid();
+  u32 pid      = pid_tgid >> 32;
+  u32 tid      = pid_tgid & 0xFFFFFFFF;
+
+  if (pid == 0 || tid == 0) {
+    return 0;
+  }
+
+  if (!pid_is_profiled(pid) || !cgroup_is_profiled()) {
+    return 0;
+  }
+
+  u64 ts = bpf_ktime_get_ns();
+  DEBUG_PRINT("==== uprobe__generic ====");
+
+  return collect_trace(ctx, ctx, TRACE_PROBE, pid, tid, ts, NULL);
+}
@@ -20,6 +20,7 @@ const (
 	FrameMarkerV8       = 0x8
 	FrameMarkerDotnet   = 0xa
 	FrameMarkerGo       = 0xb
+	FrameMarkerJIT      = 0xc
 	FrameMarkerAbort    = 0xff
 )
 
@@ -38,6 +39,8 @@ const (
 const (
 	DeltaCommandFlag = 0x8000
 
+	FramePointerFileID = 0x4a49545f46505f00
+
 	MergeOpcodeNegative = 0x80
 )
 
@@ -79,9 +82,17 @@ const (
 )
 
 const (
-	TraceOriginUnknown  = 0x0
-	TraceOriginSampling = 0x1
-	TraceOriginOffCPU   = 0x2
+	TraceOriginUnknown         = 0x0
+	TraceOriginSampling        = 0x1
+	TraceOriginOffCPU          = 0x2
+	TraceOriginProbe           = 0x3
+	TraceOriginPageFaults      = 0x4
+	TraceOriginMajorFaults     = 0x5
+	TraceOriginMinorFaults     = 0x6
+	TraceOriginContextSwitches = 0x7
+	TraceOriginCPUMigrations   = 0x8
+	TraceOriginWakeup          = 0x9
+	TraceOriginKernelEvent     = 0x100
 )
 
 const OffCPUThresholdMax = 0x3e8
@@ -25,6 +25,7 @@ const (
 	FrameMarkerV8       = C.FRAME_MARKER_V8
 	FrameMarkerDotnet   = C.FRAME_MARKER_DOTNET
 	FrameMarkerGo       = C.FRAME_MARKER_GO
+	FrameMarkerJIT      = C.FRAME_MARKER_JIT
 	FrameMarkerAbort    = C.FRAME_MARKER_ABORT
 )
 
@@ -43,6 +44,8 @@ const (
 const (
 	DeltaCommandFlag = C.STACK_DELTA_COMMAND_FLAG
 
+	FramePointerFileID = C.FRAME_POINTER_FILE_ID
+
 	MergeOpcodeNegative = C.MERGEOPCODE_NEGATIVE
 )
 
@@ -88,9 +91,17 @@ const (
 )
 
 const (
-	TraceOriginUnknown  = C.TRACE_UNKNOWN
-	TraceOriginSampling = C.TRACE_SAMPLING
-	TraceOriginOffCPU   = C.TRACE_OFF_CPU
+	TraceOriginUnknown         = C.TRACE_UNKNOWN
+	TraceOriginSampling        = C.TRACE_SAMPLING
+	TraceOriginOffCPU          = C.TRACE_OFF_CPU
+	TraceOriginProbe           = C.TRACE_PROBE
+	TraceOriginPageFaults      = C.TRACE_PAGE_FAULTS
+	TraceOriginMajorFaults     = C.TRACE_MAJOR_FAULTS
+	TraceOriginMinorFaults     = C.TRACE_MINOR_FAULTS
+	TraceOriginContextSwitches = C.TRACE_CONTEXT_SWITCHES
+	TraceOriginCPUMigrations   = C.TRACE_CPU_MIGRATIONS
+	TraceOriginWakeup          = C.TRACE_WAKEUP
+	TraceOriginKernelEvent     = C.TRACE_KERNEL_EVENT
 )
 
 const OffCPUThresholdMax = C.OFF_CPU_THRESHOLD_MAX
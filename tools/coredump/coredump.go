@@ -15,6 +15,7 @@ import (
 
 	cebpf "github.com/cilium/ebpf"
 
+	"go.opentelemetry.io/ebpf-profiler/interpreter/python"
 	"go.opentelemetry.io/ebpf-profiler/libpf"
 	"go.opentelemetry.io/ebpf-profiler/libpf/xsync"
 	"go.opentelemetry.io/ebpf-profiler/nativeunwind/elfunwindinfo"
@@ -188,9 +189,9 @@ func ExtractTraces(ctx context.Context, pr process.Process, debug bool,
 	// Instantiate managers and enable all tracers by default
 	includeTracers, _ := tracertypes.Parse("all")
 
-	manager, err := pm.New(todo, includeTracers, monitorInterval, &coredumpEbpfMaps,
-		pm.NewMapFileIDMapper(), symCache, elfunwindinfo.NewStackDeltaProvider(), false,
-		libpf.Set[string]{})
+	manager, err := pm.New(todo, includeTracers, python.Options{}, monitorInterval,
+		&coredumpEbpfMaps, pm.NewMapFileIDMapper(), symCache,
+		elfunwindinfo.NewStackDeltaProvider(), false, libpf.Set[string]{}, false, nil, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Interpreter manager: %v", err)
 	}
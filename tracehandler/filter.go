@@ -0,0 +1,199 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracehandler // import "go.opentelemetry.io/ebpf-profiler/tracehandler"
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	lru "github.com/elastic/go-freelru"
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/host"
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+)
+
+// Keys that can be used in filter rules.
+const (
+	// FilterKeyPID matches the process ID.
+	FilterKeyPID = "pid"
+	// FilterKeyComm matches the thread name.
+	FilterKeyComm = "comm"
+	// FilterKeyProcess matches the process name.
+	FilterKeyProcess = "process"
+	// FilterKeyExecutable matches the path of the main executable.
+	FilterKeyExecutable = "exe"
+	// FilterKeyCgroup matches the cgroupv2 path.
+	FilterKeyCgroup = "cgroup"
+	// FilterKeyContainer matches the container ID derived from the cgroupv2 path.
+	FilterKeyContainer = "container"
+)
+
+// containerIDPattern extracts a container ID from a cgroupv2 path, as used by
+// Docker, containerd and CRI-O.
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// filterRule matches a single attribute of a trace against a glob pattern.
+type filterRule struct {
+	key     string
+	pattern *regexp.Regexp
+}
+
+// Filter decides which traces are kept, based on include and exclude rules.
+//
+// A trace is kept if it matches at least one include rule, or if there are no
+// include rules, and if it matches none of the exclude rules.
+type Filter struct {
+	include []filterRule
+	exclude []filterRule
+
+	// cgroupv2Path caches PID to cgroupv2 path information. It is only set if
+	// any of the rules match on the cgroupv2 path.
+	cgroupv2Path *lru.SyncedLRU[libpf.PID, string]
+}
+
+// NewFilter creates a Filter from comma separated lists of include and
+// exclude rules. A rule has the form key=pattern, where key is one of the
+// FilterKey* constants and pattern may use '*' to match any sequence of
+// characters and '?' to match a single character. For example,
+// "comm=kworker*" or "exe=/opt/app/*". If both lists are empty, nil is
+// returned, which keeps all traces.
+func NewFilter(include, exclude string) (*Filter, error) {
+	f := &Filter{}
+
+	var err error
+	if f.include, err = parseFilterRules(include); err != nil {
+		return nil, fmt.Errorf("invalid include rules: %v", err)
+	}
+	if f.exclude, err = parseFilterRules(exclude); err != nil {
+		return nil, fmt.Errorf("invalid exclude rules: %v", err)
+	}
+	if len(f.include) == 0 && len(f.exclude) == 0 {
+		return nil, nil
+	}
+
+	if needsCgroupv2(f.include) || needsCgroupv2(f.exclude) {
+		f.cgroupv2Path, err = lru.NewSynced[libpf.PID, string](1024,
+			func(pid libpf.PID) uint32 { return uint32(pid) })
+		if err != nil {
+			return nil, err
+		}
+		// Set a lifetime to reduce the risk of invalid data in case of PID reuse.
+		f.cgroupv2Path.SetLifetime(90 * time.Second)
+	}
+
+	return f, nil
+}
+
+// parseFilterRules parses a comma separated list of rules.
+func parseFilterRules(rules string) ([]filterRule, error) {
+	var parsed []filterRule
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		key, pattern, found := strings.Cut(rule, "=")
+		if !found {
+			return nil, fmt.Errorf("rule '%s' is not of the form key=pattern", rule)
+		}
+		switch key {
+		case FilterKeyPID, FilterKeyComm, FilterKeyProcess, FilterKeyExecutable,
+			FilterKeyCgroup, FilterKeyContainer:
+		default:
+			return nil, fmt.Errorf("unknown key '%s' in rule '%s'", key, rule)
+		}
+
+		parsed = append(parsed, filterRule{
+			key:     key,
+			pattern: globToRegexp(pattern),
+		})
+	}
+	return parsed, nil
+}
+
+// needsCgroupv2 returns true if any of rules matches on the cgroupv2 path.
+func needsCgroupv2(rules []filterRule) bool {
+	for _, r := range rules {
+		if r.key == FilterKeyCgroup || r.key == FilterKeyContainer {
+			return true
+		}
+	}
+	return false
+}
+
+// globToRegexp converts a glob pattern into an anchored regular expression.
+func globToRegexp(glob string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i, part := range strings.Split(glob, "*") {
+		if i > 0 {
+			sb.WriteString(".*")
+		}
+		sb.WriteString(strings.ReplaceAll(regexp.QuoteMeta(part), `\?`, "."))
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// Keep returns true if trace passes the filter. A nil Filter keeps all traces.
+func (f *Filter) Keep(trace *host.Trace) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.include) > 0 && !f.matchesAny(f.include, trace) {
+		return false
+	}
+	return !f.matchesAny(f.exclude, trace)
+}
+
+// matchesAny returns true if trace matches any of rules.
+func (f *Filter) matchesAny(rules []filterRule, trace *host.Trace) bool {
+	for _, r := range rules {
+		if r.pattern.MatchString(f.value(r.key, trace)) {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the value of the attribute key of trace.
+func (f *Filter) value(key string, trace *host.Trace) string {
+	switch key {
+	case FilterKeyPID:
+		return strconv.FormatUint(uint64(trace.PID), 10)
+	case FilterKeyComm:
+		return trace.Comm
+	case FilterKeyProcess:
+		return trace.ProcessName
+	case FilterKeyExecutable:
+		return trace.ExecutablePath
+	case FilterKeyCgroup:
+		return f.cgroupv2(trace.PID)
+	case FilterKeyContainer:
+		return containerIDPattern.FindString(f.cgroupv2(trace.PID))
+	}
+	return ""
+}
+
+// cgroupv2 returns the cgroupv2 path of pid.
+func (f *Filter) cgroupv2(pid libpf.PID) string {
+	path, err := libpf.LookupCgroupv2(f.cgroupv2Path, pid)
+	if err != nil {
+		log.Debugf("Failed to get the cgroupv2 path for PID %d: %v", pid, err)
+	}
+	return path
+}
+
+// PurgeExpired removes expired entries from the internal caches.
+func (f *Filter) PurgeExpired() {
+	if f != nil && f.cgroupv2Path != nil {
+		f.cgroupv2Path.PurgeExpired()
+	}
+}
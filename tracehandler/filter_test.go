@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracehandler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/host"
+)
+
+func TestFilter(t *testing.T) {
+	app := &host.Trace{
+		PID:            1000,
+		Comm:           "worker-1",
+		ProcessName:    "app",
+		ExecutablePath: "/opt/app/bin/app",
+	}
+	kworker := &host.Trace{
+		PID:  2,
+		Comm: "kworker/0:1",
+	}
+
+	tests := map[string]struct {
+		include string
+		exclude string
+		keep    []bool
+	}{
+		"no rules": {
+			keep: []bool{true, true},
+		},
+		"exclude comm": {
+			exclude: "comm=kworker*",
+			keep:    []bool{true, false},
+		},
+		"include executable": {
+			include: "exe=/opt/app/*",
+			keep:    []bool{true, false},
+		},
+		"include pid": {
+			include: "pid=2",
+			keep:    []bool{false, true},
+		},
+		"include any of": {
+			include: "process=app, comm=kworker*",
+			keep:    []bool{true, true},
+		},
+		"exclude wins": {
+			include: "exe=/opt/*",
+			exclude: "comm=worker-?",
+			keep:    []bool{false, false},
+		},
+		"single character": {
+			include: "comm=worker-??",
+			keep:    []bool{false, false},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewFilter(tc.include, tc.exclude)
+			require.NoError(t, err)
+			assert.Equal(t, tc.keep[0], f.Keep(app))
+			assert.Equal(t, tc.keep[1], f.Keep(kworker))
+		})
+	}
+}
+
+func TestNewFilterInvalid(t *testing.T) {
+	for _, rules := range []string{"comm", "host=foo"} {
+		_, err := NewFilter(rules, "")
+		require.Error(t, err, rules)
+		_, err = NewFilter("", rules)
+		require.Error(t, err, rules)
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	re := globToRegexp("*.slice/docker-*.scope")
+	assert.True(t, re.MatchString("/system.slice/docker-abc.scope"))
+	assert.False(t, re.MatchString("/system.slice/docker-abc.scope/x"))
+	assert.False(t, globToRegexp("a.b").MatchString("axb"))
+}
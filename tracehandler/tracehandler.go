@@ -61,6 +61,7 @@ type traceHandler struct {
 	// Metrics
 	traceCacheHit  uint64
 	traceCacheMiss uint64
+	traceFiltered  uint64
 
 	traceProcessor TraceProcessor
 
@@ -72,12 +73,36 @@ type traceHandler struct {
 	// reporter instance to use to send out traces.
 	reporter reporter.TraceReporter
 
+	// filter drops traces before they are symbolized. May be nil.
+	filter *Filter
+
 	times Times
 }
 
+// Option configures optional behavior of the trace handler.
+type Option func(*traceHandler)
+
+// WithFilter drops traces that do not pass filter before they are symbolized.
+func WithFilter(filter *Filter) Option {
+	return func(m *traceHandler) {
+		m.filter = filter
+	}
+}
+
 // newTraceHandler creates a new traceHandler
 func newTraceHandler(ctx context.Context, rep reporter.TraceReporter,
-	traceProcessor TraceProcessor, intervals Times, cacheSize uint32) (*traceHandler, error) {
+	traceProcessor TraceProcessor, intervals Times, cacheSize uint32,
+	opts ...Option) (*traceHandler, error) {
+	m := &traceHandler{
+		traceProcessor: traceProcessor,
+		reporter:       rep,
+		times:          intervals,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	filter := m.filter
+
 	traceCache, err := lru.NewSynced[host.TraceHash, libpf.Trace](
 		cacheSize, func(k host.TraceHash) uint32 { return uint32(k) })
 	if err != nil {
@@ -100,6 +125,7 @@ func newTraceHandler(ctx context.Context, rep reporter.TraceReporter,
 				return
 			case <-ticker.C:
 				traceCache.PurgeExpired()
+				filter.PurgeExpired()
 			}
 		}
 	}()
@@ -107,27 +133,36 @@ func newTraceHandler(ctx context.Context, rep reporter.TraceReporter,
 	// Wait to make sure the purge routine did start.
 	wg.Wait()
 
-	return &traceHandler{
-		traceProcessor: traceProcessor,
-		traceCache:     traceCache,
-		reporter:       rep,
-		times:          intervals,
-	}, nil
+	m.traceCache = traceCache
+	return m, nil
 }
 
 func (m *traceHandler) HandleTrace(bpfTrace *host.Trace) {
+	if !m.filter.Keep(bpfTrace) {
+		m.traceFiltered++
+		return
+	}
+
 	meta := &samples.TraceEventMeta{
-		Timestamp:      libpf.UnixTime64(bpfTrace.KTime.UnixNano()),
-		Comm:           bpfTrace.Comm,
-		PID:            bpfTrace.PID,
-		TID:            bpfTrace.TID,
-		APMServiceName: "", // filled in below
-		CPU:            bpfTrace.CPU,
-		ProcessName:    bpfTrace.ProcessName,
-		ExecutablePath: bpfTrace.ExecutablePath,
-		Origin:         bpfTrace.Origin,
-		OffTime:        bpfTrace.OffTime,
-		EnvVars:        bpfTrace.EnvVars,
+		Timestamp:        libpf.UnixTime64(bpfTrace.KTime.UnixNano()),
+		Comm:             bpfTrace.Comm,
+		PID:              bpfTrace.PID,
+		TID:              bpfTrace.TID,
+		APMServiceName:   "", // filled in below
+		CPU:              bpfTrace.CPU,
+		ProcessName:      bpfTrace.ProcessName,
+		ExecutablePath:   bpfTrace.ExecutablePath,
+		Origin:           bpfTrace.Origin,
+		OffTime:          bpfTrace.OffTime,
+		EnvVars:          bpfTrace.EnvVars,
+		CommandLine:      bpfTrace.CommandLine,
+		ParentPID:        bpfTrace.ParentPID,
+		StartTime:        bpfTrace.StartTime,
+		Ancestors:        bpfTrace.Ancestors,
+		Wakeup:           bpfTrace.Wakeup,
+		TaskState:        bpfTrace.TaskState,
+		BlockingReason:   bpfTrace.BlockingReason,
+		SamplesPerSecond: bpfTrace.SamplesPerSecond,
 	}
 
 	if trace, exists := m.traceCache.GetAndRefresh(bpfTrace.Hash,
@@ -144,7 +179,9 @@ func (m *traceHandler) HandleTrace(bpfTrace *host.Trace) {
 
 	// Slow path: convert trace.
 	umTrace := m.traceProcessor.ConvertTrace(bpfTrace)
-	m.traceCache.Add(bpfTrace.Hash, *umTrace)
+	if !umTrace.Dynamic {
+		m.traceCache.Add(bpfTrace.Hash, *umTrace)
+	}
 
 	meta.APMServiceName = m.traceProcessor.MaybeNotifyAPMAgent(bpfTrace, umTrace.Hash, 1)
 	if err := m.reporter.ReportTraceEvent(umTrace, meta); err != nil {
@@ -157,10 +194,10 @@ func (m *traceHandler) HandleTrace(bpfTrace *host.Trace) {
 // The returned channel allows the caller to wait for the background worker
 // to exit after a cancellation through the context.
 func Start(ctx context.Context, rep reporter.TraceReporter, traceProcessor TraceProcessor,
-	traceInChan <-chan *host.Trace, intervals Times, cacheSize uint32,
+	traceInChan <-chan *host.Trace, intervals Times, cacheSize uint32, opts ...Option,
 ) (workerExited <-chan libpf.Void, err error) {
 	handler, err :=
-		newTraceHandler(ctx, rep, traceProcessor, intervals, cacheSize)
+		newTraceHandler(ctx, rep, traceProcessor, intervals, cacheSize, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create traceHandler: %v", err)
 	}
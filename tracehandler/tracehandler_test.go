@@ -29,14 +29,21 @@ func defaultTimes() *fakeTimes {
 func (ft *fakeTimes) MonitorInterval() time.Duration { return ft.monitorInterval }
 
 // fakeTraceProcessor implements a fake TraceProcessor used only within the test scope.
-type fakeTraceProcessor struct{}
+type fakeTraceProcessor struct {
+	// dynamic marks the converted traces as dynamic.
+	dynamic bool
+	// conversions counts the calls to ConvertTrace.
+	conversions int
+}
 
 // Compile time check to make sure fakeTraceProcessor satisfies the interfaces.
 var _ tracehandler.TraceProcessor = (*fakeTraceProcessor)(nil)
 
 func (f *fakeTraceProcessor) ConvertTrace(trace *host.Trace) *libpf.Trace {
+	f.conversions++
 	var newTrace libpf.Trace
 	newTrace.Hash = libpf.NewTraceHash(uint64(trace.Hash), uint64(trace.Hash))
+	newTrace.Dynamic = f.dynamic
 	return &newTrace
 }
 
@@ -126,3 +133,31 @@ func TestTraceHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTraceHandlerDynamicTrace(t *testing.T) {
+	for _, dynamic := range []bool{false, true} {
+		r := &mockReporter{
+			t:       t,
+			reports: make(map[libpf.TraceHash]uint16),
+		}
+		processor := &fakeTraceProcessor{dynamic: dynamic}
+
+		traceChan := make(chan *host.Trace)
+		ctx, cancel := context.WithCancel(context.Background())
+		exitNotify, err := tracehandler.Start(ctx, r, processor,
+			traceChan, defaultTimes(), 128)
+		require.NoError(t, err)
+
+		traceChan <- &host.Trace{Hash: host.TraceHash(4)}
+		traceChan <- &host.Trace{Hash: host.TraceHash(4)}
+		cancel()
+		<-exitNotify
+
+		require.Equal(t, uint16(2), r.reports[libpf.NewTraceHash(4, 4)])
+		if dynamic {
+			require.Equal(t, 2, processor.conversions, "dynamic traces are not cached")
+		} else {
+			require.Equal(t, 1, processor.conversions)
+		}
+	}
+}
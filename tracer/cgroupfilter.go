@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer // import "go.opentelemetry.io/ebpf-profiler/tracer"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	cebpf "github.com/cilium/ebpf"
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+)
+
+// updateCgroupFilter synchronizes the eBPF map cgroup_filter with the IDs of
+// the configured cgroups and their descendants. Cgroups created after the
+// last update are not profiled until the next update.
+func (t *Tracer) updateCgroupFilter() error {
+	ids := make(libpf.Set[uint64])
+	for _, path := range t.cgroups {
+		pathIDs, err := libpf.Cgroupv2IDs(path)
+		if err != nil {
+			// The cgroup may not exist yet, or no longer.
+			log.Debugf("Failed to resolve cgroup %s: %v", path, err)
+			continue
+		}
+		for _, id := range pathIDs {
+			ids[id] = libpf.Void{}
+		}
+	}
+
+	cgroupFilterMap := t.ebpfMaps["cgroup_filter"]
+	var errs []error
+
+	for id := range t.cgroupIDs {
+		if _, ok := ids[id]; ok {
+			continue
+		}
+		if err := cgroupFilterMap.Delete(unsafe.Pointer(&id)); err != nil &&
+			!errors.Is(err, cebpf.ErrKeyNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove cgroup ID %d: %v", id, err))
+			continue
+		}
+		delete(t.cgroupIDs, id)
+	}
+
+	value := true
+	for id := range ids {
+		if _, ok := t.cgroupIDs[id]; ok {
+			continue
+		}
+		if err := cgroupFilterMap.Update(unsafe.Pointer(&id), unsafe.Pointer(&value),
+			cebpf.UpdateAny); err != nil {
+			errs = append(errs, fmt.Errorf("failed to add cgroup ID %d: %v", id, err))
+			continue
+		}
+		t.cgroupIDs[id] = libpf.Void{}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,186 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer // import "go.opentelemetry.io/ebpf-profiler/tracer"
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	cebpf "github.com/cilium/ebpf"
+	"github.com/elastic/go-perf"
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/metrics"
+)
+
+// attachCPU opens the sampling perf event and the software perf events on the
+// CPU and attaches their entry programs. The perf events are added to events.
+// The caller must hold the perfEntrypoints lock.
+func (t *Tracer) attachCPU(events *[]*perf.Event, cpu int) error {
+	tracerProg, ok := t.ebpfProgs["native_tracer_entry"]
+	if !ok {
+		return errors.New("entry program is not available")
+	}
+
+	perfAttribute := new(perf.Attr)
+	perfAttribute.SetSampleFreq(uint64(t.samplesPerSecond.Load()))
+	// The enabled time tells whether the event still runs, see cpuEventStopped.
+	perfAttribute.CountFormat = perf.CountFormat{Enabled: true}
+	if err := perf.CPUClock.Configure(perfAttribute); err != nil {
+		return fmt.Errorf("failed to configure software perf event: %v", err)
+	}
+
+	samplingEvent, err := openPerfEvent(perfAttribute, cpu, tracerProg)
+	if err != nil {
+		return fmt.Errorf("failed to attach to perf event on CPU %d: %v", cpu, err)
+	}
+	cpuEvents := []*perf.Event{samplingEvent}
+
+	for _, spec := range t.softwareEvents {
+		event := softwareEvents[spec.Name]
+		prog, ok := t.ebpfProgs[event.progName]
+		if !ok {
+			closePerfEvents(cpuEvents)
+			return fmt.Errorf("entry program %s is not available", event.progName)
+		}
+
+		perfAttribute := new(perf.Attr)
+		perfAttribute.SetSamplePeriod(spec.Period)
+		if err := event.counter.Configure(perfAttribute); err != nil {
+			closePerfEvents(cpuEvents)
+			return fmt.Errorf("failed to configure software perf event %s: %v",
+				spec.Name, err)
+		}
+
+		perfEvent, err := openPerfEvent(perfAttribute, cpu, prog)
+		if err != nil {
+			closePerfEvents(cpuEvents)
+			return fmt.Errorf("failed to attach to %s perf event on CPU %d: %v",
+				spec.Name, cpu, err)
+		}
+		cpuEvents = append(cpuEvents, perfEvent)
+	}
+
+	if t.perfEventsDisabled {
+		// Probabilistic profiling disabled sampling for the current interval.
+		for _, event := range cpuEvents {
+			if err := event.Disable(); err != nil {
+				log.Errorf("Failed to disable perf event on CPU %d: %v", cpu, err)
+			}
+		}
+	}
+
+	*events = append(*events, cpuEvents...)
+	t.samplingEvents = append(t.samplingEvents, samplingEvent)
+	t.cpuEvents[cpu] = cpuEvents
+	t.cpuEnabledTimes[cpu] = 0
+	return nil
+}
+
+// detachCPU closes the perf events of the CPU and removes them from events.
+// The caller must hold the perfEntrypoints lock.
+func (t *Tracer) detachCPU(events *[]*perf.Event, cpu int) {
+	cpuEvents := t.cpuEvents[cpu]
+	closePerfEvents(cpuEvents)
+
+	isCPUEvent := func(event *perf.Event) bool {
+		return slices.Contains(cpuEvents, event)
+	}
+	*events = slices.DeleteFunc(*events, isCPUEvent)
+	t.samplingEvents = slices.DeleteFunc(t.samplingEvents, isCPUEvent)
+	delete(t.cpuEvents, cpu)
+	delete(t.cpuEnabledTimes, cpu)
+}
+
+// cpuEventStopped reports whether the sampling perf event of the CPU stopped
+// running since the last update. The kernel stops the perf events of a CPU
+// when it goes offline and does not restart them when it comes back online,
+// which may happen between two updates. The time an event was enabled does not
+// advance while it is stopped. The caller must hold the perfEntrypoints lock.
+func (t *Tracer) cpuEventStopped(cpu int) bool {
+	count, err := t.cpuEvents[cpu][0].ReadCount()
+	if err != nil {
+		log.Warnf("Failed to read perf event on CPU %d: %v", cpu, err)
+		return true
+	}
+	last := t.cpuEnabledTimes[cpu]
+	t.cpuEnabledTimes[cpu] = count.Enabled
+	if t.perfEventsDisabled {
+		// Probabilistic profiling disabled the event on purpose.
+		return false
+	}
+	return count.Enabled <= last
+}
+
+// updateOnlineCPUs attaches perf events to the CPUs that were brought online and
+// detaches them from the CPUs that went offline since the last update. The perf
+// events of CPUs that went offline and came back online in the meantime are
+// opened again. The off-CPU, probe and kernel event hooks are not bound to CPUs
+// and need no update.
+func (t *Tracer) updateOnlineCPUs() error {
+	onlineCPUIDs, err := getOnlineCPUIDs()
+	if err != nil {
+		return fmt.Errorf("failed to get online CPUs: %v", err)
+	}
+
+	events := t.perfEntrypoints.WLock()
+	defer t.perfEntrypoints.WUnlock(&events)
+	if t.cpuEvents == nil {
+		// The tracer is not attached, or closed already.
+		return nil
+	}
+
+	online := make(libpf.Set[int], len(onlineCPUIDs))
+	var errs []error
+	for _, cpu := range onlineCPUIDs {
+		online[cpu] = libpf.Void{}
+		if _, ok := t.cpuEvents[cpu]; ok {
+			if !t.cpuEventStopped(cpu) {
+				continue
+			}
+			t.detachCPU(events, cpu)
+			log.Infof("Perf events of CPU %d stopped, opening them again", cpu)
+		}
+		if err := t.attachCPU(events, cpu); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Infof("Attached perf events to CPU %d", cpu)
+	}
+
+	for cpu := range t.cpuEvents {
+		if _, ok := online[cpu]; ok {
+			continue
+		}
+		t.detachCPU(events, cpu)
+		log.Infof("Detached perf events from offline CPU %d", cpu)
+	}
+
+	metrics.Add(metrics.IDSampledCPUs, metrics.MetricValue(len(t.cpuEvents)))
+	return errors.Join(errs...)
+}
+
+// openPerfEvent opens the perf event on the CPU and attaches the eBPF program.
+func openPerfEvent(attr *perf.Attr, cpu int, prog *cebpf.Program) (*perf.Event, error) {
+	event, err := perf.Open(attr, perf.AllThreads, cpu, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := event.SetBPF(uint32(prog.FD())); err != nil {
+		_ = event.Close()
+		return nil, fmt.Errorf("failed to attach eBPF program to perf event: %v", err)
+	}
+	return event, nil
+}
+
+// closePerfEvents closes the perf events and logs failures.
+func closePerfEvents(events []*perf.Event) {
+	for _, event := range events {
+		if err := event.Close(); err != nil {
+			log.Errorf("Failed to close perf event: %v", err)
+		}
+	}
+}
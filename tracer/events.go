@@ -136,8 +136,10 @@ func startPerfEventMonitor(ctx context.Context, perfEventMap *ebpf.Map,
 func (t *Tracer) startTraceEventMonitor(ctx context.Context,
 	traceOutChan chan<- *host.Trace) func() []metrics.Metric {
 	eventsMap := t.ebpfMaps["trace_events"]
+	// Size the buffer for the highest sampling frequency, as the frequency can
+	// be raised at runtime.
 	eventReader, err := perf.NewReader(eventsMap,
-		t.samplesPerSecond*int(unsafe.Sizeof(C.Trace{})))
+		t.maxSamplesPerSecond*int(unsafe.Sizeof(C.Trace{})))
 	if err != nil {
 		log.Fatalf("Failed to setup perf reporting via %s: %v", eventsMap, err)
 	}
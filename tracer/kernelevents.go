@@ -0,0 +1,146 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer // import "go.opentelemetry.io/ebpf-profiler/tracer"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/link"
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+// KernelEventSpec describes a kernel tracepoint or kprobe that triggers the
+// collection of a stack trace whenever it is hit.
+type KernelEventSpec struct {
+	// Group is the tracepoint group, e.g. "syscalls". It is empty for kprobes.
+	Group string
+	// Name is the name of the tracepoint, or the kernel function for kprobes.
+	Name string
+	// Ratio defines the sampling ratio: a stack trace is collected for one
+	// out of Ratio events on average.
+	Ratio uint32
+}
+
+func (e KernelEventSpec) String() string {
+	if e.Group == "" {
+		return e.Name
+	}
+	return e.Group + ":" + e.Name
+}
+
+// ParseKernelEventSpecs parses a comma separated list of kernel events. The
+// format is <group>:<name> for tracepoints, e.g. syscalls:sys_enter_write, and
+// <function> for kprobes, e.g. tcp_retransmit_skb. An optional @<N> suffix
+// samples one out of N events, e.g. block:block_rq_issue@10. Each event may
+// only be given once.
+func ParseKernelEventSpecs(specs string) ([]KernelEventSpec, error) {
+	var events []KernelEventSpec
+	seen := make(libpf.Set[string])
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		event := KernelEventSpec{Ratio: 1}
+		if name, ratio, found := strings.Cut(spec, "@"); found {
+			n, err := strconv.ParseUint(ratio, 10, 32)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid sampling ratio in kernel event %q", spec)
+			}
+			event.Ratio = uint32(n)
+			spec = name
+		}
+
+		parts := strings.Split(spec, ":")
+		for _, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("invalid kernel event %q: empty field", spec)
+			}
+		}
+		switch len(parts) {
+		case 1:
+			event.Name = parts[0]
+		case 2:
+			event.Group, event.Name = parts[0], parts[1]
+		default:
+			return nil, fmt.Errorf("invalid kernel event %q: expected group:name or "+
+				"function", spec)
+		}
+		if _, dup := seen[event.String()]; dup {
+			return nil, fmt.Errorf("duplicate kernel event %q", spec)
+		}
+		seen[event.String()] = libpf.Void{}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// KernelEventOrigins returns the trace origins of the kernel events. The
+// kernel event at index i reports its traces with the origin
+// TraceOriginKernelEvent+i.
+func KernelEventOrigins(events []KernelEventSpec) []samples.EventOrigin {
+	origins := make([]samples.EventOrigin, 0, len(events))
+	for i, event := range events {
+		name := event.Name
+		if event.Group != "" {
+			name = event.Group + "_" + event.Name
+		}
+		origins = append(origins, samples.EventOrigin{
+			Origin:     libpf.Origin(support.TraceOriginKernelEvent + i),
+			Name:       name,
+			SampleType: event.String(),
+			SampleUnit: "count",
+		})
+	}
+	return origins
+}
+
+// StartKernelEventProfiling attaches the kprobe__generic and tracepoint__generic
+// programs to the configured kernel events. The trace origin and the sampling
+// ratio are passed to the eBPF programs with the attach cookie, which requires
+// Linux 5.15 or newer.
+func (t *Tracer) StartKernelEventProfiling() error {
+	for i, event := range t.kernelEvents {
+		cookie := uint64(event.Ratio)<<32 | uint64(support.TraceOriginKernelEvent+i)
+
+		hook := hookPoint{group: "kprobe", name: event.String()}
+		progName := "kprobe__generic"
+		if event.Group != "" {
+			hook.group = "tracepoint"
+			progName = "tracepoint__generic"
+		}
+		prog, ok := t.ebpfProgs[progName]
+		if !ok {
+			return fmt.Errorf("kernel event program %s is not available", progName)
+		}
+
+		var eventLink link.Link
+		var err error
+		if event.Group != "" {
+			eventLink, err = link.Tracepoint(event.Group, event.Name, prog,
+				&link.TracepointOptions{Cookie: cookie})
+		} else {
+			eventLink, err = link.Kprobe(event.Name, prog,
+				&link.KprobeOptions{Cookie: cookie})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to attach to kernel event %s: %v", event, err)
+		}
+		if _, exists := t.hooks[hook]; exists {
+			// Do not leak the link, the hook key would overwrite it.
+			_ = eventLink.Close()
+			return fmt.Errorf("kernel event %s is attached more than once", event)
+		}
+		t.hooks[hook] = eventLink
+	}
+	log.Debugf("Attached to %d kernel events", len(t.kernelEvents))
+	return nil
+}
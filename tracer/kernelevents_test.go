@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+func TestParseKernelEventSpecs(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected []KernelEventSpec
+		wantErr  bool
+	}{
+		"empty": {
+			input: "",
+		},
+		"tracepoints and kprobes": {
+			input: "syscalls:sys_enter_write, block:block_rq_issue@10,tcp_retransmit_skb",
+			expected: []KernelEventSpec{
+				{Group: "syscalls", Name: "sys_enter_write", Ratio: 1},
+				{Group: "block", Name: "block_rq_issue", Ratio: 10},
+				{Name: "tcp_retransmit_skb", Ratio: 1},
+			},
+		},
+		"zero ratio": {
+			input:   "tcp_retransmit_skb@0",
+			wantErr: true,
+		},
+		"invalid ratio": {
+			input:   "tcp_retransmit_skb@x",
+			wantErr: true,
+		},
+		"empty field": {
+			input:   "syscalls:",
+			wantErr: true,
+		},
+		"too many fields": {
+			input:   "a:b:c",
+			wantErr: true,
+		},
+		"duplicate event": {
+			input:   "tcp_retransmit_skb,tcp_retransmit_skb@10",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseKernelEventSpecs(tc.input)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestKernelEventOrigins(t *testing.T) {
+	origins := KernelEventOrigins([]KernelEventSpec{
+		{Group: "syscalls", Name: "sys_enter_write", Ratio: 1},
+		{Name: "tcp_retransmit_skb", Ratio: 5},
+	})
+	require.Equal(t, []samples.EventOrigin{
+		{
+			Origin:     libpf.Origin(support.TraceOriginKernelEvent),
+			Name:       "syscalls_sys_enter_write",
+			SampleType: "syscalls:sys_enter_write",
+			SampleUnit: "count",
+		},
+		{
+			Origin:     libpf.Origin(support.TraceOriginKernelEvent + 1),
+			Name:       "tcp_retransmit_skb",
+			SampleType: "tcp_retransmit_skb",
+			SampleUnit: "count",
+		},
+	}, origins)
+}
@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer // import "go.opentelemetry.io/ebpf-profiler/tracer"
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// Blocking reasons of off-CPU samples, derived from the kernel stack.
+const (
+	BlockingReasonFutex   = "futex"
+	BlockingReasonEpoll   = "epoll"
+	BlockingReasonBlockIO = "block_io"
+	BlockingReasonNetwork = "network"
+	BlockingReasonSleep   = "sleep"
+)
+
+// blockingReasonCacheSize is the number of kernel addresses whose blocking
+// reason is cached.
+const blockingReasonCacheSize = 4096
+
+// blockingFunctions maps kernel functions that put a task to sleep to the
+// blocking reason they indicate.
+var blockingFunctions = map[string]string{
+	"futex_wait_queue":            BlockingReasonFutex,
+	"futex_wait_queue_me":         BlockingReasonFutex,
+	"futex_wait_multiple":         BlockingReasonFutex,
+	"futex_lock_pi":               BlockingReasonFutex,
+	"ep_poll":                     BlockingReasonEpoll,
+	"io_schedule":                 BlockingReasonBlockIO,
+	"io_schedule_timeout":         BlockingReasonBlockIO,
+	"bit_wait_io":                 BlockingReasonBlockIO,
+	"submit_bio_wait":             BlockingReasonBlockIO,
+	"blk_mq_get_tag":              BlockingReasonBlockIO,
+	"folio_wait_bit_common":       BlockingReasonBlockIO,
+	"wait_on_page_bit_common":     BlockingReasonBlockIO,
+	"__wait_on_buffer":            BlockingReasonBlockIO,
+	"sk_wait_data":                BlockingReasonNetwork,
+	"sk_stream_wait_memory":       BlockingReasonNetwork,
+	"sk_stream_wait_connect":      BlockingReasonNetwork,
+	"__skb_wait_for_more_packets": BlockingReasonNetwork,
+	"inet_csk_wait_for_connect":   BlockingReasonNetwork,
+	"inet_wait_for_connect":       BlockingReasonNetwork,
+	"unix_stream_data_wait":       BlockingReasonNetwork,
+	"unix_wait_for_peer":          BlockingReasonNetwork,
+	"do_nanosleep":                BlockingReasonSleep,
+	"msleep":                      BlockingReasonSleep,
+	"msleep_interruptible":        BlockingReasonSleep,
+}
+
+// blockingReason returns the blocking reason indicated by the kernel function,
+// or an empty string if the function does not indicate one.
+func blockingReason(function string) string {
+	// Strip compiler generated suffixes like .isra.0 or .constprop.0.
+	function, _, _ = strings.Cut(function, ".")
+	return blockingFunctions[function]
+}
+
+// taskStateNames holds the names of the task states reported by the
+// sched/sched_switch tracepoint, indexed by the bit position of the state.
+var taskStateNames = []string{
+	"interruptible",
+	"uninterruptible",
+	"stopped",
+	"traced",
+	"dead",
+	"zombie",
+	"parked",
+	"idle",
+}
+
+// taskStatePreempted is set in the prev_state of the sched/sched_switch
+// tracepoint if the task was preempted (TASK_REPORT_MAX).
+const taskStatePreempted = 0x100
+
+// taskStateName returns the name of the prev_state reported by the
+// sched/sched_switch tracepoint, as encoded by Linux 4.14 and newer.
+func taskStateName(state uint64) string {
+	if state&taskStatePreempted != 0 {
+		return "preempted"
+	}
+	if state == 0 {
+		return "running"
+	}
+	idx := bits.TrailingZeros64(state)
+	if idx >= len(taskStateNames) {
+		return ""
+	}
+	return taskStateNames[idx]
+}
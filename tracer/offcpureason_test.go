@@ -0,0 +1,48 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockingReason(t *testing.T) {
+	tests := map[string]string{
+		"futex_wait_queue":             BlockingReasonFutex,
+		"futex_wait_queue.constprop.0": BlockingReasonFutex,
+		"ep_poll":                      BlockingReasonEpoll,
+		"io_schedule":                  BlockingReasonBlockIO,
+		"folio_wait_bit_common":        BlockingReasonBlockIO,
+		"sk_wait_data":                 BlockingReasonNetwork,
+		"unix_stream_data_wait":        BlockingReasonNetwork,
+		"do_nanosleep":                 BlockingReasonSleep,
+		"schedule":                     "",
+		"schedule_timeout":             "",
+		"tcp_recvmsg_locked":           "",
+		"udp_sendmsg":                  "",
+		"inet_sendmsg":                 "",
+	}
+	for function, expected := range tests {
+		t.Run(function, func(t *testing.T) {
+			assert.Equal(t, expected, blockingReason(function))
+		})
+	}
+}
+
+func TestTaskStateName(t *testing.T) {
+	tests := map[uint64]string{
+		0x0:   "running",
+		0x1:   "interruptible",
+		0x2:   "uninterruptible",
+		0x4:   "stopped",
+		0x80:  "idle",
+		0x100: "preempted",
+		0x200: "",
+	}
+	for state, expected := range tests {
+		assert.Equal(t, expected, taskStateName(state), "state 0x%x", state)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer // import "go.opentelemetry.io/ebpf-profiler/tracer"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	cebpf "github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/proc"
+)
+
+// loadPIDFilter stores the PIDs profiling is restricted to in the eBPF map
+// pid_filter. If followChildren is set, the existing descendants of these
+// processes are added as well and the fork tracepoint keeps adding new ones.
+func (t *Tracer) loadPIDFilter(pids []libpf.PID, followChildren bool) error {
+	filter := make(libpf.Set[libpf.PID], len(pids))
+	for _, pid := range pids {
+		filter[pid] = libpf.Void{}
+	}
+
+	if followChildren {
+		// Attach the fork tracepoint before scanning /proc so that no child
+		// created in the meantime is missed.
+		prog, ok := t.ebpfProgs["tracepoint__task_newtask"]
+		if !ok {
+			return errors.New("program tracepoint__task_newtask is not available")
+		}
+		forkLink, err := link.Tracepoint("task", "task_newtask", prog, nil)
+		if err != nil {
+			return fmt.Errorf("failed to attach to task/task_newtask: %v", err)
+		}
+		t.hooks[hookPoint{group: "task", name: "task_newtask"}] = forkLink
+
+		if err = addDescendants(filter); err != nil {
+			return fmt.Errorf("failed to find child processes: %v", err)
+		}
+	}
+
+	pidFilterMap := t.ebpfMaps["pid_filter"]
+	value := true
+	for pid := range filter {
+		key := uint32(pid)
+		if err := pidFilterMap.Update(unsafe.Pointer(&key), unsafe.Pointer(&value),
+			cebpf.UpdateAny); err != nil {
+			return fmt.Errorf("failed to add PID %d: %v", pid, err)
+		}
+	}
+	return nil
+}
+
+// addDescendants adds the running descendants of the processes in pids to it.
+func addDescendants(pids libpf.Set[libpf.PID]) error {
+	allPIDs, err := proc.ListPIDs()
+	if err != nil {
+		return err
+	}
+	parents := make(map[libpf.PID]libpf.PID, len(allPIDs))
+	for _, pid := range allPIDs {
+		stat, err := proc.GetProcessStat(pid)
+		if err != nil {
+			// The process exited in the meantime.
+			continue
+		}
+		parents[pid] = stat.PPID
+	}
+
+	// Walk up the process tree of each process until a profiled process or
+	// the root is reached.
+	for pid := range parents {
+		var chain []libpf.PID
+		for p := pid; p != 0; p = parents[p] {
+			if _, ok := pids[p]; ok {
+				for _, c := range chain {
+					pids[c] = libpf.Void{}
+				}
+				break
+			}
+			if len(chain) > len(parents) {
+				// Guard against cycles caused by PID reuse while scanning.
+				break
+			}
+			chain = append(chain, p)
+		}
+	}
+	return nil
+}
+
+// updatePIDFilter removes exited processes from the eBPF map pid_filter. Once
+// no process is left, the channel returned by TargetsExited is closed.
+func (t *Tracer) updatePIDFilter() error {
+	pidFilterMap := t.ebpfMaps["pid_filter"]
+
+	var exited []uint32
+	var key uint32
+	var value bool
+	remaining := 0
+	it := pidFilterMap.Iterate()
+	for it.Next(&key, &value) {
+		if processExited(libpf.PID(key)) {
+			exited = append(exited, key)
+			continue
+		}
+		remaining++
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("failed to iterate pid_filter: %v", err)
+	}
+
+	var errs []error
+	for _, pid := range exited {
+		if err := pidFilterMap.Delete(unsafe.Pointer(&pid)); err != nil &&
+			!errors.Is(err, cebpf.ErrKeyNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove PID %d: %v", pid, err))
+		}
+	}
+
+	if remaining == 0 {
+		select {
+		case <-t.targetsExited:
+		default:
+			log.Info("All profiled processes exited")
+			close(t.targetsExited)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// processExited reports whether all threads of the process exited. The thread
+// group leader stays a zombie until the other threads exited, so its state
+// alone does not tell whether the process still runs.
+func processExited(pid libpf.PID) bool {
+	stat, err := proc.GetProcessStat(pid)
+	if err != nil {
+		return true
+	}
+	if stat.State != 'Z' {
+		return false
+	}
+	tids, err := proc.ListTIDs(pid)
+	if err != nil {
+		return true
+	}
+	// Exited threads other than the leader are removed immediately.
+	for _, tid := range tids {
+		if tid != pid {
+			return false
+		}
+	}
+	return true
+}
+
+// TargetsExited returns a channel that is closed once all processes profiling
+// is restricted to have exited.
+func (t *Tracer) TargetsExited() <-chan libpf.Void {
+	return t.targetsExited
+}
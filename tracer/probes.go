@@ -0,0 +1,183 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer // import "go.opentelemetry.io/ebpf-profiler/tracer"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf/link"
+	log "github.com/sirupsen/logrus"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/libpf/pfelf"
+)
+
+// ProbeSpec describes a user space location that triggers the collection of a
+// stack trace whenever it is hit.
+type ProbeSpec struct {
+	// Binary is the path of the executable or shared library.
+	Binary string
+	// Symbol is the function to attach a uprobe to. It is empty for USDT probes.
+	Symbol string
+	// Provider and Name identify a USDT probe.
+	Provider string
+	Name     string
+}
+
+func (p ProbeSpec) String() string {
+	if p.Symbol != "" {
+		return p.Binary + ":" + p.Symbol
+	}
+	return p.Binary + ":" + p.Provider + ":" + p.Name
+}
+
+// ParseProbeSpecs parses a comma separated list of probe specifications. The
+// format is <binary>:<symbol> for uprobes, e.g. /usr/lib/libc.so.6:malloc,
+// and <binary>:<provider>:<probe> for USDT probes.
+func ParseProbeSpecs(specs string) ([]ProbeSpec, error) {
+	var probes []ProbeSpec
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		parts := strings.Split(spec, ":")
+		for _, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("invalid probe %q: empty field", spec)
+			}
+		}
+		switch len(parts) {
+		case 2:
+			probes = append(probes, ProbeSpec{Binary: parts[0], Symbol: parts[1]})
+		case 3:
+			probes = append(probes, ProbeSpec{Binary: parts[0], Provider: parts[1],
+				Name: parts[2]})
+		default:
+			return nil, fmt.Errorf("invalid probe %q: expected binary:symbol or "+
+				"binary:provider:probe", spec)
+		}
+	}
+	return probes, nil
+}
+
+// probeLocation is a resolved location of a probe in its binary.
+type probeLocation struct {
+	// offset is the file offset of the probed instruction.
+	offset uint64
+	// refCtrOffset is the file offset of the USDT semaphore, or 0.
+	refCtrOffset uint64
+}
+
+// resolveProbe returns the locations of the probe in its binary. USDT probes
+// may have several locations, e.g. if the probe site was inlined.
+func resolveProbe(spec ProbeSpec) ([]probeLocation, error) {
+	ef, err := pfelf.Open(spec.Binary)
+	if err != nil {
+		return nil, err
+	}
+	defer ef.Close()
+
+	if spec.Symbol != "" {
+		sym, err := ef.LookupSymbol(libpf.SymbolName(spec.Symbol))
+		if err != nil {
+			// Fall back to the full symbol table for non-exported functions.
+			if symbols, symErr := ef.ReadSymbols(); symErr == nil {
+				sym, err = symbols.LookupSymbol(libpf.SymbolName(spec.Symbol))
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find symbol %s: %v", spec.Symbol, err)
+		}
+		offset, ok := ef.VirtualAddressToFileOffset(uint64(sym.Address))
+		if !ok {
+			return nil, fmt.Errorf("symbol %s at 0x%x is not in a loadable segment",
+				spec.Symbol, sym.Address)
+		}
+		return []probeLocation{{offset: offset}}, nil
+	}
+
+	probes, err := ef.USDTProbes()
+	if err != nil {
+		return nil, err
+	}
+	var locations []probeLocation
+	for _, probe := range probes {
+		if probe.Provider != spec.Provider || probe.Name != spec.Name {
+			continue
+		}
+		var location probeLocation
+		var ok bool
+		if location.offset, ok = ef.VirtualAddressToFileOffset(probe.Address); !ok {
+			return nil, fmt.Errorf("probe at 0x%x is not in a loadable segment",
+				probe.Address)
+		}
+		if probe.SemaphoreAddress != 0 {
+			if location.refCtrOffset, ok =
+				ef.VirtualAddressToFileOffset(probe.SemaphoreAddress); !ok {
+				return nil, fmt.Errorf("semaphore at 0x%x is not in a loadable segment",
+					probe.SemaphoreAddress)
+			}
+		}
+		locations = append(locations, location)
+	}
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("USDT probe %s:%s not found", spec.Provider, spec.Name)
+	}
+	return locations, nil
+}
+
+// StartProbeProfiling attaches the uprobe__generic program to all configured
+// uprobe and USDT locations. Probes that can not be resolved are skipped with
+// a warning, but an error is returned if none of them could be attached.
+func (t *Tracer) StartProbeProfiling() error {
+	prog, ok := t.ebpfProgs["uprobe__generic"]
+	if !ok {
+		return errors.New("probe program uprobe__generic is not available")
+	}
+
+	attached := 0
+	for _, spec := range t.probes {
+		locations, err := resolveProbe(spec)
+		if err != nil {
+			log.Warnf("Failed to resolve probe %s: %v", spec, err)
+			continue
+		}
+
+		ex, err := link.OpenExecutable(spec.Binary)
+		if err != nil {
+			log.Warnf("Failed to open %s: %v", spec.Binary, err)
+			continue
+		}
+		// The symbol is only used to name the probe, as its address is given.
+		symbol := spec.Symbol
+		if symbol == "" {
+			symbol = spec.Provider + "_" + spec.Name
+		}
+		for _, location := range locations {
+			probeLink, err := ex.Uprobe(symbol, prog, &link.UprobeOptions{
+				Address:      location.offset,
+				RefCtrOffset: location.refCtrOffset,
+			})
+			if err != nil {
+				log.Warnf("Failed to attach to probe %s at 0x%x: %v",
+					spec, location.offset, err)
+				continue
+			}
+			attached++
+			t.hooks[hookPoint{
+				group: "uprobe",
+				name:  fmt.Sprintf("%s@0x%x", spec, location.offset),
+			}] = probeLink
+		}
+	}
+	if attached == 0 {
+		return fmt.Errorf("failed to attach to any of %d probes", len(t.probes))
+	}
+	log.Debugf("Attached to %d probe locations", attached)
+	return nil
+}
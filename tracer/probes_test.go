@@ -0,0 +1,53 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProbeSpecs(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected []ProbeSpec
+		wantErr  bool
+	}{
+		"empty": {
+			input: "",
+		},
+		"uprobe and USDT": {
+			input: "/usr/lib/libc.so.6:malloc, /usr/bin/python3:python:function__entry",
+			expected: []ProbeSpec{
+				{Binary: "/usr/lib/libc.so.6", Symbol: "malloc"},
+				{Binary: "/usr/bin/python3", Provider: "python", Name: "function__entry"},
+			},
+		},
+		"missing symbol": {
+			input:   "/usr/lib/libc.so.6",
+			wantErr: true,
+		},
+		"empty field": {
+			input:   "/usr/lib/libc.so.6:",
+			wantErr: true,
+		},
+		"too many fields": {
+			input:   "a:b:c:d",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseProbeSpecs(tc.input)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer // import "go.opentelemetry.io/ebpf-profiler/tracer"
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/ebpf-profiler/times"
+)
+
+// maxSamplingFrequencyChanges is the number of sampling frequency changes kept
+// to attribute the traces still buffered during a change. Traces collected
+// before the oldest change kept are attributed to it.
+const maxSamplingFrequencyChanges = 16
+
+// samplingFrequencyChange records the sampling frequency in effect since a point
+// in time.
+type samplingFrequencyChange struct {
+	since            times.KTime
+	samplesPerSecond int
+}
+
+// samplingFrequencies tracks the changes of the sampling frequency, so that
+// traces read from the trace event buffer after a change are attributed to the
+// frequency they were collected with.
+type samplingFrequencies struct {
+	mu sync.Mutex
+	// changes is ordered from oldest to newest.
+	changes []samplingFrequencyChange
+}
+
+func newSamplingFrequencies(samplesPerSecond int) *samplingFrequencies {
+	return &samplingFrequencies{
+		changes: []samplingFrequencyChange{{samplesPerSecond: samplesPerSecond}},
+	}
+}
+
+// set records samplesPerSecond as the frequency of the traces collected since
+// the given time.
+func (f *samplingFrequencies) set(since times.KTime, samplesPerSecond int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.changes) == maxSamplingFrequencyChanges {
+		f.changes = f.changes[1:]
+	}
+	f.changes = append(f.changes, samplingFrequencyChange{
+		since:            since,
+		samplesPerSecond: samplesPerSecond,
+	})
+}
+
+// at returns the sampling frequency of a trace collected at ktime.
+func (f *samplingFrequencies) at(ktime times.KTime) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.changes) - 1; i > 0; i-- {
+		if ktime >= f.changes[i].since {
+			return f.changes[i].samplesPerSecond
+		}
+	}
+	return f.changes[0].samplesPerSecond
+}
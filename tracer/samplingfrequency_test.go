@@ -0,0 +1,30 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplingFrequencies(t *testing.T) {
+	f := newSamplingFrequencies(20)
+	assert.Equal(t, 20, f.at(100))
+
+	f.set(1000, 50)
+	f.set(2000, 10)
+	// Traces still buffered after a change keep the frequency they were
+	// collected with.
+	assert.Equal(t, 20, f.at(999))
+	assert.Equal(t, 50, f.at(1000))
+	assert.Equal(t, 50, f.at(1999))
+	assert.Equal(t, 10, f.at(2000))
+
+	// Traces older than the changes kept are attributed to the oldest one.
+	for i := 0; i < maxSamplingFrequencyChanges; i++ {
+		f.set(3000, 30)
+	}
+	assert.Equal(t, 30, f.at(100))
+}
@@ -0,0 +1,129 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer // import "go.opentelemetry.io/ebpf-profiler/tracer"
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-perf"
+
+	"go.opentelemetry.io/ebpf-profiler/libpf"
+	"go.opentelemetry.io/ebpf-profiler/reporter/samples"
+	"go.opentelemetry.io/ebpf-profiler/support"
+)
+
+// softwareEvent describes a supported software perf event.
+type softwareEvent struct {
+	// counter is the perf software counter.
+	counter perf.SoftwareCounter
+	// origin is the trace origin the event reports its traces with.
+	origin libpf.Origin
+	// progName is the name of the eBPF entry program of the event.
+	progName string
+	// defaultPeriod is the sample period if none is given.
+	defaultPeriod uint64
+}
+
+// softwareEvents maps the names of the supported software perf events, as used
+// by the perf tool, to their definition.
+var softwareEvents = map[string]softwareEvent{
+	"page-faults": {
+		counter:       perf.PageFaults,
+		origin:        support.TraceOriginPageFaults,
+		progName:      "native_tracer_entry_page_faults",
+		defaultPeriod: 1000,
+	},
+	"major-faults": {
+		counter:       perf.MajorPageFaults,
+		origin:        support.TraceOriginMajorFaults,
+		progName:      "native_tracer_entry_major_faults",
+		defaultPeriod: 1,
+	},
+	"minor-faults": {
+		counter:       perf.MinorPageFaults,
+		origin:        support.TraceOriginMinorFaults,
+		progName:      "native_tracer_entry_minor_faults",
+		defaultPeriod: 1000,
+	},
+	"context-switches": {
+		counter:       perf.ContextSwitches,
+		origin:        support.TraceOriginContextSwitches,
+		progName:      "native_tracer_entry_context_switches",
+		defaultPeriod: 100,
+	},
+	"cpu-migrations": {
+		counter:       perf.CPUMigrations,
+		origin:        support.TraceOriginCPUMigrations,
+		progName:      "native_tracer_entry_cpu_migrations",
+		defaultPeriod: 1,
+	},
+}
+
+// SoftwareEventSpec describes a software perf event that triggers the
+// collection of a stack trace every Period events.
+type SoftwareEventSpec struct {
+	// Name is the name of the event, e.g. "page-faults".
+	Name string
+	// Period is the number of events between two samples.
+	Period uint64
+}
+
+// ParseSoftwareEventSpecs parses a comma separated list of software perf
+// events with an optional sample period, e.g. "page-faults@100,cpu-migrations".
+// Supported events are page-faults, major-faults, minor-faults,
+// context-switches and cpu-migrations.
+func ParseSoftwareEventSpecs(specs string) ([]SoftwareEventSpec, error) {
+	var events []SoftwareEventSpec
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		name, period, hasPeriod := strings.Cut(spec, "@")
+		event, ok := softwareEvents[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported software event %q", name)
+		}
+		if slices.ContainsFunc(events, func(e SoftwareEventSpec) bool {
+			return e.Name == name
+		}) {
+			return nil, fmt.Errorf("duplicate software event %q", name)
+		}
+
+		eventSpec := SoftwareEventSpec{Name: name, Period: event.defaultPeriod}
+		if hasPeriod {
+			n, err := strconv.ParseUint(period, 10, 64)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid sample period in software event %q", spec)
+			}
+			eventSpec.Period = n
+		}
+		events = append(events, eventSpec)
+	}
+	return events, nil
+}
+
+// SoftwareEventOrigins returns the trace origins of the software perf events.
+func SoftwareEventOrigins(events []SoftwareEventSpec) []samples.EventOrigin {
+	origins := make([]samples.EventOrigin, 0, len(events))
+	for _, event := range events {
+		origins = append(origins, samples.EventOrigin{
+			Origin:     softwareEvents[event.Name].origin,
+			Name:       event.Name,
+			SampleType: event.Name,
+			SampleUnit: "count",
+			Period:     int64(event.Period),
+		})
+	}
+	return origins
+}
+
+// isSoftwareEventOrigin returns true if origin belongs to a software perf event.
+func isSoftwareEventOrigin(origin libpf.Origin) bool {
+	return origin >= support.TraceOriginPageFaults && origin <= support.TraceOriginCPUMigrations
+}
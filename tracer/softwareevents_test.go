@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSoftwareEventSpecs(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected []SoftwareEventSpec
+		wantErr  bool
+	}{
+		"empty": {
+			input: "",
+		},
+		"default and custom periods": {
+			input: "page-faults@100, major-faults,cpu-migrations@5",
+			expected: []SoftwareEventSpec{
+				{Name: "page-faults", Period: 100},
+				{Name: "major-faults", Period: 1},
+				{Name: "cpu-migrations", Period: 5},
+			},
+		},
+		"unsupported event": {
+			input:   "cpu-clock",
+			wantErr: true,
+		},
+		"duplicate event": {
+			input:   "context-switches,context-switches@10",
+			wantErr: true,
+		},
+		"zero period": {
+			input:   "minor-faults@0",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseSoftwareEventSpecs(tc.input)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
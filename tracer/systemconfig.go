@@ -10,6 +10,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"time"
 	"unsafe"
 
 	"go.opentelemetry.io/ebpf-profiler/rlimit"
@@ -227,7 +228,9 @@ func determineStackLayout(coll *cebpf.CollectionSpec, maps map[string]*cebpf.Map
 
 func loadSystemConfig(coll *cebpf.CollectionSpec, maps map[string]*cebpf.Map,
 	kernelSymbols *libpf.SymbolMap, includeTracers types.IncludedTracers,
-	offCPUThreshold uint32, filterErrorFrames bool) error {
+	offCPUThreshold uint32, offCPUMinDuration time.Duration,
+	offCPUWakeups, offCPUFilterPIDs, filterErrorFrames, filterCgroups, filterPIDs,
+	followChildren bool) error {
 	pacMask := pacmask.GetPACMask()
 	if pacMask != 0 {
 		log.Infof("Determined PAC mask to be 0x%016X", pacMask)
@@ -238,6 +241,12 @@ func loadSystemConfig(coll *cebpf.CollectionSpec, maps map[string]*cebpf.Map,
 		inverse_pac_mask:       ^C.u64(pacMask),
 		drop_error_only_traces: C.bool(filterErrorFrames),
 		off_cpu_threshold:      C.u32(offCPUThreshold),
+		filter_cgroups:         C.bool(filterCgroups),
+		off_cpu_wakeups:        C.bool(offCPUWakeups),
+		off_cpu_filter_pids:    C.bool(offCPUFilterPIDs),
+		filter_pids:            C.bool(filterPIDs),
+		follow_children:        C.bool(followChildren),
+		off_cpu_min_duration:   C.u64(offCPUMinDuration.Nanoseconds()),
 	}
 
 	if err := parseBTF(&syscfg); err != nil {
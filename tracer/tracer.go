@@ -12,6 +12,8 @@ import (
 	"hash/fnv"
 	"math"
 	"math/rand/v2"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"sync/atomic"
@@ -21,11 +23,13 @@ import (
 	cebpf "github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/link"
+	lru "github.com/elastic/go-freelru"
 	"github.com/elastic/go-perf"
 	log "github.com/sirupsen/logrus"
 	"github.com/zeebo/xxh3"
 
 	"go.opentelemetry.io/ebpf-profiler/host"
+	"go.opentelemetry.io/ebpf-profiler/interpreter/python"
 	"go.opentelemetry.io/ebpf-profiler/libpf"
 	"go.opentelemetry.io/ebpf-profiler/libpf/pfelf"
 	"go.opentelemetry.io/ebpf-profiler/libpf/xsync"
@@ -89,9 +93,30 @@ type Tracer struct {
 	// kernelModules holds symbols/addresses for the kernel module address space
 	kernelModules *libpf.SymbolMap
 
+	// blockingReasons caches the blocking reason of kernel addresses in off-CPU
+	// traces. It is only used by the trace event reader.
+	blockingReasons *lru.LRU[libpf.Address, string]
+
 	// perfEntrypoints holds a list of frequency based perf events that are opened on the system.
 	perfEntrypoints xsync.RWMutex[[]*perf.Event]
 
+	// samplingEvents holds the CPU clock perf events of perfEntrypoints. It is
+	// protected by the perfEntrypoints lock.
+	samplingEvents []*perf.Event
+
+	// cpuEvents holds the perf events of perfEntrypoints per CPU. It is protected
+	// by the perfEntrypoints lock.
+	cpuEvents map[int][]*perf.Event
+
+	// cpuEnabledTimes holds the time the sampling perf event of each CPU was
+	// enabled as of the last update of the online CPUs. It is protected by the
+	// perfEntrypoints lock.
+	cpuEnabledTimes map[int]time.Duration
+
+	// perfEventsDisabled indicates whether probabilistic profiling currently
+	// disabled the perf events. It is protected by the perfEntrypoints lock.
+	perfEventsDisabled bool
+
 	// hooks holds references to loaded eBPF hooks.
 	hooks map[hookPoint]link.Link
 
@@ -121,14 +146,49 @@ type Tracer struct {
 	// reporter allows swapping out the reporter implementation.
 	reporter reporter.SymbolReporter
 
-	// samplesPerSecond holds the configured number of samples per second.
-	samplesPerSecond int
+	// samplesPerSecond holds the current number of samples per second. It is
+	// only changed with the perfEntrypoints lock held.
+	samplesPerSecond atomic.Int64
+
+	// samplingFrequencies attributes the sampled traces to the frequency they
+	// were collected with.
+	samplingFrequencies *samplingFrequencies
+
+	// maxSamplesPerSecond is the highest number of samples per second the trace
+	// event buffer is sized for.
+	maxSamplesPerSecond int
 
 	// probabilisticInterval is the time interval for which probabilistic profiling will be enabled.
 	probabilisticInterval time.Duration
 
 	// probabilisticThreshold holds the threshold for probabilistic profiling.
 	probabilisticThreshold uint
+
+	// cgroups holds the cgroupv2 paths profiling is restricted to.
+	cgroups []string
+
+	// cgroupIDs holds the cgroup IDs currently stored in the cgroup_filter eBPF map.
+	cgroupIDs libpf.Set[uint64]
+
+	// probes holds the user space probes that trigger stack collection.
+	probes []ProbeSpec
+
+	// kernelEvents holds the kernel tracepoints and kprobes that trigger stack collection.
+	kernelEvents []KernelEventSpec
+
+	// softwareEvents holds the software perf events that trigger stack collection.
+	softwareEvents []SoftwareEventSpec
+
+	// offCPUWakeups indicates whether the stacks of tasks waking up off-CPU sampled
+	// tasks are collected.
+	offCPUWakeups bool
+
+	// filterPIDs indicates whether profiling is restricted to the PIDs in the
+	// pid_filter eBPF map.
+	filterPIDs bool
+
+	// targetsExited is closed once all processes profiling is restricted to exited.
+	targetsExited chan libpf.Void
 }
 
 type Config struct {
@@ -138,8 +198,14 @@ type Config struct {
 	Intervals Intervals
 	// IncludeTracers holds information about which tracers are enabled.
 	IncludeTracers types.IncludedTracers
+	// PythonOptions holds the optional features of the Python tracer.
+	PythonOptions python.Options
 	// SamplesPerSecond holds the number of samples per second.
 	SamplesPerSecond int
+	// MaxSamplesPerSecond is the highest number of samples per second the
+	// sampling frequency can be changed to at runtime. If it is lower than
+	// SamplesPerSecond, SamplesPerSecond is used.
+	MaxSamplesPerSecond int
 	// MapScaleFactor is the scaling factor for eBPF map sizes.
 	MapScaleFactor int
 	// FilterErrorFrames indicates whether error frames should be filtered.
@@ -156,9 +222,63 @@ type Config struct {
 	ProbabilisticThreshold uint
 	// OffCPUThreshold is the user defined threshold for off-cpu profiling.
 	OffCPUThreshold uint32
+	// OffCPUMinDuration enables the latency mode of off-CPU profiling as an
+	// alternative to OffCPUThreshold: all scheduler events are considered, but
+	// only off-CPU intervals of at least this duration are recorded.
+	OffCPUMinDuration time.Duration
+	// OffCPUPIDs restricts off-CPU profiling to the given PIDs. If empty, all
+	// processes are profiled.
+	OffCPUPIDs []libpf.PID
+	// OffCPUWakeups enables collecting the stacks of the tasks that wake up off-CPU
+	// sampled tasks, reported with the TraceOriginWakeup origin. Requires off-CPU
+	// profiling to be enabled.
+	OffCPUWakeups bool
 	// IncludeEnvVars holds a list of environment variables that should be captured and reported
 	// from processes
 	IncludeEnvVars libpf.Set[string]
+	// IncludeCmdline indicates whether the command lines of processes are reported.
+	IncludeCmdline bool
+	// CmdlineRedact holds the patterns whose matches are removed from the reported
+	// process command lines. If a pattern has capture groups, only these are removed.
+	CmdlineRedact []*regexp.Regexp
+	// IncludeAncestors indicates whether the names of the ancestor processes are reported.
+	IncludeAncestors bool
+	// Cgroups restricts profiling to the given cgroupv2 paths, as returned by
+	// libpf.LookupCgroupv2, and their descendants. If empty, all cgroups are profiled.
+	Cgroups []string
+	// PIDs restricts profiling to the given processes. If empty, all processes
+	// are profiled.
+	PIDs []libpf.PID
+	// FollowChildren extends the restriction to PIDs to the existing and future
+	// descendants of these processes.
+	FollowChildren bool
+	// Probes holds the uprobe and USDT locations that trigger the collection of
+	// stack traces with the TraceOriginProbe origin.
+	Probes []ProbeSpec
+	// KernelEvents holds the kernel tracepoints and kprobes that trigger the
+	// collection of stack traces. Their origins are given by KernelEventOrigins.
+	KernelEvents []KernelEventSpec
+	// SoftwareEvents holds the software perf events, e.g. page faults, that trigger
+	// the collection of stack traces. Their origins are given by SoftwareEventOrigins.
+	SoftwareEvents []SoftwareEventSpec
+}
+
+// offCPULatencyMapSize is the number of tasks that can be off-CPU at the same
+// time in the latency mode of off-CPU profiling without losing intervals.
+const offCPULatencyMapSize = 32768
+
+// offCPUEnabled returns true if off-CPU profiling is enabled in either mode.
+func (cfg *Config) offCPUEnabled() bool {
+	return cfg.OffCPUThreshold > 0 || cfg.OffCPUMinDuration > 0
+}
+
+// offCPUSamplingThreshold returns the per-mille chance of a scheduler event to
+// be considered for off-CPU profiling. The latency mode considers all events.
+func (cfg *Config) offCPUSamplingThreshold() uint32 {
+	if cfg.OffCPUMinDuration > 0 {
+		return support.OffCPUThresholdMax
+	}
+	return cfg.OffCPUThreshold
 }
 
 // hookPoint specifies the group and name of the hooked point in the kernel.
@@ -297,9 +417,11 @@ func NewTracer(ctx context.Context, cfg *Config) (*Tracer, error) {
 
 	hasBatchOperations := ebpfHandler.SupportsGenericBatchOperations()
 
-	processManager, err := pm.New(ctx, cfg.IncludeTracers, cfg.Intervals.MonitorInterval(),
+	processManager, err := pm.New(ctx, cfg.IncludeTracers, cfg.PythonOptions,
+		cfg.Intervals.MonitorInterval(),
 		ebpfHandler, nil, cfg.Reporter, elfunwindinfo.NewStackDeltaProvider(),
-		cfg.FilterErrorFrames, cfg.IncludeEnvVars)
+		cfg.FilterErrorFrames, cfg.IncludeEnvVars, cfg.IncludeCmdline, cfg.CmdlineRedact,
+		cfg.IncludeAncestors)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create processManager: %v", err)
 	}
@@ -316,12 +438,19 @@ func NewTracer(ctx context.Context, cfg *Config) (*Tracer, error) {
 		return nil, fmt.Errorf("failed to extract kernel modules metadata: %v", err)
 	}
 
+	blockingReasons, err := lru.New[libpf.Address, string](blockingReasonCacheSize,
+		libpf.Address.Hash32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blocking reason cache: %v", err)
+	}
+
 	perfEventList := []*perf.Event{}
 
-	return &Tracer{
+	t := &Tracer{
 		processManager:         processManager,
 		kernelSymbols:          kernelSymbols,
 		kernelModules:          kernelModules,
+		blockingReasons:        blockingReasons,
 		triggerPIDProcessing:   make(chan bool, 1),
 		pidEvents:              make(chan libpf.PID, pidEventBufferSize),
 		ebpfMaps:               ebpfMaps,
@@ -332,10 +461,43 @@ func NewTracer(ctx context.Context, cfg *Config) (*Tracer, error) {
 		perfEntrypoints:        xsync.NewRWMutex(perfEventList),
 		moduleFileIDs:          moduleFileIDs,
 		reporter:               cfg.Reporter,
-		samplesPerSecond:       cfg.SamplesPerSecond,
+		maxSamplesPerSecond:    max(cfg.SamplesPerSecond, cfg.MaxSamplesPerSecond),
 		probabilisticInterval:  cfg.ProbabilisticInterval,
 		probabilisticThreshold: cfg.ProbabilisticThreshold,
-	}, nil
+		cgroups:                cfg.Cgroups,
+		probes:                 cfg.Probes,
+		kernelEvents:           cfg.KernelEvents,
+		softwareEvents:         cfg.SoftwareEvents,
+		offCPUWakeups:          cfg.OffCPUWakeups,
+		filterPIDs:             len(cfg.PIDs) > 0,
+		targetsExited:          make(chan libpf.Void),
+		cgroupIDs:              make(libpf.Set[uint64]),
+	}
+	t.samplesPerSecond.Store(int64(cfg.SamplesPerSecond))
+	t.samplingFrequencies = newSamplingFrequencies(cfg.SamplesPerSecond)
+
+	if t.filterPIDs {
+		if err = t.loadPIDFilter(cfg.PIDs, cfg.FollowChildren); err != nil {
+			return nil, fmt.Errorf("failed to load PID filter: %v", err)
+		}
+	}
+
+	if len(cfg.OffCPUPIDs) > 0 {
+		if err = t.loadOffCPUPIDs(cfg.OffCPUPIDs); err != nil {
+			return nil, fmt.Errorf("failed to load off-cpu PID filter: %v", err)
+		}
+	}
+
+	if len(t.cgroups) > 0 {
+		if err = t.updateCgroupFilter(); err != nil {
+			return nil, fmt.Errorf("failed to load cgroup filter: %v", err)
+		}
+		if len(t.cgroupIDs) == 0 {
+			log.Warnf("None of the cgroups %v exists yet", t.cgroups)
+		}
+	}
+
+	return t, nil
 }
 
 // Close provides functionality for Tracer to perform cleanup tasks.
@@ -351,6 +513,9 @@ func (t *Tracer) Close() {
 		}
 	}
 	*events = nil
+	t.samplingEvents = nil
+	t.cpuEvents = nil
+	t.cpuEnabledTimes = nil
 	t.perfEntrypoints.WUnlock(&events)
 
 	// Avoid resource leakage by closing all kernel hooks.
@@ -488,20 +653,93 @@ func initializeMapsAndPrograms(kernelSymbols *libpf.SymbolMap, cfg *Config) (
 		},
 	}
 
+	softwareEventProgs := make([]progLoaderHelper, 0, len(cfg.SoftwareEvents))
+	for _, event := range cfg.SoftwareEvents {
+		softwareEventProgs = append(softwareEventProgs, progLoaderHelper{
+			name:             softwareEvents[event.Name].progName,
+			noTailCallTarget: true,
+			enable:           true,
+		})
+	}
+
 	if err = loadPerfUnwinders(coll, ebpfProgs, ebpfMaps["perf_progs"], tailCallProgs,
-		cfg.BPFVerifierLogLevel); err != nil {
+		softwareEventProgs, cfg.BPFVerifierLogLevel); err != nil {
 		return nil, nil, fmt.Errorf("failed to load perf eBPF programs: %v", err)
 	}
 
-	if cfg.OffCPUThreshold > 0 {
+	hasKprobeEvents := slices.ContainsFunc(cfg.KernelEvents, func(e KernelEventSpec) bool {
+		return e.Group == ""
+	})
+	hasTracepointEvents := slices.ContainsFunc(cfg.KernelEvents, func(e KernelEventSpec) bool {
+		return e.Group != ""
+	})
+
+	if cfg.offCPUEnabled() || len(cfg.Probes) > 0 || hasKprobeEvents {
+		entryProgs := []progLoaderHelper{
+			{
+				name:             "finish_task_switch",
+				noTailCallTarget: true,
+				enable:           cfg.offCPUEnabled(),
+			},
+			{
+				name:             "tracepoint__sched_switch",
+				noTailCallTarget: true,
+				enable:           cfg.offCPUEnabled(),
+			},
+			{
+				name:             "uprobe__generic",
+				noTailCallTarget: true,
+				enable:           len(cfg.Probes) > 0,
+			},
+			{
+				name:             "kprobe__generic",
+				noTailCallTarget: true,
+				enable:           hasKprobeEvents,
+			},
+		}
 		if err = loadKProbeUnwinders(coll, ebpfProgs, ebpfMaps["kprobe_progs"], tailCallProgs,
-			cfg.BPFVerifierLogLevel, ebpfMaps["perf_progs"].FD()); err != nil {
+			entryProgs, cfg.BPFVerifierLogLevel, ebpfMaps["perf_progs"].FD(),
+			"kprobe_"); err != nil {
 			return nil, nil, fmt.Errorf("failed to load kprobe eBPF programs: %v", err)
 		}
 	}
 
+	if hasTracepointEvents || cfg.OffCPUWakeups {
+		entryProgs := []progLoaderHelper{
+			{
+				name:             "tracepoint__generic",
+				noTailCallTarget: true,
+				enable:           hasTracepointEvents,
+			},
+			{
+				name:             "tracepoint__sched_waking",
+				noTailCallTarget: true,
+				enable:           cfg.OffCPUWakeups,
+			},
+		}
+		if err = loadKProbeUnwinders(coll, ebpfProgs, ebpfMaps["tracepoint_progs"],
+			tailCallProgs, entryProgs, cfg.BPFVerifierLogLevel, ebpfMaps["perf_progs"].FD(),
+			"tracepoint_"); err != nil {
+			return nil, nil, fmt.Errorf("failed to load tracepoint eBPF programs: %v", err)
+		}
+	}
+
+	if len(cfg.PIDs) > 0 && cfg.FollowChildren {
+		progSpec, ok := coll.Programs["tracepoint__task_newtask"]
+		if !ok {
+			return nil, nil, errors.New("program tracepoint__task_newtask does not exist")
+		}
+		if err = loadProgram(ebpfProgs, nil, 0, progSpec, cebpf.ProgramOptions{
+			LogLevel: cebpf.LogLevel(cfg.BPFVerifierLogLevel),
+		}, true); err != nil {
+			return nil, nil, fmt.Errorf("failed to load fork tracking eBPF program: %v", err)
+		}
+	}
+
 	if err = loadSystemConfig(coll, ebpfMaps, kernelSymbols, cfg.IncludeTracers,
-		cfg.OffCPUThreshold, cfg.FilterErrorFrames); err != nil {
+		cfg.offCPUSamplingThreshold(), cfg.OffCPUMinDuration, cfg.OffCPUWakeups,
+		len(cfg.OffCPUPIDs) > 0, cfg.FilterErrorFrames, len(cfg.Cgroups) > 0,
+		len(cfg.PIDs) > 0, cfg.FollowChildren); err != nil {
 		return nil, nil, fmt.Errorf("failed to load system config: %v", err)
 	}
 
@@ -555,7 +793,15 @@ func loadAllMaps(coll *cebpf.CollectionSpec, cfg *Config,
 	// calculate a size based on an assumed upper bound of scheduler events per
 	// second (1000hz) multiplied by an average time a task remains off CPU (3s),
 	// scaled by the probability of capturing a trace.
-	adaption["sched_times"] = (4096 * cfg.OffCPUThreshold) / support.OffCPUThresholdMax
+	adaption["sched_times"] = (4096 * cfg.offCPUSamplingThreshold()) / support.OffCPUThresholdMax
+	if cfg.OffCPUMinDuration > 0 {
+		// The latency mode records every task that is scheduled out, so the map
+		// needs room for all tasks that are off-CPU at the same time. Otherwise
+		// the long intervals the mode is looking for are evicted first.
+		adaption["sched_times"] = offCPULatencyMapSize
+	}
+	adaption["offcpu_tasks"] = adaption["sched_times"]
+	adaption["wakeups"] = adaption["sched_times"]
 
 	for i := support.StackDeltaBucketSmallest; i <= support.StackDeltaBucketLargest; i++ {
 		mapName := fmt.Sprintf("exe_id_to_%d_stack_deltas", i)
@@ -563,7 +809,8 @@ func loadAllMaps(coll *cebpf.CollectionSpec, cfg *Config,
 	}
 
 	for mapName, mapSpec := range coll.Maps {
-		if mapName == "sched_times" && cfg.OffCPUThreshold == 0 {
+		if (mapName == "sched_times" || mapName == "offcpu_tasks" || mapName == "wakeups" ||
+			mapName == "offcpu_pids") && !cfg.offCPUEnabled() {
 			// Off CPU Profiling is disabled. So do not load this map.
 			continue
 		}
@@ -583,7 +830,7 @@ func loadAllMaps(coll *cebpf.CollectionSpec, cfg *Config,
 
 // loadPerfUnwinders loads all perf eBPF Programs and their tail call targets.
 func loadPerfUnwinders(coll *cebpf.CollectionSpec, ebpfProgs map[string]*cebpf.Program,
-	tailcallMap *cebpf.Map, tailCallProgs []progLoaderHelper,
+	tailcallMap *cebpf.Map, tailCallProgs, entryProgs []progLoaderHelper,
 	bpfVerifierLogLevel uint32) error {
 	programOptions := cebpf.ProgramOptions{
 		LogLevel: cebpf.LogLevel(bpfVerifierLogLevel),
@@ -591,6 +838,7 @@ func loadPerfUnwinders(coll *cebpf.CollectionSpec, ebpfProgs map[string]*cebpf.P
 
 	progs := make([]progLoaderHelper, len(tailCallProgs)+2)
 	copy(progs, tailCallProgs)
+	progs = append(progs, entryProgs...)
 	progs = append(progs,
 		progLoaderHelper{
 			name:             "tracepoint__sched_process_exit",
@@ -650,27 +898,17 @@ func progArrayReferences(perfTailCallMapFD int, insns asm.Instructions) []int {
 // loadKProbeUnwinders reuses large parts of loadPerfUnwinders. By default all eBPF programs
 // are written as perf event eBPF programs. loadKProbeUnwinders dynamically rewrites the
 // specification of these programs to kprobe eBPF programs and adjusts tail call maps.
+// The program type is selected by progPrefix, which is "kprobe_" or "tracepoint_".
 func loadKProbeUnwinders(coll *cebpf.CollectionSpec, ebpfProgs map[string]*cebpf.Program,
-	tailcallMap *cebpf.Map, tailCallProgs []progLoaderHelper,
-	bpfVerifierLogLevel uint32, perfTailCallMapFD int) error {
+	tailcallMap *cebpf.Map, tailCallProgs, entryProgs []progLoaderHelper,
+	bpfVerifierLogLevel uint32, perfTailCallMapFD int, progPrefix string) error {
 	programOptions := cebpf.ProgramOptions{
 		LogLevel: cebpf.LogLevel(bpfVerifierLogLevel),
 	}
 
-	progs := make([]progLoaderHelper, len(tailCallProgs)+2)
-	copy(progs, tailCallProgs)
-	progs = append(progs,
-		progLoaderHelper{
-			name:             "finish_task_switch",
-			noTailCallTarget: true,
-			enable:           true,
-		},
-		progLoaderHelper{
-			name:             "tracepoint__sched_switch",
-			noTailCallTarget: true,
-			enable:           true,
-		},
-	)
+	progs := make([]progLoaderHelper, 0, len(tailCallProgs)+len(entryProgs))
+	progs = append(progs, tailCallProgs...)
+	progs = append(progs, entryProgs...)
 
 	for _, unwindProg := range progs {
 		if !unwindProg.enable {
@@ -679,7 +917,7 @@ func loadKProbeUnwinders(coll *cebpf.CollectionSpec, ebpfProgs map[string]*cebpf
 
 		unwindProgName := unwindProg.name
 		if !unwindProg.noTailCallTarget {
-			unwindProgName = "kprobe_" + unwindProg.name
+			unwindProgName = progPrefix + unwindProg.name
 		}
 
 		progSpec, ok := coll.Programs[unwindProgName]
@@ -825,12 +1063,35 @@ func (t *Tracer) insertKernelFrames(trace *host.Trace, ustackLen uint32,
 		}
 	}
 
+	if trace.Origin == support.TraceOriginOffCPU {
+		trace.BlockingReason = t.offCPUBlockingReason(kstackVal[:kstackLen])
+	}
+
 	t.fallbackSymbolMiss.Add(kernelSymbolCacheMiss)
 	t.fallbackSymbolHit.Add(kernelSymbolCacheHit)
 
 	return kstackLen, nil
 }
 
+// offCPUBlockingReason returns the blocking reason of the first kernel frame, starting
+// from the leaf, whose function indicates one.
+func (t *Tracer) offCPUBlockingReason(kstack []C.uint64_t) string {
+	for _, addr := range kstack {
+		reason, found := t.blockingReasons.Get(libpf.Address(addr))
+		if !found {
+			if symbol, _, ok := t.kernelSymbols.LookupByAddress(
+				libpf.SymbolValue(addr)); ok {
+				reason = blockingReason(string(symbol))
+			}
+			t.blockingReasons.Add(libpf.Address(addr), reason)
+		}
+		if reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
 // enableEvent removes the entry of given eventType from the inhibitEvents map
 // so that the eBPF code will send the event again.
 func (t *Tracer) enableEvent(eventType int) {
@@ -974,9 +1235,12 @@ func (t *Tracer) loadBpfTrace(raw []byte, cpu int) *host.Trace {
 	ptr := (*C.Trace)(unsafe.Pointer(unsafe.SliceData(raw)))
 
 	// NOTE: can't do exact check here: kernel adds a few padding bytes to messages.
-	if len(raw) < frameListOffs+int(ptr.stack_len)*frameSize {
+	tailOffs := frameListOffs + int(ptr.stack_len)*frameSize
+	if len(raw) < tailOffs+int(unsafe.Sizeof(C.TraceTail{})) {
 		panic("unexpected record size")
 	}
+	// send_trace moves the tail right behind the last frame.
+	tail := (*C.TraceTail)(unsafe.Pointer(&raw[tailOffs]))
 
 	pid := libpf.PID(ptr.pid)
 	procMeta := t.processManager.MetaForPID(pid)
@@ -993,9 +1257,33 @@ func (t *Tracer) loadBpfTrace(raw []byte, cpu int) *host.Trace {
 		KTime:            times.KTime(ptr.ktime),
 		CPU:              cpu,
 		EnvVars:          procMeta.EnvVariables,
+		CommandLine:      procMeta.CommandLine,
+		ParentPID:        procMeta.ParentPID,
+		StartTime:        procMeta.StartTime,
+		Ancestors:        procMeta.Ancestors,
+		Wakeup: libpf.WakeupLink{
+			WakerPID: libpf.PID(tail.wakeup.waker_pid),
+			WakerTID: libpf.PID(tail.wakeup.waker_tid),
+			WakeePID: libpf.PID(tail.wakeup.wakee_pid),
+			WakeeTID: libpf.PID(tail.wakeup.wakee_tid),
+		},
 	}
-
-	if trace.Origin != support.TraceOriginSampling && trace.Origin != support.TraceOriginOffCPU {
+	switch trace.Origin {
+	case support.TraceOriginSampling:
+		trace.SamplesPerSecond = t.samplingFrequencies.at(trace.KTime)
+	case support.TraceOriginOffCPU:
+		trace.TaskState = taskStateName(uint64(tail.task_state))
+	}
+
+	switch {
+	case trace.Origin == support.TraceOriginSampling,
+		trace.Origin == support.TraceOriginOffCPU,
+		trace.Origin == support.TraceOriginWakeup,
+		trace.Origin == support.TraceOriginProbe:
+	case isSoftwareEventOrigin(trace.Origin):
+	case trace.Origin >= support.TraceOriginKernelEvent &&
+		int(trace.Origin) < support.TraceOriginKernelEvent+len(t.kernelEvents):
+	default:
 		log.Warnf("Skip handling trace from unexpected %d origin", trace.Origin)
 		return nil
 	}
@@ -1003,13 +1291,15 @@ func (t *Tracer) loadBpfTrace(raw []byte, cpu int) *host.Trace {
 	// Trace fields included in the hash:
 	//  - PID, kernel stack ID, length & frame array
 	// Intentionally excluded:
-	//  - ktime, COMM, APM trace, APM transaction ID, Origin and Off Time
+	//  - ktime, COMM, APM trace, APM transaction ID, Origin, Off Time, Task State
+	//    and Wakeup
 	ptr.comm = [16]C.char{}
 	ptr.apm_trace_id = C.ApmTraceID{}
 	ptr.apm_transaction_id = C.ApmSpanID{}
 	ptr.ktime = 0
 	ptr.origin = 0
 	ptr.offtime = 0
+	*tail = C.TraceTail{}
 	trace.Hash = host.TraceHash(xxh3.Hash128(raw).Lo)
 
 	userFrameOffs := 0
@@ -1063,6 +1353,31 @@ func (t *Tracer) StartMapMonitors(ctx context.Context, traceOutChan chan<- *host
 			pidEvents = pidEvents[:0]
 		})
 
+	// Pick up CPUs that were brought online or offline in the meantime.
+	periodiccaller.Start(ctx, t.intervals.MonitorInterval(), func() {
+		if err := t.updateOnlineCPUs(); err != nil {
+			log.Warnf("Failed to update perf events of online CPUs: %v", err)
+		}
+	})
+
+	if t.filterPIDs {
+		// Forget about exited processes and notice when all of them exited.
+		periodiccaller.Start(ctx, t.intervals.MonitorInterval(), func() {
+			if err := t.updatePIDFilter(); err != nil {
+				log.Warnf("Failed to update PID filter: %v", err)
+			}
+		})
+	}
+
+	if len(t.cgroups) > 0 {
+		// Pick up cgroups that were created or removed in the meantime.
+		periodiccaller.Start(ctx, t.intervals.MonitorInterval(), func() {
+			if err := t.updateCgroupFilter(); err != nil {
+				log.Warnf("Failed to update cgroup filter: %v", err)
+			}
+		})
+	}
+
 	// translateIDs is a translation table for eBPF IDs into Metric IDs.
 	// Index is the ebpfID, value is the corresponding metricID.
 	//nolint:lll
@@ -1157,6 +1472,7 @@ func (t *Tracer) StartMapMonitors(ctx context.Context, traceOutChan chan<- *host
 		C.metricID_UnwindDotnetErrBadFP:                       metrics.IDUnwindDotnetErrBadFP,
 		C.metricID_UnwindDotnetErrCodeHeader:                  metrics.IDUnwindDotnetErrCodeHeader,
 		C.metricID_UnwindDotnetErrCodeTooLarge:                metrics.IDUnwindDotnetErrCodeTooLarge,
+		C.metricID_OffCPUDroppedShortIntervals:                metrics.IDOffCPUDroppedShortIntervals,
 	}
 
 	// previousMetricValue stores the previously retrieved metric values to
@@ -1187,17 +1503,6 @@ func (t *Tracer) StartMapMonitors(ctx context.Context, traceOutChan chan<- *host
 // entry point is always the native tracer. The native tracer will determine when to invoke the
 // interpreter tracers based on address range information.
 func (t *Tracer) AttachTracer() error {
-	tracerProg, ok := t.ebpfProgs["native_tracer_entry"]
-	if !ok {
-		return errors.New("entry program is not available")
-	}
-
-	perfAttribute := new(perf.Attr)
-	perfAttribute.SetSampleFreq(uint64(t.samplesPerSecond))
-	if err := perf.CPUClock.Configure(perfAttribute); err != nil {
-		return fmt.Errorf("failed to configure software perf event: %v", err)
-	}
-
 	onlineCPUIDs, err := getOnlineCPUIDs()
 	if err != nil {
 		return fmt.Errorf("failed to get online CPUs: %v", err)
@@ -1205,16 +1510,54 @@ func (t *Tracer) AttachTracer() error {
 
 	events := t.perfEntrypoints.WLock()
 	defer t.perfEntrypoints.WUnlock(&events)
+	if t.cpuEvents == nil {
+		t.cpuEvents = make(map[int][]*perf.Event, len(onlineCPUIDs))
+		t.cpuEnabledTimes = make(map[int]time.Duration, len(onlineCPUIDs))
+	}
 	for _, id := range onlineCPUIDs {
-		perfEvent, err := perf.Open(perfAttribute, perf.AllThreads, id, nil)
-		if err != nil {
-			return fmt.Errorf("failed to attach to perf event on CPU %d: %v", id, err)
+		if err := t.attachCPU(events, id); err != nil {
+			return err
 		}
-		if err := perfEvent.SetBPF(uint32(tracerProg.FD())); err != nil {
-			return fmt.Errorf("failed to attach eBPF program to perf event: %v", err)
+	}
+	metrics.Add(metrics.IDSampledCPUs, metrics.MetricValue(len(t.cpuEvents)))
+	return nil
+}
+
+// SetSamplingFrequency changes the number of samples per second of on-CPU
+// profiling while profiling continues. The CPU clock perf events are updated in
+// place. Traces carry the frequency they were collected with, so that they are
+// reported with the matching period, even if they are read from the trace event
+// buffer after the change. Only the traces collected while the perf events of
+// the CPUs are updated may be attributed to the new frequency.
+func (t *Tracer) SetSamplingFrequency(samplesPerSecond int) error {
+	if samplesPerSecond < 1 || samplesPerSecond > t.maxSamplesPerSecond {
+		return fmt.Errorf("invalid sampling frequency: %d (max: %d)",
+			samplesPerSecond, t.maxSamplesPerSecond)
+	}
+
+	events := t.perfEntrypoints.WLock()
+	defer t.perfEntrypoints.WUnlock(&events)
+
+	// For frequency based perf events, the kernel interprets the new period as
+	// the new frequency.
+	since := times.GetKTime()
+	for i, event := range t.samplingEvents {
+		if err := event.UpdatePeriod(uint64(samplesPerSecond)); err != nil {
+			// Restore the events updated so far, so that all CPUs keep sampling
+			// with the same frequency.
+			old := uint64(t.samplesPerSecond.Load())
+			for _, updated := range t.samplingEvents[:i] {
+				if rerr := updated.UpdatePeriod(old); rerr != nil {
+					log.Errorf("Failed to restore sampling frequency: %v", rerr)
+				}
+			}
+			return fmt.Errorf("failed to update sampling frequency: %v", err)
 		}
-		*events = append(*events, perfEvent)
 	}
+	t.samplesPerSecond.Store(int64(samplesPerSecond))
+	t.samplingFrequencies.set(since, samplesPerSecond)
+
+	log.Infof("Changed sampling frequency to %d Hz", samplesPerSecond)
 	return nil
 }
 
@@ -1230,6 +1573,7 @@ func (t *Tracer) EnableProfiling() error {
 			return fmt.Errorf("failed to enable perf event on CPU %d: %v", id, err)
 		}
 	}
+	t.perfEventsDisabled = false
 	return nil
 }
 
@@ -1251,6 +1595,7 @@ func (t *Tracer) probabilisticProfile(interval time.Duration, threshold uint) {
 
 	events := t.perfEntrypoints.WLock()
 	defer t.perfEntrypoints.WUnlock(&events)
+	t.perfEventsDisabled = !enableSampling
 	var enableErr, disableErr metrics.MetricValue
 	for _, event := range *events {
 		if enableSampling {
@@ -1291,6 +1636,21 @@ func (t *Tracer) StartProbabilisticProfiling(ctx context.Context) {
 	})
 }
 
+// loadOffCPUPIDs stores the PIDs off-CPU profiling is restricted to in the
+// eBPF map offcpu_pids.
+func (t *Tracer) loadOffCPUPIDs(pids []libpf.PID) error {
+	offCPUPIDsMap := t.ebpfMaps["offcpu_pids"]
+	value := true
+	for _, pid := range pids {
+		key := uint32(pid)
+		if err := offCPUPIDsMap.Update(unsafe.Pointer(&key), unsafe.Pointer(&value),
+			cebpf.UpdateAny); err != nil {
+			return fmt.Errorf("failed to add PID %d: %v", pid, err)
+		}
+	}
+	return nil
+}
+
 // StartOffCPUProfiling starts off-cpu profiling by attaching the programs to the hooks.
 func (t *Tracer) StartOffCPUProfiling() error {
 	// Attach the second hook for off-cpu profiling first.
@@ -1321,6 +1681,18 @@ func (t *Tracer) StartOffCPUProfiling() error {
 			len(kprobeSymbs), hookSymbolPrefix)
 	}
 
+	if t.offCPUWakeups {
+		wakingProg, ok := t.ebpfProgs["tracepoint__sched_waking"]
+		if !ok {
+			return errors.New("tracepoint__sched_waking is not available")
+		}
+		wakingLink, err := link.Tracepoint("sched", "sched_waking", wakingProg, nil)
+		if err != nil {
+			return fmt.Errorf("failed to attach to sched_waking: %v", err)
+		}
+		t.hooks[hookPoint{group: "sched", name: "sched_waking"}] = wakingLink
+	}
+
 	// Attach the first hook that enables off-cpu profiling.
 	tpProg, ok := t.ebpfProgs["tracepoint__sched_switch"]
 	if !ok {
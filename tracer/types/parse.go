@@ -23,6 +23,9 @@ const (
 	V8Tracer
 	DotnetTracer
 	GoTracer
+	// PerfMapTracer symbolizes JIT code described by perf map and jitdump
+	// files. It is not enabled by 'all'.
+	PerfMapTracer
 
 	// maxTracers indicates the max. number of different tracers
 	maxTracers
@@ -37,6 +40,7 @@ var tracerTypeToName = map[tracerType]string{
 	V8Tracer:      "v8",
 	DotnetTracer:  "dotnet",
 	GoTracer:      "go",
+	PerfMapTracer: "perfmap",
 }
 
 var tracerNameToType = make(map[string]tracerType, maxTracers)
@@ -92,10 +96,18 @@ func (t *IncludedTracers) Disable(tracer tracerType) {
 	*t &= ^(1 << tracer)
 }
 
-// enableAll enables all known tracers.
+// enabledByAll reports whether 'all' enables the tracer. The perfmap tracer,
+// which looks for files of every process, needs to be enabled explicitly.
+func enabledByAll(tracer tracerType) bool {
+	return tracer != PerfMapTracer
+}
+
+// enableAll enables all known tracers that are enabled by 'all'.
 func (t *IncludedTracers) enableAll() {
 	for tracer := range maxTracers {
-		t.Enable(tracer)
+		if enabledByAll(tracer) {
+			t.Enable(tracer)
+		}
 	}
 }
 
@@ -25,6 +25,7 @@ var tracersTestsOK = []struct {
 	{"native,python", []tracerType{PythonTracer}},
 	{"native,php,python", []tracerType{PHPTracer, PythonTracer}},
 	{"dotnet,ruby", []tracerType{DotnetTracer, RubyTracer}},
+	{"native,perfmap", []tracerType{PerfMapTracer}},
 }
 
 // tests expected to fail
@@ -44,7 +45,7 @@ func TestParseTracers(t *testing.T) {
 
 			if tt.expectedTracers == nil {
 				for tracer := range maxTracers {
-					if availableOnArch(tracer) {
+					if availableOnArch(tracer) && enabledByAll(tracer) {
 						require.True(t, include.Has(tracer))
 					} else {
 						require.False(t, include.Has(tracer))
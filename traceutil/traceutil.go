@@ -5,6 +5,7 @@ package traceutil // import "go.opentelemetry.io/ebpf-profiler/traceutil"
 
 import (
 	"hash/fnv"
+	"slices"
 	"strconv"
 
 	"go.opentelemetry.io/ebpf-profiler/libpf"
@@ -22,6 +23,21 @@ func HashTrace(trace *libpf.Trace) libpf.TraceHash {
 		// to escaping to heap (allocation).
 		_, _ = h.Write(strconv.AppendUint(buf[:0], uint64(trace.Linenos[i]), 10))
 	}
+	// Custom labels only contribute to the hash if present, so that the hash
+	// of traces without labels is unchanged.
+	if len(trace.CustomLabels) > 0 {
+		keys := make([]string, 0, len(trace.CustomLabels))
+		for key := range trace.CustomLabels {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			_, _ = h.Write([]byte(key))
+			_, _ = h.Write([]byte{0})
+			_, _ = h.Write([]byte(trace.CustomLabels[key]))
+			_, _ = h.Write([]byte{0})
+		}
+	}
 	// make instead of nil avoids a heap allocation
 	traceHash, _ := libpf.TraceHashFromBytes(h.Sum(make([]byte, 0, 16)))
 	return traceHash
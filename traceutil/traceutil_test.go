@@ -51,3 +51,20 @@ func TestHashTrace(t *testing.T) {
 		})
 	}
 }
+
+func TestHashTraceCustomLabels(t *testing.T) {
+	trace := &libpf.Trace{
+		Linenos:    []libpf.AddressOrLineno{1},
+		Files:      []libpf.FileID{libpf.NewFileID(1, 1)},
+		FrameTypes: []libpf.FrameType{libpf.PythonFrame},
+	}
+	unlabeled := HashTrace(trace)
+
+	trace.SetCustomLabel("task", "a")
+	labeledA := HashTrace(trace)
+	trace.SetCustomLabel("task", "b")
+	labeledB := HashTrace(trace)
+
+	assert.NotEqual(t, unlabeled, labeledA)
+	assert.NotEqual(t, labeledA, labeledB)
+}